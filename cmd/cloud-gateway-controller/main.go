@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -13,9 +15,12 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
 	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/controllers"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/logging"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/tracing"
 	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/version"
 	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 	//+kubebuilder:scaffold:imports
@@ -29,66 +34,111 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(gatewayv1beta1.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
+// Leases are needed for leader election of the manager
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;patch;delete
+
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
-	opts := zap.Options{
-		Development: true,
+	if len(os.Args) > 1 && os.Args[1] == "rbac" {
+		if err := rbacCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
+
+	var configFile string
+	flag.StringVar(&configFile, "config", "",
+		"The controller configuration file. Command line flags override values from the file.")
+	cfg := config.New()
+	cfg.BindFlags(flag.CommandLine)
+	opts := logging.NewOptions()
 	opts.BindFlags(flag.CommandLine)
+	var tracingOpts tracing.Options
+	tracingOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	ctrl.SetLogger(opts.Logger())
 	setupLog.Info("initializing", "version", version.Version)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "77c39b72.pixelperfekt.dk",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
-	})
+	if configFile != "" {
+		fileCfg, err := config.Load(configFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load configuration file")
+			os.Exit(1)
+		}
+		if err := fileCfg.Override(flag.CommandLine); err != nil {
+			setupLog.Error(err, "unable to apply command line flags to configuration")
+			os.Exit(1)
+		}
+		cfg = fileCfg
+	}
+	cfg.Complete()
+	if err := cfg.Validate(); err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+	setupLog.Info("configuration", "controllerName", cfg.ControllerName, "namespaces", cfg.Namespaces,
+		"fieldManager", cfg.FieldManager, "leaderElectionID", cfg.LeaderElection.ResourceName,
+		"featureGates", cfg.FeatureGates.String())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to setup tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), cfg.ManagerOptions(scheme))
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	gwcctrl := controllers.NewGatewayClassController(mgr)
-	if err = gwcctrl.SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "GatewayClassController")
+	if err = controllers.SetupIndexes(context.Background(), mgr); err != nil {
+		setupLog.Error(err, "unable to setup indexes")
 		os.Exit(1)
 	}
-	gwctrl := controllers.NewGatewayController(mgr)
+
+	if err = checkPermissions(context.Background(), mgr, cfg); err != nil {
+		setupLog.Error(err, "unable to check permissions")
+	}
+
+	if !cfg.DisableGatewayClassController {
+		gwcctrl := controllers.NewGatewayClassController(mgr, cfg)
+		if err = gwcctrl.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GatewayClassController")
+			os.Exit(1)
+		}
+	}
+	clusters, err := controllers.NewClusterRegistry(mgr, cfg)
+	if err != nil {
+		setupLog.Error(err, "unable to create member cluster registry")
+		os.Exit(1)
+	}
+	gwctrl := controllers.NewGatewayController(mgr, cfg, clusters)
 	if err = gwctrl.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GatewayController")
 		os.Exit(1)
 	}
-	rtctrl := controllers.NewHTTPRouteController(mgr)
+	rtctrl := controllers.NewHTTPRouteController(mgr, cfg, clusters)
 	if err = rtctrl.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HTTPRouteController")
 		os.Exit(1)
 	}
+	if prober := controllers.NewClusterProber(mgr, clusters); prober != nil {
+		if err = mgr.Add(prober); err != nil {
+			setupLog.Error(err, "unable to add member cluster prober")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
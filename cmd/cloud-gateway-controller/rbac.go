@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/controllers"
+)
+
+// rbacCommand prints a ClusterRole with the rules required by the controller
+// and the templates of the given GatewayClasses, or all GatewayClasses
+// managed by the controller if none are given. For classes applying templates
+// as a ServiceAccount, a Role allowing to impersonate it is printed for the
+// namespace of the class parameters.
+func rbacCommand(args []string) error {
+	fs := flag.NewFlagSet("rbac", flag.ExitOnError)
+	controllerName := fs.String("controller-name", config.DefaultControllerName,
+		"The controller name of GatewayClasses managed by the controller.")
+	roleName := fs.String("name", "cloud-gateway-controller", "The name of the ClusterRole.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s rbac [flags] [gatewayclass...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	ctx := context.Background()
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	classNames := fs.Args()
+	if len(classNames) == 0 {
+		var gwcList gatewayv1beta1.GatewayClassList
+		if err := c.List(ctx, &gwcList); err != nil {
+			return err
+		}
+		for i := range gwcList.Items {
+			if string(gwcList.Items[i].Spec.ControllerName) == *controllerName {
+				classNames = append(classNames, gwcList.Items[i].Name)
+			}
+		}
+	}
+
+	// Leader election rules are included, as the namespace of the lease is
+	// not known here
+	rules := append(controllers.BaseRules(), controllers.LeaderElectionRules()...)
+	namespaceRules := map[string][]rbacv1.PolicyRule{}
+	for _, name := range classNames {
+		var gwc gatewayv1beta1.GatewayClass
+		if err := c.Get(ctx, types.NamespacedName{Name: name}, &gwc); err != nil {
+			return err
+		}
+		ref := gwc.Spec.ParametersRef
+		if ref == nil || ref.Namespace == nil {
+			return fmt.Errorf("GatewayClass %s has no parameters", name)
+		}
+		var configmap corev1.ConfigMap
+		if err := c.Get(ctx, types.NamespacedName{Namespace: string(*ref.Namespace), Name: ref.Name}, &configmap); err != nil {
+			return err
+		}
+		templateRules, err := controllers.TemplateRules(c.RESTMapper(), &configmap)
+		if err != nil {
+			return fmt.Errorf("GatewayClass %s: %w", name, err)
+		}
+		saRules, err := controllers.ServiceAccountRules(&configmap)
+		if err != nil {
+			return fmt.Errorf("GatewayClass %s: %w", name, err)
+		}
+		if saRules != nil {
+			// Template rules are to be granted to the ServiceAccount
+			fmt.Fprintf(os.Stderr, "GatewayClass %s applies templates as ServiceAccount %s, which needs rules:\n%s\n",
+				name, configmap.Data["serviceAccount"], controllers.FormatRules(templateRules))
+			rules = append(rules, controllers.ImpersonationRules()...)
+			namespaceRules[configmap.Namespace] = append(namespaceRules[configmap.Namespace], saRules...)
+			continue
+		}
+		rules = append(rules, templateRules...)
+	}
+
+	role := rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: *roleName},
+		Rules:      controllers.MergeRules(rules),
+	}
+	out, err := yaml.Marshal(&role)
+	if err != nil {
+		return err
+	}
+
+	namespaces := make([]string, 0, len(namespaceRules))
+	for ns := range namespaceRules {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		// Rules are not merged, as they are restricted to ServiceAccount names
+		role := rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: *roleName + "-impersonation", Namespace: ns},
+			Rules:      namespaceRules[ns],
+		}
+		roleOut, err := yaml.Marshal(&role)
+		if err != nil {
+			return err
+		}
+		out = append(append(out, "---\n"...), roleOut...)
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// checkPermissions logs the rules required by the controller independent of
+// class templates which are not granted. Leader election rules are checked in
+// the namespace of the lease only. Rules required by templates are reported
+// in the status of each GatewayClass.
+func checkPermissions(ctx context.Context, mgr ctrl.Manager, cfg *config.ControllerConfiguration) error {
+	c, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+	missing, err := controllers.MissingRules(ctx, c, "", controllers.BaseRules(), cfg.Namespaces)
+	if err != nil {
+		return err
+	}
+	if cfg.LeaderElection.LeaderElect {
+		ns := cfg.LeaderElectionNamespace()
+		if ns == "" {
+			setupLog.Info("unable to check leader election permissions, namespace of the lease unknown")
+		} else {
+			missingLeases, err := controllers.MissingRules(ctx, c, "", controllers.LeaderElectionRules(), []string{ns})
+			if err != nil {
+				return err
+			}
+			missing = controllers.MergeRules(append(missing, missingLeases...))
+		}
+	}
+	if ns := cfg.Multicluster.ClusterSecretNamespace; ns != "" {
+		missingSecrets, err := controllers.MissingRules(ctx, c, "", controllers.MulticlusterRules(), []string{ns})
+		if err != nil {
+			return err
+		}
+		missing = controllers.MergeRules(append(missing, missingSecrets...))
+	}
+	if len(missing) > 0 {
+		setupLog.Error(fmt.Errorf("missing RBAC permissions"), "controller lacks required permissions",
+			"missing", controllers.FormatRules(missing))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Command cloud-simulator runs the local cloud simulator, reconciling
+// simulated load balancers, DNS records and certificates for end-to-end tests
+// without a cloud provider.
+package main
+
+import (
+	"flag"
+	"os"
+
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	simulatorv1alpha1 "github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/logging"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/simulator"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/version"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(simulatorv1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	var metricsAddr string
+	var opts simulator.Options
+	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metric endpoint binds to, disabled if 0.")
+	flag.DurationVar(&opts.ProvisioningDelay, "provisioning-delay", 0,
+		"Time from creation of simulated resources until they are ready.")
+	logOpts := logging.NewOptions()
+	logOpts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(logOpts.Logger())
+	setupLog.Info("initializing", "version", version.Version, "provisioningDelay", opts.ProvisioningDelay)
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{Scheme: scheme, MetricsBindAddress: metricsAddr})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+	if err := simulator.SetupWithManager(mgr, opts); err != nil {
+		setupLog.Error(err, "unable to create simulator controllers")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
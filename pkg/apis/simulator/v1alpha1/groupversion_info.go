@@ -0,0 +1,22 @@
+// Package v1alpha1 contains the API of the cloud simulator, i.e. simulated
+// cloud resources that class templates can target in tests without a cloud
+// provider.
+// +kubebuilder:object:generate=true
+// +groupName=simulator.cloud-gateway-controller.pixelperfekt.dk
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group version of the API
+	GroupVersion = schema.GroupVersion{Group: "simulator.cloud-gateway-controller.pixelperfekt.dk", Version: "v1alpha1"}
+
+	// SchemeBuilder adds the types of the API to a scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types of the API to a scheme
+	AddToScheme = SchemeBuilder.AddToScheme
+)
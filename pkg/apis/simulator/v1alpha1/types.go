@@ -0,0 +1,266 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionReady is true when a simulated resource is provisioned
+	ConditionReady = "Ready"
+
+	// ReasonProvisioned is used with the Ready condition when a simulated
+	// resource is provisioned
+	ReasonProvisioned = "Provisioned"
+
+	// ReasonProvisioning is used with the Ready condition while a simulated
+	// resource is being provisioned
+	ReasonProvisioning = "Provisioning"
+
+	// ReasonInvalid is used with the Ready condition when the spec of a
+	// simulated resource is invalid
+	ReasonInvalid = "Invalid"
+
+	// ReasonFailed is used with the Ready condition when failure is
+	// injected with the FailAnnotation
+	ReasonFailed = "Failed"
+
+	// ReasonCertificateNotReady is used with the Ready condition of load
+	// balancers referencing certificates that are not found or not ready
+	ReasonCertificateNotReady = "CertificateNotReady"
+
+	// FailAnnotation injects a provisioning failure in a simulated resource,
+	// with the value as message
+	FailAnnotation = "simulator.cloud-gateway-controller.pixelperfekt.dk/fail"
+)
+
+// LoadBalancerScheme is whether a load balancer is reachable from the
+// internet or internal
+// +kubebuilder:validation:Enum=internet-facing;internal
+type LoadBalancerScheme string
+
+const (
+	LoadBalancerSchemeInternetFacing LoadBalancerScheme = "internet-facing"
+	LoadBalancerSchemeInternal       LoadBalancerScheme = "internal"
+)
+
+// SimulatedLoadBalancerSpec describes a load balancer, e.g. an AWS ALB
+type SimulatedLoadBalancerSpec struct {
+	// Scheme of the load balancer, internet-facing if not specified
+	// +optional
+	Scheme LoadBalancerScheme `json:"scheme,omitempty"`
+
+	// Addresses requested for the load balancer. Addresses are assigned
+	// from the simulated address pool if not specified.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+
+	// Listeners of the load balancer
+	// +optional
+	Listeners []SimulatedListener `json:"listeners,omitempty"`
+
+	// Backends of the load balancer
+	// +optional
+	Backends []SimulatedBackend `json:"backends,omitempty"`
+}
+
+// SimulatedListener is a listener of a load balancer
+type SimulatedListener struct {
+	// Port of the listener
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Protocol of the listener, e.g. HTTP or HTTPS
+	Protocol string `json:"protocol"`
+
+	// Hostnames served by the listener
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	// CertificateRefs are the names of SimulatedCertificates in the
+	// namespace of the load balancer used by the listener. The load balancer
+	// is not ready until the certificates are ready.
+	// +optional
+	CertificateRefs []string `json:"certificateRefs,omitempty"`
+}
+
+// SimulatedBackend is a backend of a load balancer
+type SimulatedBackend struct {
+	// Address of the backend
+	Address string `json:"address"`
+
+	// Port of the backend
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Weight of traffic to the backend
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// SimulatedLoadBalancerStatus is the status of a simulated load balancer
+type SimulatedLoadBalancerStatus struct {
+	// ARN of the load balancer
+	// +optional
+	ARN string `json:"arn,omitempty"`
+
+	// LoadBalancer is the addresses assigned to the load balancer
+	// +optional
+	LoadBalancer corev1.LoadBalancerStatus `json:"loadBalancer,omitempty"`
+
+	// Conditions of the load balancer, i.e. Ready
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SimulatedLoadBalancer is a simulated cloud load balancer
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=simlb
+// +kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.loadBalancer.ingress[0].ip`
+// +kubebuilder:printcolumn:name="Hostname",type=string,JSONPath=`.status.loadBalancer.ingress[0].hostname`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type SimulatedLoadBalancer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SimulatedLoadBalancerSpec   `json:"spec,omitempty"`
+	Status SimulatedLoadBalancerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SimulatedLoadBalancerList is a list of SimulatedLoadBalancers
+type SimulatedLoadBalancerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SimulatedLoadBalancer `json:"items"`
+}
+
+// DNSRecordType is the type of a DNS record
+// +kubebuilder:validation:Enum=A;AAAA;CNAME
+type DNSRecordType string
+
+const (
+	DNSRecordTypeA     DNSRecordType = "A"
+	DNSRecordTypeAAAA  DNSRecordType = "AAAA"
+	DNSRecordTypeCNAME DNSRecordType = "CNAME"
+)
+
+// SimulatedDNSRecordSpec describes a DNS record, e.g. an AWS Route 53 record
+type SimulatedDNSRecordSpec struct {
+	// Name of the record, i.e. a fully qualified domain name
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Type of the record
+	Type DNSRecordType `json:"type"`
+
+	// Targets of the record, i.e. IP addresses for A and AAAA records and a
+	// single hostname for CNAME records
+	// +kubebuilder:validation:MinItems=1
+	Targets []string `json:"targets"`
+
+	// TTL of the record in seconds
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTL int32 `json:"ttl,omitempty"`
+}
+
+// SimulatedDNSRecordStatus is the status of a simulated DNS record
+type SimulatedDNSRecordStatus struct {
+	// ChangeID is the ID of the last change of the record
+	// +optional
+	ChangeID string `json:"changeID,omitempty"`
+
+	// Conditions of the record, i.e. Ready
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SimulatedDNSRecord is a simulated cloud DNS record
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=simdns
+// +kubebuilder:printcolumn:name="Name",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type SimulatedDNSRecord struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SimulatedDNSRecordSpec   `json:"spec,omitempty"`
+	Status SimulatedDNSRecordStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SimulatedDNSRecordList is a list of SimulatedDNSRecords
+type SimulatedDNSRecordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SimulatedDNSRecord `json:"items"`
+}
+
+// SimulatedCertificateSpec describes a certificate, e.g. an AWS ACM
+// certificate
+type SimulatedCertificateSpec struct {
+	// DNSNames covered by the certificate
+	// +kubebuilder:validation:MinItems=1
+	DNSNames []string `json:"dnsNames"`
+}
+
+// SimulatedCertificateStatus is the status of a simulated certificate
+type SimulatedCertificateStatus struct {
+	// ARN of the certificate
+	// +optional
+	ARN string `json:"arn,omitempty"`
+
+	// NotAfter is the expiry time of the certificate
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// Conditions of the certificate, i.e. Ready
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SimulatedCertificate is a simulated cloud certificate
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=simcert
+// +kubebuilder:printcolumn:name="ARN",type=string,JSONPath=`.status.arn`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type SimulatedCertificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SimulatedCertificateSpec   `json:"spec,omitempty"`
+	Status SimulatedCertificateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SimulatedCertificateList is a list of SimulatedCertificates
+type SimulatedCertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SimulatedCertificate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SimulatedLoadBalancer{}, &SimulatedLoadBalancerList{},
+		&SimulatedDNSRecord{}, &SimulatedDNSRecordList{},
+		&SimulatedCertificate{}, &SimulatedCertificateList{})
+}
@@ -0,0 +1,119 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultWeight is the weight of clusters not specifying a weight
+	DefaultWeight = 100
+
+	// WeightAnnotation on GatewayClusters gives the weight of traffic to
+	// the cluster, taking precedence over the weight of the spec
+	WeightAnnotation = "cloud-gateway-controller.pixelperfekt.dk/weight"
+
+	// DrainAnnotation on GatewayClusters drains traffic from the cluster if
+	// "true", taking precedence over the drain field of the spec
+	DrainAnnotation = "cloud-gateway-controller.pixelperfekt.dk/drain"
+
+	// DefaultKubeconfigKey is the key of the kubeconfig in Secrets
+	// referenced by clusters not specifying a key
+	DefaultKubeconfigKey = "kubeconfig"
+
+	// GatewayClusterConditionReady is true if the member cluster is
+	// reachable with the referenced kubeconfig
+	GatewayClusterConditionReady = "Ready"
+
+	// GatewayClusterReasonReachable is used with the Ready condition when
+	// the member cluster is reachable
+	GatewayClusterReasonReachable = "Reachable"
+
+	// GatewayClusterReasonUnreachable is used with the Ready condition when
+	// requests to the member cluster fail
+	GatewayClusterReasonUnreachable = "Unreachable"
+
+	// GatewayClusterReasonInvalidKubeconfig is used with the Ready condition
+	// when the referenced kubeconfig is missing or invalid
+	GatewayClusterReasonInvalidKubeconfig = "InvalidKubeconfig"
+)
+
+// GatewayClusterSpec describes a member cluster of a hub controller
+type GatewayClusterSpec struct {
+	// KubeconfigSecretRef references the Secret holding the kubeconfig of
+	// the member cluster. The Secret must be in the namespace of the
+	// GatewayCluster.
+	KubeconfigSecretRef SecretKeyReference `json:"kubeconfigSecretRef"`
+
+	// Weight of traffic to the cluster relative to other clusters
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=100
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+
+	// Drain traffic from the cluster, e.g. before upgrading or removing it
+	// +optional
+	Drain bool `json:"drain,omitempty"`
+}
+
+// SecretKeyReference references a key of a Secret
+type SecretKeyReference struct {
+	// Name of the Secret
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key in the Secret, "kubeconfig" if not specified
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// GatewayClusterStatus is the status of a member cluster probed by the
+// controller
+type GatewayClusterStatus struct {
+	// Conditions of the cluster, i.e. Ready
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// GatewayCluster registers a member cluster of a hub controller. Region and
+// zone of the cluster are given by the topology.kubernetes.io/region and
+// topology.kubernetes.io/zone labels, which may be selected by the
+// clusterSelector of GatewayClasses.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=gwcluster
+// +kubebuilder:printcolumn:name="Region",type=string,JSONPath=`.metadata.labels.topology\.kubernetes\.io/region`
+// +kubebuilder:printcolumn:name="Zone",type=string,JSONPath=`.metadata.labels.topology\.kubernetes\.io/zone`
+// +kubebuilder:printcolumn:name="Weight",type=integer,JSONPath=`.spec.weight`
+// +kubebuilder:printcolumn:name="Drain",type=boolean,JSONPath=`.spec.drain`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type GatewayCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GatewayClusterSpec   `json:"spec,omitempty"`
+	Status GatewayClusterStatus `json:"status,omitempty"`
+}
+
+// KubeconfigKey returns the key of the kubeconfig in the referenced Secret
+func (c *GatewayCluster) KubeconfigKey() string {
+	if c.Spec.KubeconfigSecretRef.Key == "" {
+		return DefaultKubeconfigKey
+	}
+	return c.Spec.KubeconfigSecretRef.Key
+}
+
+// +kubebuilder:object:root=true
+
+// GatewayClusterList is a list of GatewayClusters
+type GatewayClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GatewayCluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GatewayCluster{}, &GatewayClusterList{})
+}
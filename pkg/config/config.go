@@ -0,0 +1,359 @@
+// Package config defines the versioned controller configuration file, in the
+// style of Kubernetes component configuration, and command line flags
+// overriding values from the file.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// GroupVersion of the configuration file format
+	GroupVersion = "config.cloud-gateway-controller.pixelperfekt.dk/v1alpha1"
+
+	// Kind of the configuration file
+	Kind = "ControllerConfiguration"
+
+	// DefaultControllerName is the controller name of GatewayClasses managed
+	// by default
+	DefaultControllerName = "github.com/pixelperfekt-dk/cloud-gateway-controller"
+
+	defaultLeaderElectionID = "77c39b72.pixelperfekt.dk"
+
+	maxFieldManagerLength = 128
+)
+
+// ControllerConfiguration configures the controller
+type ControllerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerName is the controller name of GatewayClasses managed by this
+	// controller instance
+	ControllerName string `json:"controllerName,omitempty"`
+
+	// Namespaces watched for Gateways and HTTPRoutes. All namespaces are
+	// watched if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// DisableGatewayClassController disables reconciling of GatewayClasses,
+	// e.g. when running namespace-scoped with GatewayClass status managed by
+	// another instance. GatewayClasses are still read.
+	DisableGatewayClassController bool `json:"disableGatewayClassController,omitempty"`
+
+	// Concurrency is the maximum number of concurrent reconciles per
+	// controller
+	Concurrency ConcurrencyConfiguration `json:"concurrency,omitempty"`
+
+	// SyncPeriod is the minimum period at which watched resources are
+	// reconciled
+	SyncPeriod metav1.Duration `json:"syncPeriod,omitempty"`
+
+	// FeatureGates enables or disables features by name
+	FeatureGates FeatureGates `json:"featureGates,omitempty"`
+
+	// Cache configures the informer cache
+	Cache CacheConfiguration `json:"cache,omitempty"`
+
+	// FieldManager is the field manager used when applying objects created
+	// from templates. Defaults to the controller name.
+	FieldManager string `json:"fieldManager,omitempty"`
+
+	// Metrics configures the metrics endpoint
+	Metrics EndpointConfiguration `json:"metrics,omitempty"`
+
+	// Health configures the health probe endpoint
+	Health EndpointConfiguration `json:"health,omitempty"`
+
+	// LeaderElection configures leader election
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+
+	// WebhookPort is the port of the webhook server
+	WebhookPort int `json:"webhookPort,omitempty"`
+
+	// Multicluster configures hub mode, where shadow Gateways and HTTPRoutes
+	// are created in member clusters
+	Multicluster MulticlusterConfiguration `json:"multicluster,omitempty"`
+}
+
+// ConcurrencyConfiguration is the maximum number of concurrent reconciles per
+// controller
+type ConcurrencyConfiguration struct {
+	GatewayClass int `json:"gatewayClass,omitempty"`
+	Gateway      int `json:"gateway,omitempty"`
+	HTTPRoute    int `json:"httpRoute,omitempty"`
+}
+
+// CacheConfiguration configures the informer cache
+type CacheConfiguration struct {
+	// SyncTimeout is the time to wait for caches to sync when starting
+	// controllers
+	SyncTimeout metav1.Duration `json:"syncTimeout,omitempty"`
+
+	// LabelSelector restricts the Gateways and HTTPRoutes watched to those
+	// matching the selector
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// EndpointConfiguration configures an HTTP endpoint
+type EndpointConfiguration struct {
+	// BindAddress is the address the endpoint binds to, '0' disables the
+	// endpoint
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// MulticlusterConfiguration configures hub mode
+type MulticlusterConfiguration struct {
+	// ClusterSecretNamespace is the namespace of GatewayClusters registering
+	// member clusters and the Secrets holding their kubeconfigs. Hub mode is
+	// enabled if set.
+	ClusterSecretNamespace string `json:"clusterSecretNamespace,omitempty"`
+}
+
+// LeaderElectionConfiguration configures leader election
+type LeaderElectionConfiguration struct {
+	// LeaderElect enables leader election
+	LeaderElect bool `json:"leaderElect,omitempty"`
+
+	// ResourceName is the name of the lease used for leader election.
+	// Defaults to a name derived from the controller name.
+	ResourceName string `json:"resourceName,omitempty"`
+
+	// ResourceNamespace is the namespace of the lease used for leader
+	// election. Defaults to the namespace the controller runs in.
+	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+}
+
+// New returns a configuration with defaults
+func New() *ControllerConfiguration {
+	return &ControllerConfiguration{
+		TypeMeta:       metav1.TypeMeta{APIVersion: GroupVersion, Kind: Kind},
+		ControllerName: DefaultControllerName,
+		Concurrency:    ConcurrencyConfiguration{GatewayClass: 1, Gateway: 1, HTTPRoute: 1},
+		SyncPeriod:     metav1.Duration{Duration: 10 * time.Hour},
+		FeatureGates:   FeatureGates{},
+		Cache:          CacheConfiguration{SyncTimeout: metav1.Duration{Duration: 2 * time.Minute}},
+		Metrics:        EndpointConfiguration{BindAddress: ":8080"},
+		Health:         EndpointConfiguration{BindAddress: ":8081"},
+		WebhookPort:    9443,
+	}
+}
+
+// Load reads a configuration file. Values not in the file are defaulted.
+func Load(path string) (*ControllerConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := New()
+	cfg.TypeMeta = metav1.TypeMeta{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cfg.APIVersion != GroupVersion || cfg.Kind != Kind {
+		return nil, fmt.Errorf("%s: unsupported configuration %q kind %q, expected %q kind %q",
+			path, cfg.APIVersion, cfg.Kind, GroupVersion, Kind)
+	}
+	return cfg, nil
+}
+
+// Complete sets defaults derived from the controller name, such that
+// controller instances with different controller names use separate leader
+// election leases and field managers.
+func (c *ControllerConfiguration) Complete() {
+	if c.FieldManager == "" {
+		c.FieldManager = c.ControllerName
+		if len(c.FieldManager) > maxFieldManagerLength {
+			c.FieldManager = "cloud-gateway-controller-" + InstanceID(c.ControllerName)
+		}
+	}
+	if c.LeaderElection.ResourceName == "" {
+		c.LeaderElection.ResourceName = defaultLeaderElectionID
+		if c.ControllerName != DefaultControllerName {
+			c.LeaderElection.ResourceName = InstanceID(c.ControllerName) + ".pixelperfekt.dk"
+		}
+	}
+}
+
+// InstanceID returns a short identifier of a controller name usable in
+// object names and label values
+func InstanceID(controllerName string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(controllerName))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Validate validates the configuration
+func (c *ControllerConfiguration) Validate() error {
+	if c.ControllerName == "" {
+		return fmt.Errorf("controllerName must not be empty")
+	}
+	if c.FieldManager == "" {
+		return fmt.Errorf("fieldManager must not be empty")
+	}
+	if _, err := labels.Parse(c.Cache.LabelSelector); err != nil {
+		return fmt.Errorf("cache.labelSelector: %w", err)
+	}
+	if c.Concurrency.GatewayClass < 1 || c.Concurrency.Gateway < 1 || c.Concurrency.HTTPRoute < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	return c.FeatureGates.validate()
+}
+
+// BindFlags binds command line flags to the configuration
+func (c *ControllerConfiguration) BindFlags(fs *flag.FlagSet) {
+	if c.FeatureGates == nil {
+		c.FeatureGates = FeatureGates{}
+	}
+	fs.StringVar(&c.ControllerName, "controller-name", c.ControllerName,
+		"The controller name of GatewayClasses managed by this controller.")
+	fs.Var((*stringList)(&c.Namespaces), "namespaces",
+		"Comma-separated list of namespaces watched for Gateways and HTTPRoutes. All namespaces are watched if empty.")
+	fs.BoolVar(&c.DisableGatewayClassController, "disable-gatewayclass-controller", c.DisableGatewayClassController,
+		"Disable reconciling of GatewayClasses, e.g. when running namespace-scoped.")
+	fs.IntVar(&c.Concurrency.GatewayClass, "gatewayclass-concurrency", c.Concurrency.GatewayClass,
+		"The maximum number of concurrent GatewayClass reconciles.")
+	fs.IntVar(&c.Concurrency.Gateway, "gateway-concurrency", c.Concurrency.Gateway,
+		"The maximum number of concurrent Gateway reconciles.")
+	fs.IntVar(&c.Concurrency.HTTPRoute, "httproute-concurrency", c.Concurrency.HTTPRoute,
+		"The maximum number of concurrent HTTPRoute reconciles.")
+	fs.DurationVar(&c.SyncPeriod.Duration, "sync-period", c.SyncPeriod.Duration,
+		"The minimum period at which watched resources are reconciled.")
+	fs.Var(c.FeatureGates, "feature-gates",
+		"Comma-separated list of feature=true|false pairs. Known features: "+strings.Join(knownFeatureNames(), ", ")+".")
+	fs.DurationVar(&c.Cache.SyncTimeout.Duration, "cache-sync-timeout", c.Cache.SyncTimeout.Duration,
+		"The time to wait for caches to sync when starting controllers.")
+	fs.StringVar(&c.Cache.LabelSelector, "label-selector", c.Cache.LabelSelector,
+		"Label selector restricting the Gateways and HTTPRoutes watched.")
+	fs.StringVar(&c.FieldManager, "field-manager", c.FieldManager,
+		"The field manager used when applying objects created from templates. Defaults to the controller name.")
+	fs.StringVar(&c.Metrics.BindAddress, "metrics-bind-address", c.Metrics.BindAddress,
+		"The address the metric endpoint binds to.")
+	fs.StringVar(&c.Health.BindAddress, "health-probe-bind-address", c.Health.BindAddress,
+		"The address the probe endpoint binds to.")
+	fs.BoolVar(&c.LeaderElection.LeaderElect, "leader-elect", c.LeaderElection.LeaderElect,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	fs.StringVar(&c.LeaderElection.ResourceName, "leader-election-id", c.LeaderElection.ResourceName,
+		"The name of the lease used for leader election. Defaults to a name derived from the controller name.")
+	fs.StringVar(&c.LeaderElection.ResourceNamespace, "leader-election-namespace", c.LeaderElection.ResourceNamespace,
+		"The namespace of the lease used for leader election. Defaults to the namespace the controller runs in.")
+	fs.StringVar(&c.Multicluster.ClusterSecretNamespace, "cluster-secret-namespace", c.Multicluster.ClusterSecretNamespace,
+		"Namespace of GatewayClusters registering member clusters and their kubeconfig Secrets. Enables hub mode if set.")
+}
+
+// Override sets the values of flags explicitly set on the command line in
+// the configuration, such that flags take precedence over a configuration
+// file.
+func (c *ControllerConfiguration) Override(set *flag.FlagSet) error {
+	fs := flag.NewFlagSet("override", flag.ContinueOnError)
+	c.BindFlags(fs)
+	var err error
+	set.Visit(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) != nil && err == nil {
+			err = fs.Set(f.Name, f.Value.String())
+		}
+	})
+	return err
+}
+
+// stringList is a comma-separated list flag value
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// Feature is the name of a feature that can be enabled or disabled with
+// feature gates
+type Feature string
+
+// FeatureGates enables or disables features by name
+type FeatureGates map[Feature]bool
+
+// Known features and whether they are enabled by default
+var knownFeatures = map[Feature]bool{
+	// ListenerTemplates allows class templates rendered per listener
+	ListenerTemplates: true,
+}
+
+const (
+	ListenerTemplates Feature = "ListenerTemplates"
+)
+
+// Enabled returns true if a feature is enabled, either explicitly or by
+// default
+func (g FeatureGates) Enabled(f Feature) bool {
+	if enabled, found := g[f]; found {
+		return enabled
+	}
+	return knownFeatures[f]
+}
+
+func (g FeatureGates) validate() error {
+	for f := range g {
+		if _, found := knownFeatures[f]; !found {
+			return fmt.Errorf("unknown feature gate %q", f)
+		}
+	}
+	return nil
+}
+
+func (g FeatureGates) String() string {
+	pairs := make([]string, 0, len(g))
+	for f, enabled := range g {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", f, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (g FeatureGates) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, v, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("feature gate %q must be of the form feature=true|false", pair)
+		}
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("feature gate %q: %w", pair, err)
+		}
+		g[Feature(name)] = enabled
+	}
+	return nil
+}
+
+func knownFeatureNames() []string {
+	names := make([]string, 0, len(knownFeatures))
+	for f := range knownFeatures {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return names
+}
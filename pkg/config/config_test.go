@@ -0,0 +1,147 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load("../../test-data/controller-config.yaml")
+	if err != nil {
+		t.Fatalf("Cannot load configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.ControllerName != "example.com/internal-gateway-controller" {
+		t.Errorf("Unexpected controller name %q", cfg.ControllerName)
+	}
+	if len(cfg.Namespaces) != 2 || cfg.Namespaces[1] != "bar-infra" {
+		t.Errorf("Unexpected namespaces %v", cfg.Namespaces)
+	}
+	expectedConcurrency := ConcurrencyConfiguration{GatewayClass: 1, Gateway: 4, HTTPRoute: 8}
+	if cfg.Concurrency != expectedConcurrency {
+		t.Errorf("Expected concurrency %+v, got %+v", expectedConcurrency, cfg.Concurrency)
+	}
+	if cfg.SyncPeriod.Duration != time.Hour || cfg.Cache.SyncTimeout.Duration != 5*time.Minute {
+		t.Errorf("Unexpected durations %v, %v", cfg.SyncPeriod, cfg.Cache.SyncTimeout)
+	}
+	if cfg.FeatureGates.Enabled(ListenerTemplates) {
+		t.Errorf("Expected feature %s disabled", ListenerTemplates)
+	}
+	if cfg.Health.BindAddress != ":8081" || cfg.WebhookPort != 9443 {
+		t.Errorf("Expected defaults for values not in file, got %q, %d", cfg.Health.BindAddress, cfg.WebhookPort)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"unknown-field":   "apiVersion: " + GroupVersion + "\nkind: " + Kind + "\nfoo: bar\n",
+		"unknown-version": "apiVersion: example.com/v1\nkind: " + Kind + "\n",
+		"missing-kind":    "apiVersion: " + GroupVersion + "\n",
+	}
+	for name, data := range tests {
+		path := filepath.Join(dir, name+".yaml")
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Expected error loading %s", name)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	cfg := New()
+	cfg.Complete()
+	if cfg.FieldManager != DefaultControllerName || cfg.LeaderElection.ResourceName != defaultLeaderElectionID {
+		t.Errorf("Unexpected defaults %q, %q", cfg.FieldManager, cfg.LeaderElection.ResourceName)
+	}
+
+	other := New()
+	other.ControllerName = "example.com/internal-gateway-controller"
+	other.Complete()
+	if other.FieldManager != other.ControllerName {
+		t.Errorf("Expected field manager from controller name, got %q", other.FieldManager)
+	}
+	if other.LeaderElection.ResourceName == cfg.LeaderElection.ResourceName {
+		t.Errorf("Expected separate leader election IDs, got %q", other.LeaderElection.ResourceName)
+	}
+
+	long := New()
+	long.ControllerName = "example.com/" + strings.Repeat("x", maxFieldManagerLength)
+	long.Complete()
+	if len(long.FieldManager) > maxFieldManagerLength {
+		t.Errorf("Field manager too long: %q", long.FieldManager)
+	}
+
+	explicit := New()
+	explicit.ControllerName = other.ControllerName
+	explicit.FieldManager = "explicit"
+	explicit.Complete()
+	if explicit.FieldManager != "explicit" {
+		t.Errorf("Expected explicit field manager kept, got %q", explicit.FieldManager)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := New()
+	cfg.Complete()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected error validating defaults: %v", err)
+	}
+	cfg.FeatureGates["Unknown"] = true
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Expected error for unknown feature gate")
+	}
+	cfg = New()
+	cfg.Complete()
+	cfg.Concurrency.Gateway = 0
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Expected error for zero concurrency")
+	}
+	cfg = New()
+	cfg.Complete()
+	cfg.Cache.LabelSelector = "tenant in (a"
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Expected error for invalid label selector")
+	}
+}
+
+func TestFlagsOverrideFile(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	New().BindFlags(fs)
+	err := fs.Parse([]string{"--controller-name=example.com/override", "--namespaces=baz-infra",
+		"--gateway-concurrency=2", "--feature-gates=ListenerTemplates=true"})
+	if err != nil {
+		t.Fatalf("Cannot parse flags: %v", err)
+	}
+
+	cfg, err := Load("../../test-data/controller-config.yaml")
+	if err != nil {
+		t.Fatalf("Cannot load configuration: %v", err)
+	}
+	if err := cfg.Override(fs); err != nil {
+		t.Fatalf("Cannot override configuration: %v", err)
+	}
+	if cfg.ControllerName != "example.com/override" {
+		t.Errorf("Expected controller name from flag, got %q", cfg.ControllerName)
+	}
+	if len(cfg.Namespaces) != 1 || cfg.Namespaces[0] != "baz-infra" {
+		t.Errorf("Expected namespaces from flag, got %v", cfg.Namespaces)
+	}
+	if cfg.Concurrency.Gateway != 2 || cfg.Concurrency.HTTPRoute != 8 {
+		t.Errorf("Unexpected concurrency %+v", cfg.Concurrency)
+	}
+	if !cfg.FeatureGates.Enabled(ListenerTemplates) {
+		t.Errorf("Expected feature %s enabled by flag", ListenerTemplates)
+	}
+	if cfg.FieldManager != "internal-gateway-controller" {
+		t.Errorf("Expected field manager from file, got %q", cfg.FieldManager)
+	}
+}
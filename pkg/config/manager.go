@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// ManagerOptions returns controller manager options from the configuration
+func (c *ControllerConfiguration) ManagerOptions(scheme *runtime.Scheme) ctrl.Options {
+	syncPeriod := c.SyncPeriod.Duration
+	cacheSyncTimeout := c.Cache.SyncTimeout.Duration
+	opts := ctrl.Options{
+		Scheme:                  scheme,
+		MetricsBindAddress:      c.Metrics.BindAddress,
+		Port:                    c.WebhookPort,
+		HealthProbeBindAddress:  c.Health.BindAddress,
+		LeaderElection:          c.LeaderElection.LeaderElect,
+		LeaderElectionID:        c.LeaderElection.ResourceName,
+		LeaderElectionNamespace: c.LeaderElection.ResourceNamespace,
+		SyncPeriod:              &syncPeriod,
+		Controller: v1alpha1.ControllerConfigurationSpec{
+			GroupKindConcurrency: map[string]int{
+				"GatewayClass." + gateway.GroupName: c.Concurrency.GatewayClass,
+				"Gateway." + gateway.GroupName:      c.Concurrency.Gateway,
+				"HTTPRoute." + gateway.GroupName:    c.Concurrency.HTTPRoute,
+			},
+			CacheSyncTimeout: &cacheSyncTimeout,
+		},
+	}
+	opts.NewCache = c.newCache
+	return opts
+}
+
+// Path of the namespace of the ServiceAccount of Pods running in a cluster
+var inClusterNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// LeaderElectionNamespace returns the namespace of the leader election lease,
+// i.e. the configured namespace or the namespace the controller runs in, as
+// defaulted by the manager. It is empty if the controller does not run in a
+// cluster and no namespace is configured.
+func (c *ControllerConfiguration) LeaderElectionNamespace() string {
+	if c.LeaderElection.ResourceNamespace != "" {
+		return c.LeaderElection.ResourceNamespace
+	}
+	data, err := os.ReadFile(inClusterNamespacePath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// newCache creates a cache restricted to the configured namespaces, with
+// Gateways and HTTPRoutes restricted by the configured label selector.
+// Cluster-scoped objects, e.g. GatewayClasses, are cached for the cluster.
+func (c *ControllerConfiguration) newCache(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+	if c.Cache.LabelSelector != "" {
+		selector, err := labels.Parse(c.Cache.LabelSelector)
+		if err != nil {
+			return nil, err
+		}
+		opts.SelectorsByObject = cache.SelectorsByObject{
+			&gateway.Gateway{}:   {Label: selector},
+			&gateway.HTTPRoute{}: {Label: selector},
+		}
+	}
+	switch len(c.Namespaces) {
+	case 0:
+		return cache.New(config, opts)
+	case 1:
+		opts.Namespace = c.Namespaces[0]
+		return cache.New(config, opts)
+	default:
+		return cache.MultiNamespacedCacheBuilder(c.Namespaces)(config, opts)
+	}
+}
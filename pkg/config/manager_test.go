@@ -0,0 +1,138 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// listRecorder is an API server answering list requests with empty lists
+// and recording the path and label selector of each list
+type listRecorder struct {
+	sync.Mutex
+	lists []string
+}
+
+func (l *listRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("watch") == "true" {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+	l.Lock()
+	l.lists = append(l.lists, fmt.Sprintf("%s?%s", r.URL.Path, r.URL.Query().Get("labelSelector")))
+	l.Unlock()
+	fmt.Fprint(w, `{"metadata":{"resourceVersion":"1"},"items":[]}`)
+}
+
+func (l *listRecorder) recorded() []string {
+	l.Lock()
+	defer l.Unlock()
+	lists := append([]string{}, l.lists...)
+	sort.Strings(lists)
+	return lists
+}
+
+func TestNewCache(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{gateway.SchemeGroupVersion})
+	mapper.Add(gateway.SchemeGroupVersion.WithKind("GatewayClass"), meta.RESTScopeRoot)
+	mapper.AddSpecific(gateway.SchemeGroupVersion.WithKind("Gateway"),
+		gateway.SchemeGroupVersion.WithResource("gateways"), gateway.SchemeGroupVersion.WithResource("gateway"),
+		meta.RESTScopeNamespace)
+
+	const prefix = "/apis/gateway.networking.k8s.io/v1beta1"
+	tests := []struct {
+		namespaces    []string
+		labelSelector string
+		expected      []string
+	}{{
+		expected: []string{prefix + "/gatewayclasses?", prefix + "/gateways?"},
+	}, {
+		namespaces: []string{"foo-infra"},
+		expected:   []string{prefix + "/gatewayclasses?", prefix + "/namespaces/foo-infra/gateways?"},
+	}, {
+		namespaces:    []string{"foo-infra", "bar-infra"},
+		labelSelector: "tenant=foo",
+		expected: []string{
+			prefix + "/gatewayclasses?",
+			prefix + "/namespaces/bar-infra/gateways?tenant=foo",
+			prefix + "/namespaces/foo-infra/gateways?tenant=foo",
+		},
+	}}
+	for _, tc := range tests {
+		recorder := &listRecorder{}
+		server := httptest.NewServer(recorder)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		c := New()
+		c.Namespaces = tc.namespaces
+		c.Cache.LabelSelector = tc.labelSelector
+		informers, err := c.newCache(&rest.Config{Host: server.URL}, cache.Options{Scheme: scheme, Mapper: mapper})
+		if err != nil {
+			t.Fatalf("Cannot create cache for namespaces %v: %v", tc.namespaces, err)
+		}
+		go func() { _ = informers.Start(ctx) }()
+		informers.WaitForCacheSync(ctx)
+		for _, obj := range []client.Object{&gateway.GatewayClass{}, &gateway.Gateway{}} {
+			if _, err := informers.GetInformer(ctx, obj); err != nil {
+				t.Fatalf("Cannot get informer: %v", err)
+			}
+		}
+		if lists := recorder.recorded(); !reflect.DeepEqual(lists, tc.expected) {
+			t.Errorf("Expected lists %v for namespaces %v, got %v", tc.expected, tc.namespaces, lists)
+		}
+
+		cancel()
+		server.CloseClientConnections()
+		server.Close()
+	}
+
+	c := New()
+	c.Cache.LabelSelector = "tenant in (foo"
+	if _, err := c.newCache(&rest.Config{}, cache.Options{Scheme: scheme, Mapper: mapper}); err == nil {
+		t.Errorf("Expected error for invalid label selector")
+	}
+}
+
+func TestLeaderElectionNamespace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "namespace")
+	defer func(previous string) { inClusterNamespacePath = previous }(inClusterNamespacePath)
+	inClusterNamespacePath = path
+
+	c := New()
+	if ns := c.LeaderElectionNamespace(); ns != "" {
+		t.Errorf("Expected no namespace outside a cluster, got %q", ns)
+	}
+	if err := os.WriteFile(path, []byte("cloud-gateway-controller\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ns := c.LeaderElectionNamespace(); ns != "cloud-gateway-controller" {
+		t.Errorf("Expected namespace of the controller, got %q", ns)
+	}
+	c.LeaderElection.ResourceNamespace = "leases"
+	if ns := c.LeaderElectionNamespace(); ns != "leases" {
+		t.Errorf("Expected configured namespace, got %q", ns)
+	}
+	if opts := c.ManagerOptions(runtime.NewScheme()); opts.LeaderElectionNamespace != "leases" {
+		t.Errorf("Expected configured namespace in manager options, got %q", opts.LeaderElectionNamespace)
+	}
+}
@@ -0,0 +1,205 @@
+package conformance
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// namespaceLabels returns the labels of all namespaces by name
+func namespaceLabels(ctx context.Context, c client.Client) (map[string]map[string]string, error) {
+	var nsList corev1.NamespaceList
+	if err := c.List(ctx, &nsList); err != nil {
+		return nil, err
+	}
+	nsLabels := make(map[string]map[string]string, len(nsList.Items))
+	for i := range nsList.Items {
+		nsLabels[nsList.Items[i].Name] = nsList.Items[i].Labels
+	}
+	return nsLabels, nil
+}
+
+// parentRefMatches returns true if a parent reference of a route in the given
+// namespace refers to a Gateway
+func parentRefMatches(pref *gateway.ParentReference, rtNamespace string, gw *gateway.Gateway) bool {
+	if pref.Group != nil && *pref.Group != gateway.GroupName {
+		return false
+	}
+	if pref.Kind != nil && *pref.Kind != "Gateway" {
+		return false
+	}
+	namespace := rtNamespace
+	if pref.Namespace != nil {
+		namespace = string(*pref.Namespace)
+	}
+	return string(pref.Name) == gw.Name && namespace == gw.Namespace
+}
+
+// parentRefMatchesListener returns true if a parent reference, which refers
+// to the Gateway of a listener, selects the listener
+func parentRefMatchesListener(pref *gateway.ParentReference, l *gateway.Listener) bool {
+	return (pref.SectionName == nil || *pref.SectionName == l.Name) && (pref.Port == nil || *pref.Port == l.Port)
+}
+
+// listenerAllowsNamespace returns true if a listener allows routes from a
+// namespace
+func listenerAllowsNamespace(l *gateway.Listener, gwNamespace, rtNamespace string, rtNamespaceLabels map[string]string) bool {
+	from := gateway.NamespacesFromSame
+	if l.AllowedRoutes != nil && l.AllowedRoutes.Namespaces != nil && l.AllowedRoutes.Namespaces.From != nil {
+		from = *l.AllowedRoutes.Namespaces.From
+	}
+	switch from {
+	case gateway.NamespacesFromAll:
+		return true
+	case gateway.NamespacesFromSame:
+		return gwNamespace == rtNamespace
+	case gateway.NamespacesFromSelector:
+		if l.AllowedRoutes.Namespaces.Selector == nil {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(l.AllowedRoutes.Namespaces.Selector)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(labels.Set(rtNamespaceLabels))
+	}
+	return false
+}
+
+// hostnameMatches returns true if a hostname matches a hostname pattern,
+// which may have a wildcard label prefix matching one or more labels
+func hostnameMatches(pattern, hostname string) bool {
+	pattern = strings.ToLower(pattern)
+	hostname = strings.ToLower(hostname)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(hostname, pattern[1:]) && len(hostname) > len(pattern)-1
+	}
+	return pattern == hostname
+}
+
+// hostnamesIntersect returns true if the hostnames of a route intersect with
+// the hostname of a listener
+func hostnamesIntersect(listenerHostname *gateway.Hostname, routeHostnames []gateway.Hostname) bool {
+	if listenerHostname == nil || *listenerHostname == "" || len(routeHostnames) == 0 {
+		return true
+	}
+	for _, h := range routeHostnames {
+		if hostnameMatches(string(*listenerHostname), string(h)) || hostnameMatches(string(h), string(*listenerHostname)) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachesToListener returns true if a route attaches to a listener of a
+// Gateway
+func attachesToListener(gw *gateway.Gateway, l *gateway.Listener, rt *gateway.HTTPRoute, rtNamespaceLabels map[string]string) bool {
+	if kinds, _ := supportedKinds(l); len(kinds) == 0 {
+		return false
+	}
+	for i := range rt.Spec.ParentRefs {
+		pref := &rt.Spec.ParentRefs[i]
+		if parentRefMatches(pref, rt.Namespace, gw) && parentRefMatchesListener(pref, l) &&
+			listenerAllowsNamespace(l, gw.Namespace, rt.Namespace, rtNamespaceLabels) &&
+			hostnamesIntersect(l.Hostname, rt.Spec.Hostnames) {
+			return true
+		}
+	}
+	return false
+}
+
+// parentAcceptance returns the reason a route is, or is not, accepted by a
+// parent Gateway
+func parentAcceptance(gw *gateway.Gateway, pref *gateway.ParentReference, rt *gateway.HTTPRoute, rtNamespaceLabels map[string]string) gateway.RouteConditionReason {
+	reason := gateway.RouteReasonNoMatchingParent
+	for i := range gw.Spec.Listeners {
+		l := &gw.Spec.Listeners[i]
+		if !parentRefMatchesListener(pref, l) {
+			continue
+		}
+		if !listenerAllowsNamespace(l, gw.Namespace, rt.Namespace, rtNamespaceLabels) {
+			if reason == gateway.RouteReasonNoMatchingParent {
+				reason = gateway.RouteReasonNotAllowedByListeners
+			}
+			continue
+		}
+		if !hostnamesIntersect(l.Hostname, rt.Spec.Hostnames) {
+			reason = gateway.RouteReasonNoMatchingListenerHostname
+			continue
+		}
+		return gateway.RouteReasonAccepted
+	}
+	return reason
+}
+
+// referenceGranted returns true if a ReferenceGrant in the namespace of the
+// referent allows a reference from a kind in the Gateway API group in another
+// namespace
+func referenceGranted(ctx context.Context, c client.Client, fromNamespace string, fromKind gateway.Kind,
+	toNamespace string, toGroup gateway.Group, toKind gateway.Kind, toName string) (bool, error) {
+	var grants gateway.ReferenceGrantList
+	if err := c.List(ctx, &grants, client.InNamespace(toNamespace)); err != nil {
+		return false, err
+	}
+	for i := range grants.Items {
+		spec := &grants.Items[i].Spec
+		fromAllowed := false
+		for _, from := range spec.From {
+			if from.Group == gateway.GroupName && from.Kind == fromKind && string(from.Namespace) == fromNamespace {
+				fromAllowed = true
+			}
+		}
+		if !fromAllowed {
+			continue
+		}
+		for _, to := range spec.To {
+			if to.Group == toGroup && to.Kind == toKind && (to.Name == nil || string(*to.Name) == toName) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// resolveBackend returns the Service referenced by a backend of a route, or
+// the reason the reference cannot be resolved
+func resolveBackend(ctx context.Context, c client.Client, rtNamespace string, ref *gateway.BackendRef) (*corev1.Service, gateway.RouteConditionReason, error) {
+	if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+		return nil, gateway.RouteReasonInvalidKind, nil
+	}
+	namespace := rtNamespace
+	if ref.Namespace != nil {
+		namespace = string(*ref.Namespace)
+	}
+	if namespace != rtNamespace {
+		granted, err := referenceGranted(ctx, c, rtNamespace, "HTTPRoute", namespace, "", "Service", string(ref.Name))
+		if err != nil {
+			return nil, "", err
+		}
+		if !granted {
+			return nil, gateway.RouteReasonRefNotPermitted, nil
+		}
+	}
+	svc := &corev1.Service{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: string(ref.Name)}, svc)
+	if apierrors.IsNotFound(err) {
+		return nil, gateway.RouteReasonBackendNotFound, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	if ref.Port == nil {
+		return nil, gateway.RouteReasonBackendNotFound, nil
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Port == int32(*ref.Port) {
+			return svc, gateway.RouteReasonResolvedRefs, nil
+		}
+	}
+	return nil, gateway.RouteReasonBackendNotFound, nil
+}
@@ -0,0 +1,108 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DeploymentReconciler runs fake Pods for Deployments, such that the echo
+// backends of the conformance suite are ready without a kubelet. Pods are
+// named after the Deployment with an ordinal suffix and are ready right away.
+type DeploymentReconciler struct {
+	client.Client
+}
+
+func (r *DeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deploy); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !deploy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	replicas := 1
+	if deploy.Spec.Replicas != nil {
+		replicas = int(*deploy.Spec.Replicas)
+	}
+
+	for i := 0; i < replicas; i++ {
+		pod := &corev1.Pod{}
+		name := fmt.Sprintf("%s-%d", deploy.Name, i)
+		err := r.Get(ctx, client.ObjectKey{Namespace: deploy.Namespace, Name: name}, pod)
+		if apierrors.IsNotFound(err) {
+			pod = &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: deploy.Namespace,
+					Labels:    deploy.Spec.Template.Labels,
+				},
+				Spec: *deploy.Spec.Template.Spec.DeepCopy(),
+			}
+			if err := ctrl.SetControllerReference(deploy, pod, r.Scheme()); err != nil {
+				return ctrl.Result{}, err
+			}
+			log.Info("create pod", "name", name)
+			if err := r.Create(ctx, pod); err != nil {
+				return ctrl.Result{}, err
+			}
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+		if pod.Status.Phase == corev1.PodRunning {
+			continue
+		}
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{
+				Type:               corev1.PodReady,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.Now(),
+			}},
+		}
+		if err := r.Status().Update(ctx, pod); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *DeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("conformance-deployment").
+		For(&appsv1.Deployment{}).
+		Owns(&corev1.Pod{}).
+		Complete(r)
+}
+
+// servicePods returns the names of the ready Pods selected by a Service,
+// sorted by name
+func servicePods(ctx context.Context, c client.Client, svc *corev1.Service) ([]string, error) {
+	if len(svc.Spec.Selector) == 0 {
+		return nil, nil
+	}
+	var podList corev1.PodList
+	if err := c.List(ctx, &podList, client.InNamespace(svc.Namespace),
+		client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(svc.Spec.Selector)}); err != nil {
+		return nil, err
+	}
+	var pods []string
+	for i := range podList.Items {
+		if podList.Items[i].Status.Phase == corev1.PodRunning && podList.Items[i].DeletionTimestamp.IsZero() {
+			pods = append(pods, podList.Items[i].Name)
+		}
+	}
+	sort.Strings(pods)
+	return pods, nil
+}
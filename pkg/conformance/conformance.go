@@ -0,0 +1,77 @@
+// Package conformance implements a stand-in for a tier-2 Gateway API
+// implementation, such that the upstream Gateway API conformance suite can
+// run against the controller in a test environment without a data plane.
+//
+// The stand-in accepts GatewayClasses with ControllerName, reports status of
+// the shadow Gateways and HTTPRoutes created by the controller, runs fake
+// Pods for Deployments, deletes shadow objects left behind by deleted owners
+// and routes requests of the conformance suite in process according to the
+// shadow HTTPRoutes, see RoundTripper.
+package conformance
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// ControllerName is the controller name of GatewayClasses implemented by the
+// stand-in
+const ControllerName gateway.GatewayController = "cloud-gateway-controller.pixelperfekt.dk/conformance-tier2"
+
+// SetupWithManager adds the reconcilers of the stand-in to a manager
+func SetupWithManager(mgr ctrl.Manager) error {
+	if err := (&GatewayClassReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&GatewayReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&HTTPRouteReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&DeploymentReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&GarbageCollector{
+		Client:    mgr.GetClient(),
+		Name:      "conformance-gc-gateway",
+		NewObject: func() client.Object { return &gateway.Gateway{} },
+		NewList:   func() client.ObjectList { return &gateway.GatewayList{} },
+	}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	return (&GarbageCollector{
+		Client:    mgr.GetClient(),
+		Name:      "conformance-gc-httproute",
+		NewObject: func() client.Object { return &gateway.HTTPRoute{} },
+		NewList:   func() client.ObjectList { return &gateway.HTTPRouteList{} },
+	}).SetupWithManager(mgr)
+}
+
+// implementsClass returns true if a GatewayClass is implemented by the
+// stand-in
+func implementsClass(ctx context.Context, c client.Client, className gateway.ObjectName) (bool, error) {
+	gwc := &gateway.GatewayClass{}
+	if err := c.Get(ctx, client.ObjectKey{Name: string(className)}, gwc); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return gwc.Spec.ControllerName == ControllerName, nil
+}
+
+// condition returns a condition stamped with the generation of an object
+func condition(obj client.Object, conditionType string, status bool, reason string) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		ObservedGeneration: obj.GetGeneration(),
+	}
+	if !status {
+		cond.Status = metav1.ConditionFalse
+	}
+	return cond
+}
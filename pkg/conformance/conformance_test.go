@@ -0,0 +1,356 @@
+//go:build conformance
+
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/gateway-api/conformance/tests"
+	"sigs.k8s.io/gateway-api/conformance/utils/config"
+	"sigs.k8s.io/gateway-api/conformance/utils/suite"
+	"sigs.k8s.io/yaml"
+
+	simulatorv1alpha1 "github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+	controllerconfig "github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/controllers"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/simulator"
+)
+
+var (
+	allFeatures = flag.Bool("all-features", false, "Run the tests of all features, not only the features supported by the controller")
+	reportPath  = flag.String("report", "", "Write a report of the conformance tests to a file")
+	debugLog    = flag.Bool("debug", false, "Log requests of the suite and controller logs")
+)
+
+// Name of the GatewayClass under test and its objects, relative to the
+// package directory
+const gatewayClassName = "cloud-gw-conformance"
+
+var gatewayClassPath = filepath.Join("..", "..", "test-data", "conformance", "gateway-class.yaml")
+
+// Timeouts of the suite. Objects are reconciled in a local API server and
+// requests routed in process, so timeouts are shorter than the defaults to
+// keep runs with failing tests short. MaxTimeToConsistency is the default.
+var timeoutConfig = config.TimeoutConfig{
+	CreateTimeout:                  20 * time.Second,
+	DeleteTimeout:                  10 * time.Second,
+	GetTimeout:                     10 * time.Second,
+	GatewayMustHaveAddress:         30 * time.Second,
+	GatewayStatusMustHaveListeners: 30 * time.Second,
+	GWCMustBeAccepted:              30 * time.Second,
+	HTTPRouteMustNotHaveParents:    30 * time.Second,
+	HTTPRouteMustHaveCondition:     30 * time.Second,
+	HTTPRouteMustHaveParents:       30 * time.Second,
+	MaxTimeToConsistency:           30 * time.Second,
+	NamespacesMustBeReady:          60 * time.Second,
+}
+
+// Results of tests and features in reports
+const (
+	resultPassed  = "Passed"
+	resultFailed  = "Failed"
+	resultSkipped = "Skipped"
+)
+
+// Name of the core features, i.e. tests without features, in reports
+const coreFeature = "Core"
+
+// report is the outcome of a run of the conformance suite
+type report struct {
+	GatewayAPIVersion string          `json:"gatewayAPIVersion"`
+	GatewayClass      string          `json:"gatewayClass"`
+	SupportedFeatures []string        `json:"supportedFeatures"`
+	Features          []featureResult `json:"features"`
+	Tests             []testResult    `json:"tests"`
+}
+
+type featureResult struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+}
+
+type testResult struct {
+	Name     string   `json:"name"`
+	Features []string `json:"features,omitempty"`
+	Result   string   `json:"result"`
+}
+
+// gatewayAPIVersion returns the version of the Gateway API module the suite
+// is built from
+func gatewayAPIVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "sigs.k8s.io/gateway-api" {
+				return dep.Version
+			}
+		}
+	}
+	return "unknown"
+}
+
+// testFeatures returns the features of a test, or the core feature for tests
+// without features
+func testFeatures(test *suite.ConformanceTest) []string {
+	if len(test.Features) == 0 {
+		return []string{coreFeature}
+	}
+	features := make([]string, 0, len(test.Features))
+	for _, f := range test.Features {
+		features = append(features, string(f))
+	}
+	return features
+}
+
+// featureResults summarizes test results by feature. A feature passes if all
+// its tests pass and fails if any fails.
+func featureResults(results []testResult) []featureResult {
+	byName := map[string]*featureResult{}
+	for _, r := range results {
+		features := r.Features
+		if len(features) == 0 {
+			features = []string{coreFeature}
+		}
+		for _, name := range features {
+			f, found := byName[name]
+			if !found {
+				f = &featureResult{Name: name}
+				byName[name] = f
+			}
+			switch r.Result {
+			case resultPassed:
+				f.Passed++
+			case resultFailed:
+				f.Failed++
+			default:
+				f.Skipped++
+			}
+		}
+	}
+
+	features := make([]featureResult, 0, len(byName))
+	for _, f := range byName {
+		switch {
+		case f.Failed > 0:
+			f.Result = resultFailed
+		case f.Passed > 0:
+			f.Result = resultPassed
+		default:
+			f.Result = resultSkipped
+		}
+		features = append(features, *f)
+	}
+	sort.Slice(features, func(i, j int) bool { return features[i].Name < features[j].Name })
+	return features
+}
+
+// applyFile creates the objects of a YAML file
+func applyFile(ctx context.Context, c client.Client, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &obj.Object); err != nil {
+			return err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if err := c.Create(ctx, obj); err != nil {
+			return err
+		}
+	}
+}
+
+// startEnvironment starts a local API server with the controller, the
+// simulator and the tier-2 stand-in, and returns a client and a function
+// stopping it all
+func startEnvironment(t *testing.T) (client.Client, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logOpts := []zap.Opts{zap.WriteTo(io.Discard)}
+	if *debugLog {
+		logOpts = []zap.Opts{zap.WriteTo(os.Stderr), zap.UseDevMode(true)}
+	}
+	ctrl.SetLogger(zap.New(logOpts...))
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "test-data", "gateway-api", "crds"),
+			filepath.Join("..", "..", "charts", "cloud-gateway-controller", "crds"),
+			filepath.Join("..", "..", "test-data", "simulator", "crds"),
+		},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("Cannot start test environment: %v", err)
+	}
+	stop := func() {
+		cancel()
+		if err := testEnv.Stop(); err != nil {
+			t.Errorf("Cannot stop test environment: %v", err)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme, gateway.AddToScheme, simulatorv1alpha1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			stop()
+			t.Fatalf("Cannot setup scheme: %v", err)
+		}
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err == nil {
+		err = applyFile(ctx, c, gatewayClassPath)
+	}
+	if err != nil {
+		stop()
+		t.Fatalf("Cannot create GatewayClasses: %v", err)
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme, MetricsBindAddress: "0"})
+	if err == nil {
+		err = setupControllers(ctx, mgr)
+	}
+	if err != nil {
+		stop()
+		t.Fatalf("Cannot setup controllers: %v", err)
+	}
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Errorf("Cannot run manager: %v", err)
+		}
+	}()
+	return c, stop
+}
+
+func setupControllers(ctx context.Context, mgr ctrl.Manager) error {
+	cfg := controllerconfig.New()
+	cfg.Complete()
+	if err := controllers.SetupIndexes(ctx, mgr); err != nil {
+		return err
+	}
+	if err := controllers.NewGatewayClassController(mgr, cfg).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := controllers.NewGatewayController(mgr, cfg, nil).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := controllers.NewHTTPRouteController(mgr, cfg, nil).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := simulator.SetupWithManager(mgr, simulator.Options{}); err != nil {
+		return err
+	}
+	return SetupWithManager(mgr)
+}
+
+// TestConformance runs the Gateway API conformance suite against the
+// controller with the tier-2 stand-in. Tests of features in
+// controllers.SupportedFeatures are run, or of all features with
+// -all-features, and the results are summarized by feature.
+func TestConformance(t *testing.T) {
+	c, stop := startEnvironment(t)
+	defer stop()
+
+	claimed := map[string]bool{}
+	features := map[suite.SupportedFeature]bool{}
+	for _, f := range controllers.SupportedFeatures {
+		claimed[f] = true
+		features[suite.SupportedFeature(f)] = true
+	}
+	if *allFeatures {
+		for i := range tests.ConformanceTests {
+			for _, f := range tests.ConformanceTests[i].Features {
+				features[f] = true
+			}
+		}
+	}
+
+	cSuite := suite.New(suite.Options{
+		Client:            c,
+		GatewayClassName:  gatewayClassName,
+		Debug:             *debugLog,
+		RoundTripper:      &RoundTripper{Client: c},
+		SupportedFeatures: features,
+		TimeoutConfig:     timeoutConfig,
+	})
+	cSuite.Setup(t)
+
+	results := make([]testResult, len(tests.ConformanceTests))
+	t.Run("tests", func(t *testing.T) {
+		for i := range tests.ConformanceTests {
+			i, test := i, tests.ConformanceTests[i]
+			results[i] = testResult{Name: test.ShortName, Features: testFeatures(&test)}
+			t.Run(test.ShortName, func(t *testing.T) {
+				// Cleanup runs after parallel subtests complete
+				t.Cleanup(func() {
+					switch {
+					case t.Skipped():
+						results[i].Result = resultSkipped
+					case t.Failed():
+						results[i].Result = resultFailed
+					default:
+						results[i].Result = resultPassed
+					}
+				})
+				test.Run(t, cSuite)
+			})
+		}
+	})
+
+	r := report{
+		GatewayAPIVersion: gatewayAPIVersion(),
+		GatewayClass:      gatewayClassName,
+		SupportedFeatures: controllers.SupportedFeatures,
+		Features:          featureResults(results),
+		Tests:             results,
+	}
+	for _, f := range r.Features {
+		t.Logf("%-40s %-8s passed %d, failed %d, skipped %d", f.Name, f.Result, f.Passed, f.Failed, f.Skipped)
+		if f.Result == resultPassed && f.Name != coreFeature && !claimed[f.Name] {
+			t.Logf("Feature %s passes but is not in controllers.SupportedFeatures", f.Name)
+		}
+	}
+	if *reportPath != "" {
+		data, err := yaml.Marshal(&r)
+		if err == nil {
+			err = os.WriteFile(*reportPath, data, 0o644)
+		}
+		if err != nil {
+			t.Errorf("Cannot write report: %v", err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package conformance
+
+import (
+	"context"
+	"crypto/tls"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// GatewayClassReconciler accepts GatewayClasses implemented by the stand-in
+type GatewayClassReconciler struct {
+	client.Client
+}
+
+func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	gwc := &gateway.GatewayClass{}
+	if err := r.Get(ctx, req.NamespacedName, gwc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if gwc.Spec.ControllerName != ControllerName {
+		return ctrl.Result{}, nil
+	}
+
+	status := gwc.Status.DeepCopy()
+	meta.SetStatusCondition(&status.Conditions, condition(gwc, string(gateway.GatewayClassConditionStatusAccepted),
+		true, string(gateway.GatewayClassReasonAccepted)))
+	if equality.Semantic.DeepEqual(status, &gwc.Status) {
+		return ctrl.Result{}, nil
+	}
+	gwc.Status = *status
+	return ctrl.Result{}, r.Status().Update(ctx, gwc)
+}
+
+func (r *GatewayClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("conformance-gatewayclass").
+		For(&gateway.GatewayClass{}).
+		Complete(r)
+}
+
+// GatewayReconciler reports the status of Gateways of classes implemented by
+// the stand-in, i.e. the shadow Gateways created by the controller. All
+// Gateways are programmed, and listeners report the routes attached and
+// whether route kinds and certificates are resolved.
+type GatewayReconciler struct {
+	client.Client
+}
+
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	gw := &gateway.Gateway{}
+	if err := r.Get(ctx, req.NamespacedName, gw); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	implemented, err := implementsClass(ctx, r.Client, gw.Spec.GatewayClassName)
+	if err != nil || !implemented {
+		return ctrl.Result{}, err
+	}
+
+	var rtList gateway.HTTPRouteList
+	if err := r.List(ctx, &rtList); err != nil {
+		return ctrl.Result{}, err
+	}
+	nsLabels, err := namespaceLabels(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := gw.Status.DeepCopy()
+	meta.SetStatusCondition(&status.Conditions, condition(gw, string(gateway.GatewayConditionAccepted),
+		true, string(gateway.GatewayReasonAccepted)))
+	meta.SetStatusCondition(&status.Conditions, condition(gw, string(gateway.GatewayConditionProgrammed),
+		true, string(gateway.GatewayReasonProgrammed)))
+
+	listeners := make([]gateway.ListenerStatus, 0, len(gw.Spec.Listeners))
+	for i := range gw.Spec.Listeners {
+		l := &gw.Spec.Listeners[i]
+		ls := gateway.ListenerStatus{Name: l.Name}
+		for j := range status.Listeners {
+			if status.Listeners[j].Name == l.Name {
+				ls.Conditions = status.Listeners[j].Conditions
+			}
+		}
+
+		kinds, kindsValid := supportedKinds(l)
+		ls.SupportedKinds = kinds
+		for j := range rtList.Items {
+			rt := &rtList.Items[j]
+			if attachesToListener(gw, l, rt, nsLabels[rt.Namespace]) {
+				ls.AttachedRoutes++
+			}
+		}
+
+		resolved := condition(gw, string(gateway.ListenerConditionResolvedRefs), true, string(gateway.ListenerReasonResolvedRefs))
+		if !kindsValid {
+			resolved = condition(gw, string(gateway.ListenerConditionResolvedRefs), false, string(gateway.ListenerReasonInvalidRouteKinds))
+		} else if reason, err := r.certificateRefsReason(ctx, gw, l); err != nil {
+			return ctrl.Result{}, err
+		} else if reason != "" {
+			resolved = condition(gw, string(gateway.ListenerConditionResolvedRefs), false, string(reason))
+		}
+		programmed := condition(gw, string(gateway.ListenerConditionProgrammed), true, string(gateway.ListenerReasonProgrammed))
+		if resolved.Status != "True" {
+			programmed = condition(gw, string(gateway.ListenerConditionProgrammed), false, string(gateway.ListenerReasonInvalid))
+		}
+		meta.SetStatusCondition(&ls.Conditions, condition(gw, string(gateway.ListenerConditionAccepted), true, string(gateway.ListenerReasonAccepted)))
+		meta.SetStatusCondition(&ls.Conditions, resolved)
+		meta.SetStatusCondition(&ls.Conditions, programmed)
+		listeners = append(listeners, ls)
+	}
+	status.Listeners = listeners
+
+	if equality.Semantic.DeepEqual(status, &gw.Status) {
+		return ctrl.Result{}, nil
+	}
+	log.V(1).Info("update gateway status")
+	gw.Status = *status
+	return ctrl.Result{}, r.Status().Update(ctx, gw)
+}
+
+// supportedKinds returns the route kinds of a listener supported by the
+// stand-in and whether all kinds allowed by the listener are supported
+func supportedKinds(l *gateway.Listener) ([]gateway.RouteGroupKind, bool) {
+	httpRoute := gateway.RouteGroupKind{Group: (*gateway.Group)(&gateway.GroupVersion.Group), Kind: "HTTPRoute"}
+	if l.Protocol != gateway.HTTPProtocolType && l.Protocol != gateway.HTTPSProtocolType {
+		return []gateway.RouteGroupKind{}, false
+	}
+	if l.AllowedRoutes == nil || len(l.AllowedRoutes.Kinds) == 0 {
+		return []gateway.RouteGroupKind{httpRoute}, true
+	}
+	kinds := []gateway.RouteGroupKind{}
+	valid := true
+	for _, k := range l.AllowedRoutes.Kinds {
+		if k.Kind == httpRoute.Kind && (k.Group == nil || *k.Group == *httpRoute.Group) {
+			kinds = append(kinds, httpRoute)
+		} else {
+			valid = false
+		}
+	}
+	return kinds, valid
+}
+
+// certificateRefsReason returns the reason the certificates of a listener are
+// not resolved, or an empty reason if they are
+func (r *GatewayReconciler) certificateRefsReason(ctx context.Context, gw *gateway.Gateway, l *gateway.Listener) (gateway.ListenerConditionReason, error) {
+	if l.TLS == nil {
+		return "", nil
+	}
+	for _, ref := range l.TLS.CertificateRefs {
+		if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Secret") {
+			return gateway.ListenerReasonInvalidCertificateRef, nil
+		}
+		namespace := gw.Namespace
+		if ref.Namespace != nil {
+			namespace = string(*ref.Namespace)
+		}
+		if namespace != gw.Namespace {
+			granted, err := referenceGranted(ctx, r.Client, gw.Namespace, "Gateway", namespace, "", "Secret", string(ref.Name))
+			if err != nil {
+				return "", err
+			}
+			if !granted {
+				return gateway.ListenerReasonRefNotPermitted, nil
+			}
+		}
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: string(ref.Name)}, secret)
+		if apierrors.IsNotFound(err) {
+			return gateway.ListenerReasonInvalidCertificateRef, nil
+		} else if err != nil {
+			return "", err
+		}
+		if secret.Type != corev1.SecretTypeTLS {
+			return gateway.ListenerReasonInvalidCertificateRef, nil
+		}
+		if _, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]); err != nil {
+			return gateway.ListenerReasonInvalidCertificateRef, nil
+		}
+	}
+	return "", nil
+}
+
+// allGatewayRequests maps any object to requests for all Gateways, e.g. such
+// that listener status follows routes, Secrets and ReferenceGrants
+func (r *GatewayReconciler) allGatewayRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var gwList gateway.GatewayList
+	if err := r.List(ctx, &gwList); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Gateways")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(gwList.Items))
+	for i := range gwList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gwList.Items[i])})
+	}
+	return requests
+}
+
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("conformance-gateway").
+		For(&gateway.Gateway{}).
+		Watches(&source.Kind{Type: &gateway.GatewayClass{}},
+			handler.EnqueueRequestsFromMapFunc(r.allGatewayRequests)).
+		Watches(&source.Kind{Type: &gateway.HTTPRoute{}},
+			handler.EnqueueRequestsFromMapFunc(r.allGatewayRequests)).
+		Watches(&source.Kind{Type: &gateway.ReferenceGrant{}},
+			handler.EnqueueRequestsFromMapFunc(r.allGatewayRequests)).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.allGatewayRequests)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}},
+			handler.EnqueueRequestsFromMapFunc(r.allGatewayRequests)).
+		Complete(r)
+}
@@ -0,0 +1,86 @@
+package conformance
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// GarbageCollector deletes objects of a kind whose controller owner is gone,
+// as the garbage collector of a cluster would. The test environment has no
+// garbage collector, and shadow Gateways and HTTPRoutes left behind by one
+// test would otherwise be routed to by the following tests. Owners are
+// expected to be of the same kind as their dependents.
+type GarbageCollector struct {
+	client.Client
+
+	// Name of the reconciler
+	Name string
+
+	// NewObject and NewList return an empty object and list of the kind
+	// collected
+	NewObject func() client.Object
+	NewList   func() client.ObjectList
+}
+
+func (r *GarbageCollector) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	obj := r.NewObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil || !obj.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	owner := &unstructured.Unstructured{}
+	owner.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	err := r.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: ref.Name}, owner)
+	if err == nil && owner.GetUID() == ref.UID {
+		return ctrl.Result{}, nil
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	log.FromContext(ctx).Info("delete object without owner", "owner", ref.Name)
+	return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+// dependentRequests maps an owner to requests for the objects it controls
+func (r *GarbageCollector) dependentRequests(owner client.Object) []reconcile.Request {
+	ctx := context.Background()
+	list := r.NewList()
+	if err := r.List(ctx, list, client.InNamespace(owner.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list dependents", "owner", owner.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	_ = meta.EachListItem(list, func(o runtime.Object) error {
+		obj := o.(client.Object)
+		if ref := metav1.GetControllerOf(obj); ref != nil && ref.UID == owner.GetUID() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		}
+		return nil
+	})
+	return requests
+}
+
+func (r *GarbageCollector) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(r.Name).
+		For(r.NewObject()).
+		Watches(&source.Kind{Type: r.NewObject()},
+			handler.EnqueueRequestsFromMapFunc(r.dependentRequests)).
+		Complete(r)
+}
@@ -0,0 +1,127 @@
+package conformance
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// HTTPRouteReconciler reports the status of HTTPRoutes for parent Gateways of
+// classes implemented by the stand-in, i.e. the status of shadow HTTPRoutes
+// created by the controller.
+type HTTPRouteReconciler struct {
+	client.Client
+}
+
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	rt := &gateway.HTTPRoute{}
+	if err := r.Get(ctx, req.NamespacedName, rt); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	nsLabels, err := namespaceLabels(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Backends are resolved once for all parents
+	resolved := condition(rt, string(gateway.RouteConditionResolvedRefs), true, string(gateway.RouteReasonResolvedRefs))
+	for i := range rt.Spec.Rules {
+		for j := range rt.Spec.Rules[i].BackendRefs {
+			_, reason, err := resolveBackend(ctx, r.Client, rt.Namespace, &rt.Spec.Rules[i].BackendRefs[j].BackendRef)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if reason != gateway.RouteReasonResolvedRefs && resolved.Status == "True" {
+				resolved = condition(rt, string(gateway.RouteConditionResolvedRefs), false, string(reason))
+			}
+		}
+	}
+
+	var parents []gateway.RouteParentStatus
+	for i := range rt.Status.Parents {
+		if rt.Status.Parents[i].ControllerName != ControllerName {
+			parents = append(parents, rt.Status.Parents[i])
+		}
+	}
+	for i := range rt.Spec.ParentRefs {
+		pref := &rt.Spec.ParentRefs[i]
+		if (pref.Group != nil && *pref.Group != gateway.GroupName) || (pref.Kind != nil && *pref.Kind != "Gateway") {
+			continue
+		}
+		namespace := rt.Namespace
+		if pref.Namespace != nil {
+			namespace = string(*pref.Namespace)
+		}
+		gw := &gateway.Gateway{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: string(pref.Name)}, gw); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+		implemented, err := implementsClass(ctx, r.Client, gw.Spec.GatewayClassName)
+		if err != nil {
+			return ctrl.Result{}, err
+		} else if !implemented {
+			continue
+		}
+
+		status := gateway.RouteParentStatus{ParentRef: *pref, ControllerName: ControllerName}
+		for j := range rt.Status.Parents {
+			existing := &rt.Status.Parents[j]
+			if existing.ControllerName == ControllerName && equality.Semantic.DeepEqual(existing.ParentRef, *pref) {
+				status.Conditions = existing.Conditions
+			}
+		}
+		reason := parentAcceptance(gw, pref, rt, nsLabels[rt.Namespace])
+		meta.SetStatusCondition(&status.Conditions, condition(rt, string(gateway.RouteConditionAccepted),
+			reason == gateway.RouteReasonAccepted, string(reason)))
+		meta.SetStatusCondition(&status.Conditions, resolved)
+		parents = append(parents, status)
+	}
+
+	if equality.Semantic.DeepEqual(parents, rt.Status.Parents) || (len(parents) == 0 && len(rt.Status.Parents) == 0) {
+		return ctrl.Result{}, nil
+	}
+	log.FromContext(ctx).V(1).Info("update httproute status")
+	rt.Status.Parents = parents
+	return ctrl.Result{}, r.Status().Update(ctx, rt)
+}
+
+// allHTTPRouteRequests maps any object to requests for all HTTPRoutes, e.g.
+// such that route status follows Gateways, Services and ReferenceGrants
+func (r *HTTPRouteReconciler) allHTTPRouteRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var rtList gateway.HTTPRouteList
+	if err := r.List(ctx, &rtList); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list HTTPRoutes")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(rtList.Items))
+	for i := range rtList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&rtList.Items[i])})
+	}
+	return requests
+}
+
+func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("conformance-httproute").
+		For(&gateway.HTTPRoute{}).
+		Watches(&source.Kind{Type: &gateway.Gateway{}},
+			handler.EnqueueRequestsFromMapFunc(r.allHTTPRouteRequests)).
+		Watches(&source.Kind{Type: &gateway.ReferenceGrant{}},
+			handler.EnqueueRequestsFromMapFunc(r.allHTTPRouteRequests)).
+		Watches(&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.allHTTPRouteRequests)).
+		Complete(r)
+}
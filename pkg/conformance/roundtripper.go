@@ -0,0 +1,291 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/gateway-api/conformance/utils/roundtripper"
+)
+
+// Timeout of looking up the objects needed to route a request
+const requestTimeout = 10 * time.Second
+
+// RoundTripper routes requests of the conformance suite in process, as the
+// data plane of a tier-2 implementation would. The Gateway is found by the
+// address requested among the addresses reported by Gateways, and requests
+// are routed according to the HTTPRoutes attached to the shadow Gateway
+// controlled by it. Backends respond like the echo server of the conformance
+// suite, i.e. with the request received and the Pod receiving it.
+type RoundTripper struct {
+	Client client.Client
+
+	// Counter of requests, used to distribute requests over weighted
+	// backends and Pods
+	requests uint64
+}
+
+// response returns a captured response without a request reaching a backend
+func response(statusCode int) (*roundtripper.CapturedRequest, *roundtripper.CapturedResponse, error) {
+	return &roundtripper.CapturedRequest{}, &roundtripper.CapturedResponse{
+		StatusCode: statusCode,
+		Protocol:   "HTTP/1.1",
+		Headers:    map[string][]string{},
+	}, nil
+}
+
+func (r *RoundTripper) CaptureRoundTrip(req roundtripper.Request) (*roundtripper.CapturedRequest, *roundtripper.CapturedResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	n := atomic.AddUint64(&r.requests, 1)
+
+	address, portStr, err := net.SplitHostPort(req.URL.Host)
+	if err != nil {
+		address, portStr = req.URL.Host, "80"
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid port in %q: %w", req.URL.Host, err)
+	}
+	shadow, err := r.shadowGateway(ctx, address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host := req.Host
+	if host == "" {
+		host = address
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	l := listenerForRequest(shadow, gateway.PortNumber(port), host)
+	if l == nil {
+		return response(http.StatusNotFound)
+	}
+
+	var rtList gateway.HTTPRouteList
+	if err := r.Client.List(ctx, &rtList); err != nil {
+		return nil, nil, err
+	}
+	nsLabels, err := namespaceLabels(ctx, r.Client)
+	if err != nil {
+		return nil, nil, err
+	}
+	var routes []*gateway.HTTPRoute
+	for i := range rtList.Items {
+		if attachesToListener(shadow, l, &rtList.Items[i], nsLabels[rtList.Items[i].Namespace]) {
+			routes = append(routes, &rtList.Items[i])
+		}
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	in := &request{host: host, method: method, path: path, query: req.URL.Query(), headers: http.Header{}}
+	for name, values := range req.Headers {
+		for _, v := range values {
+			in.headers.Add(name, v)
+		}
+	}
+
+	m := routeRequest(l.Hostname, routes, in)
+	if m == nil {
+		return response(http.StatusNotFound)
+	}
+
+	var responseFilters []*gateway.HTTPHeaderFilter
+	for i := range m.rule.Filters {
+		f := &m.rule.Filters[i]
+		switch f.Type {
+		case gateway.HTTPRouteFilterRequestHeaderModifier:
+			modifyHeaders(in.headers, f.RequestHeaderModifier)
+		case gateway.HTTPRouteFilterResponseHeaderModifier:
+			responseFilters = append(responseFilters, f.ResponseHeaderModifier)
+		case gateway.HTTPRouteFilterRequestRedirect:
+			location, statusCode := redirectLocation(f.RequestRedirect, in, l.Port)
+			cReq, cRes, err := response(statusCode)
+			cRes.Headers["Location"] = []string{location.String()}
+			cRes.RedirectRequest = &roundtripper.RedirectRequest{
+				Scheme:   location.Scheme,
+				Hostname: location.Hostname(),
+				Port:     location.Port(),
+			}
+			return cReq, cRes, err
+		case gateway.HTTPRouteFilterRequestMirror:
+			// Mirrored requests are not observable by the suite
+		default:
+			return response(http.StatusInternalServerError)
+		}
+	}
+
+	backend := weightedBackend(m.rule.BackendRefs, n)
+	if backend == nil {
+		return response(http.StatusInternalServerError)
+	}
+	svc, _, err := resolveBackend(ctx, r.Client, m.route.Namespace, &backend.BackendRef)
+	if err != nil {
+		return nil, nil, err
+	} else if svc == nil {
+		return response(http.StatusInternalServerError)
+	}
+	for i := range backend.Filters {
+		f := &backend.Filters[i]
+		switch f.Type {
+		case gateway.HTTPRouteFilterRequestHeaderModifier:
+			modifyHeaders(in.headers, f.RequestHeaderModifier)
+		case gateway.HTTPRouteFilterResponseHeaderModifier:
+			responseFilters = append(responseFilters, f.ResponseHeaderModifier)
+		default:
+			return response(http.StatusInternalServerError)
+		}
+	}
+	pods, err := servicePods(ctx, r.Client, svc)
+	if err != nil {
+		return nil, nil, err
+	} else if len(pods) == 0 {
+		return response(http.StatusServiceUnavailable)
+	}
+
+	// Echo server, which sets response headers requested by the client
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	for _, h := range strings.Split(in.headers.Get("X-Echo-Set-Header"), ",") {
+		if name, value, found := strings.Cut(h, ":"); found {
+			headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+	}
+	for _, f := range responseFilters {
+		modifyHeaders(headers, f)
+	}
+	requestURI := in.path
+	if req.URL.RawQuery != "" {
+		requestURI += "?" + req.URL.RawQuery
+	}
+	return &roundtripper.CapturedRequest{
+		Path:      requestURI,
+		Host:      in.host,
+		Method:    in.method,
+		Protocol:  "HTTP/1.1",
+		Headers:   in.headers,
+		Namespace: svc.Namespace,
+		Pod:       pods[n%uint64(len(pods))],
+	}, &roundtripper.CapturedResponse{
+		StatusCode: http.StatusOK,
+		Protocol:   "HTTP/1.1",
+		Headers:    headers,
+	}, nil
+}
+
+// shadowGateway returns the shadow Gateway implemented by the stand-in for
+// the Gateway with an address, or an error like a failed connection if there
+// is none
+func (r *RoundTripper) shadowGateway(ctx context.Context, address string) (*gateway.Gateway, error) {
+	var gwList gateway.GatewayList
+	if err := r.Client.List(ctx, &gwList); err != nil {
+		return nil, err
+	}
+	for i := range gwList.Items {
+		gw := &gwList.Items[i]
+		hasAddress := false
+		for _, a := range gw.Status.Addresses {
+			hasAddress = hasAddress || a.Value == address
+		}
+		if !hasAddress {
+			continue
+		}
+		for j := range gwList.Items {
+			shadow := &gwList.Items[j]
+			if !metav1.IsControlledBy(shadow, gw) {
+				continue
+			}
+			implemented, err := implementsClass(ctx, r.Client, shadow.Spec.GatewayClassName)
+			if err != nil {
+				return nil, err
+			}
+			if implemented {
+				return shadow, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("connection to %s refused, no Gateway with a shadow Gateway has the address", address)
+}
+
+// listenerForRequest returns the programmed listener of a Gateway on a port
+// with the most specific hostname matching a request, or nil if there is
+// none
+func listenerForRequest(gw *gateway.Gateway, port gateway.PortNumber, host string) *gateway.Listener {
+	var best *gateway.Listener
+	bestLength, bestExact := -1, false
+	for i := range gw.Spec.Listeners {
+		l := &gw.Spec.Listeners[i]
+		if l.Port != port || !listenerProgrammed(gw, l.Name) {
+			continue
+		}
+		length, exact := 0, false
+		if l.Hostname != nil && *l.Hostname != "" {
+			if !hostnameMatches(string(*l.Hostname), host) {
+				continue
+			}
+			length, exact = len(*l.Hostname), !strings.HasPrefix(string(*l.Hostname), "*.")
+		}
+		if (exact && !bestExact) || (exact == bestExact && length > bestLength) {
+			best, bestLength, bestExact = l, length, exact
+		}
+	}
+	return best
+}
+
+// listenerProgrammed returns true if the status of a listener reports it
+// programmed
+func listenerProgrammed(gw *gateway.Gateway, name gateway.SectionName) bool {
+	for i := range gw.Status.Listeners {
+		if gw.Status.Listeners[i].Name == name {
+			return meta.IsStatusConditionTrue(gw.Status.Listeners[i].Conditions, string(gateway.ListenerConditionProgrammed))
+		}
+	}
+	return false
+}
+
+// weightedBackend returns the backend of the n'th request distributed over
+// backends according to their weights, or nil if there are no backends with
+// a weight
+func weightedBackend(backends []gateway.HTTPBackendRef, n uint64) *gateway.HTTPBackendRef {
+	var total uint64
+	for i := range backends {
+		total += uint64(backendWeight(&backends[i]))
+	}
+	if total == 0 {
+		return nil
+	}
+	n = n % total
+	for i := range backends {
+		weight := uint64(backendWeight(&backends[i]))
+		if n < weight {
+			return &backends[i]
+		}
+		n -= weight
+	}
+	return nil
+}
+
+func backendWeight(b *gateway.HTTPBackendRef) int32 {
+	if b.Weight == nil {
+		return 1
+	}
+	return *b.Weight
+}
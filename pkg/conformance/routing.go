@@ -0,0 +1,239 @@
+package conformance
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// request is an HTTP request received by a listener of the stand-in
+type request struct {
+	host    string
+	method  string
+	path    string
+	query   url.Values
+	headers http.Header
+}
+
+// ruleMatch is a rule of a route matching a request, with the properties
+// deciding precedence between matches
+type ruleMatch struct {
+	route *gateway.HTTPRoute
+	rule  *gateway.HTTPRouteRule
+
+	// Characters of the hostname matching the request, and whether it is
+	// an exact match
+	hostnameLength int
+	hostnameExact  bool
+
+	// Index of the rule in the route
+	ruleIndex int
+
+	match gateway.HTTPRouteMatch
+}
+
+// routeHostname returns whether the hostname of a request matches the
+// hostnames of a route attached to a listener, with the length of the most
+// specific route hostname matching and whether it is an exact match.
+func routeHostname(listenerHostname *gateway.Hostname, routeHostnames []gateway.Hostname, host string) (int, bool, bool) {
+	if listenerHostname != nil && *listenerHostname != "" && !hostnameMatches(string(*listenerHostname), host) {
+		return 0, false, false
+	}
+	length, exact, matched := 0, false, len(routeHostnames) == 0
+	for _, h := range routeHostnames {
+		if !hostnameMatches(string(h), host) {
+			continue
+		}
+		hExact := !strings.HasPrefix(string(h), "*.")
+		if !matched || (hExact && !exact) || (hExact == exact && len(h) > length) {
+			length, exact = len(h), hExact
+		}
+		matched = true
+	}
+	return length, exact, matched
+}
+
+// pathMatches returns true if the path of a request matches a path match
+func pathMatches(m *gateway.HTTPPathMatch, path string) bool {
+	if m == nil || m.Value == nil {
+		return true
+	}
+	value := *m.Value
+	matchType := gateway.PathMatchPathPrefix
+	if m.Type != nil {
+		matchType = *m.Type
+	}
+	switch matchType {
+	case gateway.PathMatchExact:
+		return path == value
+	case gateway.PathMatchPathPrefix:
+		// Prefixes match path elements, trailing slashes are ignored
+		prefix := strings.TrimSuffix(value, "/")
+		return path == prefix || strings.HasPrefix(path, prefix+"/") || prefix == ""
+	case gateway.PathMatchRegularExpression:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		return err == nil && re.MatchString(path)
+	}
+	return false
+}
+
+// valueMatches returns true if a header or query parameter value matches
+func valueMatches(matchType *string, expected, actual string) bool {
+	if matchType != nil && *matchType == "RegularExpression" {
+		re, err := regexp.Compile(expected)
+		return err == nil && re.MatchString(actual)
+	}
+	return expected == actual
+}
+
+// requestMatches returns true if a request matches all conditions of a match
+func requestMatches(m *gateway.HTTPRouteMatch, req *request) bool {
+	if !pathMatches(m.Path, req.path) {
+		return false
+	}
+	if m.Method != nil && string(*m.Method) != req.method {
+		return false
+	}
+	for _, h := range m.Headers {
+		values, found := req.headers[http.CanonicalHeaderKey(string(h.Name))]
+		if !found || !valueMatches((*string)(h.Type), h.Value, strings.Join(values, ",")) {
+			return false
+		}
+	}
+	for _, q := range m.QueryParams {
+		values, found := req.query[q.Name]
+		if !found || !valueMatches((*string)(q.Type), q.Value, values[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPrecedes returns true if a match takes precedence over another match.
+// Exact paths take precedence over longer prefixes, followed by methods,
+// headers and query parameters. Ties between routes are broken by age and
+// name, and ties within a route by rule order.
+func matchPrecedes(a, b *ruleMatch) bool {
+	if a.hostnameExact != b.hostnameExact {
+		return a.hostnameExact
+	}
+	if a.hostnameLength != b.hostnameLength {
+		return a.hostnameLength > b.hostnameLength
+	}
+	aExact, bExact := pathExact(&a.match), pathExact(&b.match)
+	if aExact != bExact {
+		return aExact
+	}
+	if aLen, bLen := pathLength(&a.match), pathLength(&b.match); aLen != bLen {
+		return aLen > bLen
+	}
+	if (a.match.Method != nil) != (b.match.Method != nil) {
+		return a.match.Method != nil
+	}
+	if len(a.match.Headers) != len(b.match.Headers) {
+		return len(a.match.Headers) > len(b.match.Headers)
+	}
+	if len(a.match.QueryParams) != len(b.match.QueryParams) {
+		return len(a.match.QueryParams) > len(b.match.QueryParams)
+	}
+	if a.route != b.route {
+		if !a.route.CreationTimestamp.Equal(&b.route.CreationTimestamp) {
+			return a.route.CreationTimestamp.Before(&b.route.CreationTimestamp)
+		}
+		return a.route.Namespace+"/"+a.route.Name < b.route.Namespace+"/"+b.route.Name
+	}
+	return a.ruleIndex < b.ruleIndex
+}
+
+func pathExact(m *gateway.HTTPRouteMatch) bool {
+	return m.Path != nil && m.Path.Type != nil && *m.Path.Type == gateway.PathMatchExact
+}
+
+// pathLength returns the length of the path of a match, where paths default
+// to prefix "/"
+func pathLength(m *gateway.HTTPRouteMatch) int {
+	if m.Path == nil || m.Path.Value == nil {
+		return 1
+	}
+	return len(*m.Path.Value)
+}
+
+// routeRequest returns the rule of the given routes, attached to a listener
+// with the given hostname, that takes precedence for a request, or nil if no
+// rule matches
+func routeRequest(listenerHostname *gateway.Hostname, routes []*gateway.HTTPRoute, req *request) *ruleMatch {
+	var matches []*ruleMatch
+	for _, rt := range routes {
+		length, exact, matched := routeHostname(listenerHostname, rt.Spec.Hostnames, req.host)
+		if !matched {
+			continue
+		}
+		for i := range rt.Spec.Rules {
+			rule := &rt.Spec.Rules[i]
+			ruleMatches := rule.Matches
+			if len(ruleMatches) == 0 {
+				ruleMatches = []gateway.HTTPRouteMatch{{}}
+			}
+			for _, m := range ruleMatches {
+				if requestMatches(&m, req) {
+					matches = append(matches, &ruleMatch{route: rt, rule: rule, hostnameLength: length,
+						hostnameExact: exact, ruleIndex: i, match: m})
+				}
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matchPrecedes(matches[i], matches[j]) })
+	return matches[0]
+}
+
+// modifyHeaders applies a header modifier filter to headers
+func modifyHeaders(headers http.Header, f *gateway.HTTPHeaderFilter) {
+	if f == nil {
+		return
+	}
+	for _, h := range f.Set {
+		headers.Set(string(h.Name), h.Value)
+	}
+	for _, h := range f.Add {
+		headers.Add(string(h.Name), h.Value)
+	}
+	for _, name := range f.Remove {
+		headers.Del(name)
+	}
+}
+
+// redirectLocation returns the location and status code of a redirect of a
+// request received on a listener port
+func redirectLocation(f *gateway.HTTPRequestRedirectFilter, req *request, listenerPort gateway.PortNumber) (*url.URL, int) {
+	location := &url.URL{Scheme: "http", Host: req.host, Path: req.path, RawQuery: req.query.Encode()}
+	port := int(listenerPort)
+	if f.Scheme != nil {
+		location.Scheme = *f.Scheme
+		port = 80
+		if location.Scheme == "https" {
+			port = 443
+		}
+	}
+	if f.Hostname != nil {
+		location.Host = string(*f.Hostname)
+	}
+	if f.Port != nil {
+		port = int(*f.Port)
+	}
+	if !(location.Scheme == "http" && port == 80) && !(location.Scheme == "https" && port == 443) {
+		location.Host = location.Host + ":" + strconv.Itoa(port)
+	}
+	statusCode := http.StatusFound
+	if f.StatusCode != nil {
+		statusCode = *f.StatusCode
+	}
+	return location, statusCode
+}
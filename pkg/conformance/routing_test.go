@@ -0,0 +1,226 @@
+package conformance
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func pathMatch(matchType gateway.PathMatchType, value string) *gateway.HTTPPathMatch {
+	return &gateway.HTTPPathMatch{Type: &matchType, Value: &value}
+}
+
+func TestPathMatches(t *testing.T) {
+	testCases := []struct {
+		match  *gateway.HTTPPathMatch
+		path   string
+		expect bool
+	}{
+		{nil, "/foo", true},
+		{pathMatch(gateway.PathMatchPathPrefix, "/"), "/foo", true},
+		{pathMatch(gateway.PathMatchPathPrefix, "/foo"), "/foo", true},
+		{pathMatch(gateway.PathMatchPathPrefix, "/foo"), "/foo/bar", true},
+		{pathMatch(gateway.PathMatchPathPrefix, "/foo/"), "/foo", true},
+		{pathMatch(gateway.PathMatchPathPrefix, "/foo"), "/foobar", false},
+		{pathMatch(gateway.PathMatchExact, "/foo"), "/foo", true},
+		{pathMatch(gateway.PathMatchExact, "/foo"), "/foo/", false},
+		{pathMatch(gateway.PathMatchRegularExpression, "/v[0-9]+/.*"), "/v2/foo", true},
+		{pathMatch(gateway.PathMatchRegularExpression, "/v[0-9]+"), "/v2/foo", false},
+	}
+	for _, tc := range testCases {
+		if got := pathMatches(tc.match, tc.path); got != tc.expect {
+			t.Errorf("Path %q match %v: expected %v, got %v", tc.path, tc.match, tc.expect, got)
+		}
+	}
+}
+
+func TestHostnameMatches(t *testing.T) {
+	testCases := []struct {
+		pattern, hostname string
+		expect            bool
+	}{
+		{"foo.example.com", "foo.example.com", true},
+		{"foo.example.com", "FOO.example.com", true},
+		{"foo.example.com", "bar.example.com", false},
+		{"*.example.com", "foo.example.com", true},
+		{"*.example.com", "foo.bar.example.com", true},
+		{"*.example.com", "example.com", false},
+	}
+	for _, tc := range testCases {
+		if got := hostnameMatches(tc.pattern, tc.hostname); got != tc.expect {
+			t.Errorf("Hostname %q pattern %q: expected %v, got %v", tc.hostname, tc.pattern, tc.expect, got)
+		}
+	}
+}
+
+func TestRouteRequest(t *testing.T) {
+	get := gateway.HTTPMethodGet
+	created := metav1.NewTime(time.Now())
+	route := func(name string, age time.Duration, hostnames []gateway.Hostname, rules ...gateway.HTTPRouteRule) *gateway.HTTPRoute {
+		return &gateway.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default",
+				CreationTimestamp: metav1.NewTime(created.Add(-age))},
+			Spec: gateway.HTTPRouteSpec{Hostnames: hostnames, Rules: rules},
+		}
+	}
+	rule := func(matches ...gateway.HTTPRouteMatch) gateway.HTTPRouteRule {
+		return gateway.HTTPRouteRule{Matches: matches}
+	}
+	prefix := func(value string) gateway.HTTPRouteMatch {
+		return gateway.HTTPRouteMatch{Path: pathMatch(gateway.PathMatchPathPrefix, value)}
+	}
+
+	testCases := map[string]struct {
+		routes      []*gateway.HTTPRoute
+		host, path  string
+		method      string
+		headers     http.Header
+		query       url.Values
+		expectRoute string
+		expectRule  int
+	}{
+		"no match": {
+			routes: []*gateway.HTTPRoute{route("a", 0, nil, rule(prefix("/foo")))},
+			path:   "/bar",
+		},
+		"default match": {
+			routes:      []*gateway.HTTPRoute{route("a", 0, nil, rule())},
+			path:        "/bar",
+			expectRoute: "a",
+		},
+		"longest prefix": {
+			routes: []*gateway.HTTPRoute{
+				route("a", 0, nil, rule(prefix("/")), rule(prefix("/foo"))),
+				route("b", 0, nil, rule(prefix("/fo"))),
+			},
+			path:        "/foo/bar",
+			expectRoute: "a",
+			expectRule:  1,
+		},
+		"exact path before prefix": {
+			routes: []*gateway.HTTPRoute{
+				route("a", 0, nil, rule(prefix("/foo/bar"))),
+				route("b", 0, nil, rule(gateway.HTTPRouteMatch{Path: pathMatch(gateway.PathMatchExact, "/foo")})),
+			},
+			path:        "/foo",
+			expectRoute: "b",
+		},
+		"exact hostname before wildcard": {
+			routes: []*gateway.HTTPRoute{
+				route("a", time.Hour, []gateway.Hostname{"*.example.com"}, rule(prefix("/foo"))),
+				route("b", 0, []gateway.Hostname{"foo.example.com"}, rule()),
+			},
+			host:        "foo.example.com",
+			path:        "/foo",
+			expectRoute: "b",
+		},
+		"method and headers": {
+			routes: []*gateway.HTTPRoute{
+				route("a", 0, nil, rule(prefix("/")),
+					rule(gateway.HTTPRouteMatch{Method: &get}),
+					rule(gateway.HTTPRouteMatch{Headers: []gateway.HTTPHeaderMatch{{Name: "version", Value: "two"}}})),
+			},
+			method:      "GET",
+			headers:     http.Header{"Version": {"two"}},
+			path:        "/",
+			expectRoute: "a",
+			expectRule:  1,
+		},
+		"query parameters": {
+			routes: []*gateway.HTTPRoute{
+				route("a", 0, nil, rule(prefix("/")),
+					rule(gateway.HTTPRouteMatch{QueryParams: []gateway.HTTPQueryParamMatch{{Name: "animal", Value: "whale"}}})),
+			},
+			query:       url.Values{"animal": {"whale"}},
+			path:        "/",
+			expectRoute: "a",
+			expectRule:  1,
+		},
+		"oldest route": {
+			routes: []*gateway.HTTPRoute{
+				route("a", 0, nil, rule(prefix("/foo"))),
+				route("b", time.Hour, nil, rule(prefix("/foo"))),
+			},
+			path:        "/foo",
+			expectRoute: "b",
+		},
+		"name": {
+			routes: []*gateway.HTTPRoute{
+				route("b", 0, nil, rule(prefix("/foo"))),
+				route("a", 0, nil, rule(prefix("/foo"))),
+			},
+			path:        "/foo",
+			expectRoute: "a",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := &request{host: tc.host, method: tc.method, path: tc.path, query: tc.query, headers: tc.headers}
+			if req.host == "" {
+				req.host = "example.com"
+			}
+			m := routeRequest(nil, tc.routes, req)
+			if tc.expectRoute == "" {
+				if m != nil {
+					t.Fatalf("Expected no match, got route %s rule %d", m.route.Name, m.ruleIndex)
+				}
+				return
+			}
+			if m == nil {
+				t.Fatalf("Expected route %s rule %d, got no match", tc.expectRoute, tc.expectRule)
+			}
+			if m.route.Name != tc.expectRoute || m.ruleIndex != tc.expectRule {
+				t.Errorf("Expected route %s rule %d, got route %s rule %d", tc.expectRoute, tc.expectRule, m.route.Name, m.ruleIndex)
+			}
+		})
+	}
+}
+
+func TestRedirectLocation(t *testing.T) {
+	https := "https"
+	hostname := gateway.PreciseHostname("example.org")
+	port := gateway.PortNumber(8443)
+	moved := http.StatusMovedPermanently
+	req := &request{host: "example.com", path: "/foo", query: url.Values{}}
+
+	testCases := []struct {
+		filter         gateway.HTTPRequestRedirectFilter
+		expectLocation string
+		expectStatus   int
+	}{
+		{gateway.HTTPRequestRedirectFilter{}, "http://example.com/foo", http.StatusFound},
+		{gateway.HTTPRequestRedirectFilter{Hostname: &hostname, StatusCode: &moved}, "http://example.org/foo", http.StatusMovedPermanently},
+		{gateway.HTTPRequestRedirectFilter{Scheme: &https}, "https://example.com/foo", http.StatusFound},
+		{gateway.HTTPRequestRedirectFilter{Scheme: &https, Port: &port}, "https://example.com:8443/foo", http.StatusFound},
+	}
+	for _, tc := range testCases {
+		location, status := redirectLocation(&tc.filter, req, 80)
+		if location.String() != tc.expectLocation || status != tc.expectStatus {
+			t.Errorf("Expected %d %s, got %d %s", tc.expectStatus, tc.expectLocation, status, location)
+		}
+	}
+}
+
+func TestWeightedBackend(t *testing.T) {
+	weight := func(w int32) *int32 { return &w }
+	backends := []gateway.HTTPBackendRef{
+		{BackendRef: gateway.BackendRef{BackendObjectReference: gateway.BackendObjectReference{Name: "a"}, Weight: weight(3)}},
+		{BackendRef: gateway.BackendRef{BackendObjectReference: gateway.BackendObjectReference{Name: "b"}, Weight: weight(0)}},
+		{BackendRef: gateway.BackendRef{BackendObjectReference: gateway.BackendObjectReference{Name: "c"}}},
+	}
+	counts := map[gateway.ObjectName]int{}
+	for n := uint64(0); n < 8; n++ {
+		counts[weightedBackend(backends, n).Name]++
+	}
+	if counts["a"] != 6 || counts["b"] != 0 || counts["c"] != 2 {
+		t.Errorf("Expected backends to be selected by weight, got %v", counts)
+	}
+	if b := weightedBackend(backends[1:2], 0); b != nil {
+		t.Errorf("Expected no backend with zero weights, got %s", b.Name)
+	}
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// requestedAddresses returns the addresses requested in the Gateway spec with
+// address type defaulted.
+func requestedAddresses(gw *gateway.Gateway) []gateway.GatewayAddress {
+	addresses := make([]gateway.GatewayAddress, 0, len(gw.Spec.Addresses))
+	for _, a := range gw.Spec.Addresses {
+		if a.Type == nil {
+			a.Type = addressTypePtr(gateway.IPAddressType)
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses
+}
+
+// assignedAddresses returns the addresses assigned to objects created from
+// templates as reported in their status. Addresses are found in the common
+// locations used by e.g. Services and Ingresses ('status.loadBalancer.ingress')
+// and Gateways ('status.addresses').
+func assignedAddresses(objs []*unstructured.Unstructured) []gateway.GatewayAddress {
+	var addresses []gateway.GatewayAddress
+	found := map[string]bool{}
+	add := func(value string) {
+		if value == "" || found[value] {
+			return
+		}
+		found[value] = true
+		addrType := gateway.HostnameAddressType
+		if net.ParseIP(value) != nil {
+			addrType = gateway.IPAddressType
+		}
+		addresses = append(addresses, gateway.GatewayAddress{Type: addressTypePtr(addrType), Value: value})
+	}
+
+	for _, obj := range objs {
+		ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+		for _, i := range ingress {
+			if im, ok := i.(map[string]any); ok {
+				ip, _, _ := unstructured.NestedString(im, "ip")
+				add(ip)
+				hostname, _, _ := unstructured.NestedString(im, "hostname")
+				add(hostname)
+			}
+		}
+		addrs, _, _ := unstructured.NestedSlice(obj.Object, "status", "addresses")
+		for _, a := range addrs {
+			if am, ok := a.(map[string]any); ok {
+				value, _, _ := unstructured.NestedString(am, "value")
+				add(value)
+			}
+		}
+	}
+	return addresses
+}
+
+// unassignedAddresses returns the requested addresses not honored by the
+// assigned addresses. Named addresses cannot be matched against assigned
+// addresses and are assumed honored if any address was assigned, i.e. the
+// cloud provider resolved the name.
+func unassignedAddresses(requested, assigned []gateway.GatewayAddress) []gateway.GatewayAddress {
+	var unassigned []gateway.GatewayAddress
+	for _, r := range requested {
+		if r.Type != nil && *r.Type == gateway.NamedAddressType {
+			if len(assigned) == 0 {
+				unassigned = append(unassigned, r)
+			}
+			continue
+		}
+		found := false
+		for _, a := range assigned {
+			if a.Value == r.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unassigned = append(unassigned, r)
+		}
+	}
+	return unassigned
+}
+
+func addressTypePtr(t gateway.AddressType) *gateway.AddressType {
+	return &t
+}
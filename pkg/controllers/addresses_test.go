@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestAssignedAddresses(t *testing.T) {
+	ingress := &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{"loadBalancer": map[string]any{"ingress": []any{
+			map[string]any{"ip": "192.0.2.10"},
+			map[string]any{"hostname": "lb-1234.elb.example.com"},
+		}}},
+	}}
+	lb := &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{"addresses": []any{
+			map[string]any{"type": "IPAddress", "value": "192.0.2.10"},
+			map[string]any{"type": "IPAddress", "value": "2001:db8::10"},
+		}},
+	}}
+	certificate := &unstructured.Unstructured{Object: map[string]any{"status": map[string]any{}}}
+
+	assigned := assignedAddresses([]*unstructured.Unstructured{ingress, lb, certificate})
+	expected := []struct {
+		addrType gateway.AddressType
+		value    string
+	}{
+		{gateway.IPAddressType, "192.0.2.10"},
+		{gateway.HostnameAddressType, "lb-1234.elb.example.com"},
+		{gateway.IPAddressType, "2001:db8::10"},
+	}
+	if len(assigned) != len(expected) {
+		t.Fatalf("Unexpected addresses: %+v", assigned)
+	}
+	for i, e := range expected {
+		if *assigned[i].Type != e.addrType || assigned[i].Value != e.value {
+			t.Errorf("Unexpected address %d: %+v", i, assigned[i])
+		}
+	}
+}
+
+func TestUnassignedAddresses(t *testing.T) {
+	ip := gateway.GatewayAddress{Type: addressTypePtr(gateway.IPAddressType), Value: "192.0.2.10"}
+	otherIP := gateway.GatewayAddress{Type: addressTypePtr(gateway.IPAddressType), Value: "192.0.2.11"}
+	named := gateway.GatewayAddress{Type: addressTypePtr(gateway.NamedAddressType), Value: "partner-allowlist-ip"}
+
+	tests := []struct {
+		requested  []gateway.GatewayAddress
+		assigned   []gateway.GatewayAddress
+		unassigned int
+	}{
+		{nil, nil, 0},
+		{[]gateway.GatewayAddress{ip}, []gateway.GatewayAddress{ip}, 0},
+		{[]gateway.GatewayAddress{ip, otherIP}, []gateway.GatewayAddress{ip}, 1},
+		{[]gateway.GatewayAddress{named}, nil, 1},
+		{[]gateway.GatewayAddress{named}, []gateway.GatewayAddress{otherIP}, 0},
+	}
+	for _, tc := range tests {
+		if unassigned := unassignedAddresses(tc.requested, tc.assigned); len(unassigned) != tc.unassigned {
+			t.Errorf("Unexpected unassigned addresses for %+v and %+v: %+v", tc.requested, tc.assigned, unassigned)
+		}
+	}
+}
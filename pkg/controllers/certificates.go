@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// Default limit on DNS names per certificate, which is the limit of e.g. AWS
+// ACM and Let's Encrypt
+const defaultCertificateMaxDNSNames = 100
+
+type certificateValues struct {
+	// Suffix distinguishes names of certificates when DNS names are split
+	// across multiple certificates. It is empty for the first certificate.
+	Suffix string
+
+	// DNSNames is the subject alternative names of the certificate
+	DNSNames []string
+}
+
+// certificateMaxDNSNames returns the limit on DNS names per certificate from
+// the class parameters.
+func certificateMaxDNSNames(configmap *corev1.ConfigMap) (int, error) {
+	limit, found := configmap.Data["certificateMaxDNSNames"]
+	if !found {
+		return defaultCertificateMaxDNSNames, nil
+	}
+	maxNames, err := strconv.Atoi(limit)
+	if err != nil || maxNames < 1 {
+		return 0, fmt.Errorf("invalid certificateMaxDNSNames %q", limit)
+	}
+	return maxNames, nil
+}
+
+// certificateDNSNames returns the DNS names a certificate for the Gateway must
+// cover, i.e. listener hostnames and effective hostnames of attached routes.
+func certificateDNSNames(gw *gateway.Gateway, routeHostnames []string) []string {
+	nameSet := map[string]bool{}
+	for i := range gw.Spec.Listeners {
+		if h := gw.Spec.Listeners[i].Hostname; h != nil && *h != "" {
+			nameSet[string(*h)] = true
+		}
+	}
+	for _, h := range routeHostnames {
+		nameSet[h] = true
+	}
+	return collapseWildcards(sortedKeys(nameSet))
+}
+
+// collapseWildcards removes names covered by a wildcard name in the list.
+// Note, that certificate wildcards only cover a single DNS label, i.e.
+// '*.example.com' covers 'foo.example.com' but not 'foo.bar.example.com'.
+func collapseWildcards(names []string) []string {
+	wildcards := map[string]bool{}
+	for _, n := range names {
+		if strings.HasPrefix(n, "*.") {
+			wildcards[n[2:]] = true
+		}
+	}
+
+	collapsed := make([]string, 0, len(names))
+	for _, n := range names {
+		if !strings.HasPrefix(n, "*.") {
+			if _, parent, found := strings.Cut(n, "."); found && wildcards[parent] {
+				continue
+			}
+		}
+		collapsed = append(collapsed, n)
+	}
+	return collapsed
+}
+
+// splitCertificates splits DNS names into certificates with at most maxNames
+// names each. Names are sorted such that the split is deterministic.
+func splitCertificates(names []string, maxNames int) []certificateValues {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	var certs []certificateValues
+	for idx := 0; len(sorted) > 0; idx++ {
+		n := maxNames
+		if n > len(sorted) {
+			n = len(sorted)
+		}
+		cert := certificateValues{DNSNames: sorted[:n]}
+		if idx > 0 {
+			cert.Suffix = fmt.Sprintf("-%d", idx)
+		}
+		certs = append(certs, cert)
+		sorted = sorted[n:]
+	}
+	return certs
+}
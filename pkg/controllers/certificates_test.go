@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestCertificateDNSNames(t *testing.T) {
+	wildcard := gateway.Hostname("*.example.com")
+	gw := &gateway.Gateway{
+		Spec: gateway.GatewaySpec{
+			Listeners: []gateway.Listener{
+				{Name: "web", Port: 443, Protocol: gateway.HTTPSProtocolType, Hostname: &wildcard},
+				{Name: "any", Port: 80, Protocol: gateway.HTTPProtocolType},
+			},
+		},
+	}
+	names := certificateDNSNames(gw, []string{"foo.example.com", "foo.bar.example.com", "foo.example.org", "foo.example.com"})
+	expected := []string{"*.example.com", "foo.bar.example.com", "foo.example.org"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Unexpected DNS names: %v", names)
+	}
+}
+
+func TestSplitCertificates(t *testing.T) {
+	names := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		names = append(names, fmt.Sprintf("host%03d.example.com", i))
+	}
+	certs := splitCertificates(names, 100)
+	if len(certs) != 3 {
+		t.Fatalf("Expected 3 certificates, got %d", len(certs))
+	}
+	if certs[0].Suffix != "" || certs[1].Suffix != "-1" || certs[2].Suffix != "-2" {
+		t.Errorf("Unexpected certificate suffixes: %q %q %q", certs[0].Suffix, certs[1].Suffix, certs[2].Suffix)
+	}
+	if len(certs[0].DNSNames) != 100 || len(certs[2].DNSNames) != 50 || certs[1].DNSNames[0] != "host100.example.com" {
+		t.Errorf("Unexpected certificate split: %+v", certs)
+	}
+	if len(splitCertificates(nil, 100)) != 0 {
+		t.Errorf("Expected no certificates without DNS names")
+	}
+}
+
+func TestCertificateMaxDNSNames(t *testing.T) {
+	tests := []struct {
+		data     map[string]string
+		expected int
+		isErr    bool
+	}{
+		{map[string]string{}, defaultCertificateMaxDNSNames, false},
+		{map[string]string{"certificateMaxDNSNames": "10"}, 10, false},
+		{map[string]string{"certificateMaxDNSNames": "0"}, 0, true},
+		{map[string]string{"certificateMaxDNSNames": "many"}, 0, true},
+	}
+	for _, tc := range tests {
+		maxNames, err := certificateMaxDNSNames(&corev1.ConfigMap{Data: tc.data})
+		if (err != nil) != tc.isErr || maxNames != tc.expected {
+			t.Errorf("Unexpected limit for %v: %d, %v", tc.data, maxNames, err)
+		}
+	}
+}
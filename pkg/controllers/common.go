@@ -6,9 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -17,36 +23,288 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/logging"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/tracing"
+)
+
+// Condition reasons used in addition to those defined by the Gateway API
+const (
+	ReasonTier2GatewayClassNotFound    = "Tier2GatewayClassNotFound"
+	ReasonTier2GatewayClassNotAccepted = "Tier2GatewayClassNotAccepted"
+	ReasonDegraded                     = "Degraded"
+)
+
+// Label set on objects created from templates, with the template key as value
+const templateLabel = "cloud-gateway-controller.pixelperfekt.dk/template"
+
+// Annotation set on Gateways with the kinds of objects created from templates,
+// as comma-separated apiVersion/kind
+const templateKindsAnnotation = "cloud-gateway-controller.pixelperfekt.dk/template-kinds"
+
+// Label set on shadow HTTPRoutes with the instance ID of the controller
+// managing them, such that controller instances with different controller
+// names leave each others shadow routes alone
+const controllerLabel = "cloud-gateway-controller.pixelperfekt.dk/controller"
+
+// Cache field indexes
+const (
+	gatewayClassIndex           = "spec.gatewayClassName"
+	httpRouteParentIndex        = "spec.parentRefs.gateway"
+	gatewayClassParametersIndex = "spec.parametersRef"
+	tier2GatewayClassIndex      = "data.tier2GatewayClass"
 )
 
+// Name of the component recording events
+const eventRecorderName = "cloud-gateway-controller"
+
+// Event reasons
 const (
-	SelfControllerName gateway.GatewayController = "github.com/pixelperfekt-dk/cloud-gateway-controller"
+	EventReasonShadowCreated   = "ShadowCreated"
+	EventReasonShadowUpdated   = "ShadowUpdated"
+	EventReasonShadowDeleted   = "ShadowDeleted"
+	EventReasonShadowOrphaned  = "ShadowOrphaned"
+	EventReasonApplied         = "Applied"
+	EventReasonApplyFailed     = "ApplyFailed"
+	EventReasonPruned          = "Pruned"
+	EventReasonTemplateError   = "TemplateError"
+	EventReasonParentRejected  = "ParentRejected"
+	EventReasonPolicyViolation = "PolicyViolation"
 )
 
+// Resource versions of objects applied from templates by controller and
+// Gateway, used to only record events when objects change. Reconciles of a
+// Gateway are serialized, so the versions of a Gateway need no further
+// locking. Keys include the controller name, such that controller instances
+// in the same process keep separate state.
+var appliedResourceVersions sync.Map
+
+type appliedGatewayKey struct {
+	controllerName gateway.GatewayController
+	gw             types.NamespacedName
+}
+
+// recordAppliedVersion records the resource version of an object applied
+// from a template of a Gateway and returns whether it changed
+func recordAppliedVersion(controllerName gateway.GatewayController, gw types.NamespacedName, obj *unstructured.Unstructured) bool {
+	value, _ := appliedResourceVersions.LoadOrStore(appliedGatewayKey{controllerName, gw}, map[string]string{})
+	versions := value.(map[string]string)
+	key := appliedObjectKey(obj)
+	if previous, found := versions[key]; found && previous == obj.GetResourceVersion() {
+		return false
+	}
+	versions[key] = obj.GetResourceVersion()
+	return true
+}
+
+// forgetAppliedVersion removes the resource version recorded for an object
+// deleted, or for all objects of a Gateway deleted if obj is nil
+func forgetAppliedVersion(controllerName gateway.GatewayController, gw types.NamespacedName, obj *unstructured.Unstructured) {
+	key := appliedGatewayKey{controllerName, gw}
+	if obj == nil {
+		appliedResourceVersions.Delete(key)
+		return
+	}
+	if value, found := appliedResourceVersions.Load(key); found {
+		delete(value.(map[string]string), appliedObjectKey(obj))
+	}
+}
+
+func appliedObjectKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+}
+
 type Controller interface {
 	GetClient() client.Client
 	DynamicClient() dynamic.Interface
 	Scheme() *runtime.Scheme
+	EventRecorder() record.EventRecorder
+
+	// ControllerName is the controller name of GatewayClasses managed
+	ControllerName() gateway.GatewayController
+
+	// FieldManager is the field manager used when applying objects
+	FieldManager() string
+}
+
+// managedBy returns true if an object created by a controller is managed by
+// the controller instance with the given controller name. Objects without a
+// controller label are managed by the default controller instance.
+func managedBy(obj client.Object, controllerName gateway.GatewayController) bool {
+	id, found := obj.GetLabels()[controllerLabel]
+	if !found {
+		return controllerName == config.DefaultControllerName
+	}
+	return id == config.InstanceID(string(controllerName))
+}
+
+// fieldIndexes are the cache field indexes used by the controllers
+var fieldIndexes = []struct {
+	obj     client.Object
+	field   string
+	extract client.IndexerFunc
+}{
+	{&gateway.Gateway{}, gatewayClassIndex, func(obj client.Object) []string {
+		gw := obj.(*gateway.Gateway)
+		return []string{string(gw.Spec.GatewayClassName)}
+	}},
+	{&gateway.GatewayClass{}, gatewayClassParametersIndex, func(obj client.Object) []string {
+		gwc := obj.(*gateway.GatewayClass)
+		if cmName, isConfigMap := parametersRefConfigMap(gwc.Spec.ParametersRef); isConfigMap {
+			return []string{cmName.String()}
+		}
+		return nil
+	}},
+	{&corev1.ConfigMap{}, tier2GatewayClassIndex, func(obj client.Object) []string {
+		if className := obj.(*corev1.ConfigMap).Data["tier2GatewayClass"]; className != "" {
+			return []string{className}
+		}
+		return nil
+	}},
+	{&gateway.HTTPRoute{}, httpRouteParentIndex, func(obj client.Object) []string {
+		rt := obj.(*gateway.HTTPRoute)
+		var parents []string
+		for i := range rt.Spec.ParentRefs {
+			if gwName, isGateway := parentRefGateway(&rt.Spec.ParentRefs[i], rt.Namespace); isGateway {
+				parents = append(parents, gwName.String())
+			}
+		}
+		return parents
+	}},
+}
+
+// SetupIndexes registers the cache field indexes used by the controllers. It
+// must be called before the controllers are setup.
+func SetupIndexes(ctx context.Context, mgr ctrl.Manager) error {
+	for _, index := range fieldIndexes {
+		if err := mgr.GetFieldIndexer().IndexField(ctx, index.obj, index.field, index.extract); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parametersRefConfigMap returns the name of the ConfigMap referenced by the
+// parameters reference of a GatewayClass, or false if there is none.
+func parametersRefConfigMap(ref *gateway.ParametersReference) (types.NamespacedName, bool) {
+	if ref == nil || ref.Kind != "ConfigMap" || ref.Namespace == nil {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: string(*ref.Namespace), Name: ref.Name}, true
+}
+
+// parentRefGateway returns the name of the Gateway referenced by a route
+// parent reference, or false if the reference is not to a Gateway.
+func parentRefGateway(pref *gateway.ParentReference, rtNamespace string) (types.NamespacedName, bool) {
+	if (pref.Group != nil && *pref.Group != gateway.GroupName) || (pref.Kind != nil && *pref.Kind != "Gateway") {
+		return types.NamespacedName{}, false
+	}
+	namespace := rtNamespace
+	if pref.Namespace != nil {
+		namespace = string(*pref.Namespace)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: string(pref.Name)}, true
+}
+
+// parametersClasses returns the names of GatewayClasses of a controller
+// using a ConfigMap as class parameters.
+func parametersClasses(ctx context.Context, c client.Reader, controllerName gateway.GatewayController, cmName types.NamespacedName) ([]string, error) {
+	var gwcList gateway.GatewayClassList
+	if err := c.List(ctx, &gwcList, client.MatchingFields{gatewayClassParametersIndex: cmName.String()}); err != nil {
+		return nil, err
+	}
+	var classes []string
+	for i := range gwcList.Items {
+		if gwcList.Items[i].Spec.ControllerName == controllerName {
+			classes = append(classes, gwcList.Items[i].Name)
+		}
+	}
+	return classes, nil
+}
+
+// tier2Classes returns the names of GatewayClasses of a controller using a
+// GatewayClass as tier-2 class. Class parameters using the tier-2 class and
+// the classes referencing them are found through indexes.
+func tier2Classes(ctx context.Context, c client.Reader, controllerName gateway.GatewayController, tier2Class string) ([]string, error) {
+	var cmList corev1.ConfigMapList
+	if err := c.List(ctx, &cmList, client.MatchingFields{tier2GatewayClassIndex: tier2Class}); err != nil {
+		return nil, err
+	}
+	var classes []string
+	for i := range cmList.Items {
+		names, err := parametersClasses(ctx, c, controllerName, client.ObjectKeyFromObject(&cmList.Items[i]))
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, names...)
+	}
+	return classes, nil
+}
+
+// classGateways returns the Gateways using any of the given GatewayClasses.
+func classGateways(ctx context.Context, c client.Reader, classes []string) ([]gateway.Gateway, error) {
+	var gateways []gateway.Gateway
+	for _, className := range classes {
+		var gwList gateway.GatewayList
+		if err := c.List(ctx, &gwList, client.MatchingFields{gatewayClassIndex: className}); err != nil {
+			return nil, err
+		}
+		gateways = append(gateways, gwList.Items...)
+	}
+	return gateways, nil
+}
+
+// requestsForGateways returns requests for Gateways.
+func requestsForGateways(gateways []gateway.Gateway) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(gateways))
+	for i := range gateways {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gateways[i])})
+	}
+	return requests
+}
+
+// shadowGatewayName returns the name of the shadow Gateway created for a
+// Gateway.
+func shadowGatewayName(gwName string, configmap *corev1.ConfigMap) string {
+	return fmt.Sprintf("%s-%s", gwName, configmap.Data["tier2GatewayClass"])
+}
+
+// routeNamespaceLabels returns the labels of a route namespace if they are
+// needed for matching against the listeners of a Gateway.
+func routeNamespaceLabels(ctx context.Context, r Controller, gw *gateway.Gateway, rtNamespace string) (map[string]string, error) {
+	if !listenersUseNamespaceSelector(gw) {
+		return nil, nil
+	}
+	var ns corev1.Namespace
+	if err := r.GetClient().Get(ctx, types.NamespacedName{Name: rtNamespace}, &ns); err != nil {
+		return nil, err
+	}
+	return ns.Labels, nil
 }
 
-func lookupGatewayClass(ctx context.Context, r Controller, className string) (*gateway.GatewayClass, *corev1.ConfigMap, error) {
+func lookupGatewayClass(ctx context.Context, r Controller, className string) (_ *gateway.GatewayClass, _ *corev1.ConfigMap, err error) {
+	ctx, span := tracing.Start(ctx, "lookupGatewayClass", tracing.GatewayClassKey.String(className))
+	defer func() { tracing.End(span, err) }()
 	log := log.FromContext(ctx)
 
 	var gwc gateway.GatewayClass
-	err := r.GetClient().Get(ctx, types.NamespacedName{Name: className, Namespace: ""}, &gwc)
+	err = r.GetClient().Get(ctx, types.NamespacedName{Name: className, Namespace: ""}, &gwc)
 	if err != nil {
 		return nil, nil, fmt.Errorf("GatewayClass %q not found: %w", className, err)
 	}
 
-	if gwc.Spec.ControllerName != SelfControllerName {
+	if gwc.Spec.ControllerName != r.ControllerName() {
 		return nil, nil, nil
 	}
 
-	log.Info("lookupGatewayClass", "gatewayclasses", gwc)
+	log.V(logging.ObjectLevel).Info("lookupGatewayClass", logging.ObjectKey, logging.Object(&gwc))
 
 	// Lookup associated ConfigMap
 	var configmap *corev1.ConfigMap
@@ -58,33 +316,108 @@ func lookupGatewayClass(ctx context.Context, r Controller, className string) (*g
 		if err != nil {
 			return &gwc, nil, fmt.Errorf("configmap for GatewayClass not found: %w", err)
 		}
-		log.Info("lookupGatewayClass", "configmap", configmap.Data)
+		log.V(logging.ObjectLevel).Info("lookupGatewayClass", logging.ObjectKey, logging.Object(configmap))
 	}
 
 	return &gwc, configmap, nil
 }
 
-func patch(ctx context.Context, r Controller, us *unstructured.Unstructured, namespace string) error {
-	log := log.FromContext(ctx)
+// parametersCondition returns an 'Accepted=False' condition if the class
+// parameters ConfigMap of a GatewayClass could not be read because it is
+// missing or in a namespace not watched by the controller, or nil if err is
+// another error. Namespaces are the namespaces watched, all if empty.
+func parametersCondition(gwc *gateway.GatewayClass, err error, namespaces []string) *metav1.Condition {
+	cmName, isConfigMap := parametersRefConfigMap(gwc.Spec.ParametersRef)
+	if !isConfigMap {
+		return nil
+	}
+	cond := &metav1.Condition{
+		Type:   string(gateway.GatewayClassConditionStatusAccepted),
+		Status: metav1.ConditionFalse,
+		Reason: string(gateway.GatewayClassReasonInvalidParameters),
+	}
+	watched := len(namespaces) == 0
+	for _, ns := range namespaces {
+		watched = watched || ns == cmName.Namespace
+	}
+	switch {
+	case !watched:
+		cond.Message = fmt.Sprintf("class parameters ConfigMap %s is in a namespace not watched by the controller, watched namespaces are %s",
+			cmName, strings.Join(namespaces, ", "))
+	case apierrors.IsNotFound(err):
+		cond.Message = fmt.Sprintf("class parameters ConfigMap %s not found", cmName)
+	default:
+		return nil
+	}
+	return cond
+}
+
+// lookupTier2GatewayClass looks up the tier-2 GatewayClass referenced by the
+// class parameters. If the tier-2 class is missing or not accepted by its
+// controller, a non-nil 'Accepted=False' condition describing the problem is
+// returned.
+func lookupTier2GatewayClass(ctx context.Context, r Controller, configmap *corev1.ConfigMap) (_ *gateway.GatewayClass, _ *metav1.Condition, err error) {
+	className, found := configmap.Data["tier2GatewayClass"]
+	ctx, span := tracing.Start(ctx, "lookupTier2GatewayClass", tracing.GatewayClassKey.String(className))
+	defer func() { tracing.End(span, err) }()
+	if !found || className == "" {
+		return nil, &metav1.Condition{
+			Type:    string(gateway.GatewayClassConditionStatusAccepted),
+			Status:  metav1.ConditionFalse,
+			Reason:  string(gateway.GatewayClassReasonInvalidParameters),
+			Message: "tier2GatewayClass not specified in class parameters",
+		}, nil
+	}
+
+	var gwc gateway.GatewayClass
+	err = r.GetClient().Get(ctx, types.NamespacedName{Name: className}, &gwc)
+	if apierrors.IsNotFound(err) {
+		return nil, &metav1.Condition{
+			Type:    string(gateway.GatewayClassConditionStatusAccepted),
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonTier2GatewayClassNotFound,
+			Message: fmt.Sprintf("tier-2 GatewayClass %q not found", className),
+		}, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	if !meta.IsStatusConditionTrue(gwc.Status.Conditions, string(gateway.GatewayClassConditionStatusAccepted)) {
+		return &gwc, &metav1.Condition{
+			Type:    string(gateway.GatewayClassConditionStatusAccepted),
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonTier2GatewayClassNotAccepted,
+			Message: fmt.Sprintf("tier-2 GatewayClass %q not accepted by controller %q", className, gwc.Spec.ControllerName),
+		}, nil
+	}
+
+	return &gwc, nil, nil
+}
+
+func patch(ctx context.Context, r Controller, dc dynamic.Interface, us *unstructured.Unstructured, namespace string) (_ *unstructured.Unstructured, err error) {
+	ctx, span := tracing.Start(ctx, "patch", tracing.GVKKey.String(us.GroupVersionKind().String()), tracing.NameKey.String(us.GetName()))
+	defer func() { tracing.End(span, err) }()
+	log := log.FromContext(ctx, logging.GVKKey, us.GroupVersionKind().String(), logging.NameKey, us.GetName())
 	gvr, err := unstructuredToGVR(r, us)
 	if err != nil {
 		log.Error(err, "Cannot convert unstructured to GVR")
-		return err
+		return nil, err
 	}
 	jsondata, err := json.Marshal(us.Object)
 	if err != nil {
 		log.Error(err, "Cannot convert unstructured to json")
-		return err
+		return nil, err
 	}
 
-	log.Info("ApplyPatch", "jsondata", string(jsondata))
-	c := r.DynamicClient().Resource(*gvr).Namespace(namespace)
+	log.V(logging.DebugLevel).Info("apply patch")
+	log.V(logging.ObjectLevel).Info("apply patch", logging.ObjectKey, logging.Object(us))
+	c := dc.Resource(*gvr).Namespace(namespace)
 	t := true
-	_, err = c.Patch(ctx, us.GetName(), types.ApplyPatchType, jsondata, metav1.PatchOptions{
+	defer observeApplyDuration(us.GroupVersionKind(), time.Now())
+	return c.Patch(ctx, us.GetName(), types.ApplyPatchType, jsondata, metav1.PatchOptions{
 		Force:        &t,
-		FieldManager: string(SelfControllerName),
+		FieldManager: r.FieldManager(),
 	})
-	return err
 }
 
 func unstructuredToGVR(r Controller, u *unstructured.Unstructured) (*schema.GroupVersionResource, error) {
@@ -108,7 +441,7 @@ func unstructuredToGVR(r Controller, u *unstructured.Unstructured) (*schema.Grou
 	}, nil
 }
 
-func renderTemplate(gwParent *gateway.Gateway, configmap *corev1.ConfigMap, configmapKey string) (*unstructured.Unstructured, error) {
+func renderTemplate(values *albTemplateValues, configmap *corev1.ConfigMap, configmapKey string) (*unstructured.Unstructured, error) {
 	var buf bytes.Buffer
 	tmpl, found := configmap.Data[configmapKey]
 	if !found {
@@ -120,8 +453,7 @@ func renderTemplate(gwParent *gateway.Gateway, configmap *corev1.ConfigMap, conf
 		// TODO log
 		return nil, err
 	}
-	input := &albTemplateValues{gwParent}
-	err = ptmpl.Execute(io.Writer(&buf), input)
+	err = ptmpl.Execute(io.Writer(&buf), values)
 	if err != nil {
 		// TODO log
 		return nil, err
@@ -131,28 +463,216 @@ func renderTemplate(gwParent *gateway.Gateway, configmap *corev1.ConfigMap, conf
 	if err != nil {
 		return nil, err
 	}
+	if len(data) == 0 {
+		// Templates may render nothing, e.g. for listeners they do not apply to
+		return nil, nil
+	}
 	us := unstructured.Unstructured{Object: data}
+	if values.Listener != nil {
+		us.SetName(fmt.Sprintf("%s-%s", us.GetName(), values.Listener.Name))
+	}
 	return &us, nil
 }
 
-func createUpdateFromTemplate(ctx context.Context, r Controller, gwParent *gateway.Gateway, configmap *corev1.ConfigMap, configmapKey string) error {
-	log := log.FromContext(ctx)
-	obj, err := renderTemplate(gwParent, configmap, configmapKey)
+// createUpdateFromTemplate renders a template and applies the object rendered
+// if it complies with the class policy, which may be nil
+func createUpdateFromTemplate(ctx context.Context, r Controller, dc dynamic.Interface, values *albTemplateValues, configmap *corev1.ConfigMap, policy *templatePolicy, configmapKey string) (*unstructured.Unstructured, error) {
+	log := log.FromContext(ctx, logging.TemplateKey, configmapKey)
+	gwParent := values.Gateway
+	_, span := tracing.Start(ctx, "renderTemplate", tracing.TemplateKey.String(configmapKey),
+		tracing.GatewayKey.String(client.ObjectKeyFromObject(gwParent).String()))
+	obj, err := renderTemplate(values, configmap, configmapKey)
+	tracing.End(span, err)
 	if err != nil {
-		log.Error(err, "unable to render template", "templateKey", configmapKey)
-		return err
+		log.Error(err, "unable to render template")
+		templateRenderFailures.WithLabelValues(configmapKey).Inc()
+		r.EventRecorder().Eventf(gwParent, corev1.EventTypeWarning, EventReasonTemplateError,
+			"Unable to render template %s: %v", configmapKey, err)
+		return nil, err
+	}
+	if obj == nil {
+		return nil, nil
 	}
 
-	log.Info("create obj", "obj", obj)
+	log = log.WithValues(logging.GVKKey, obj.GroupVersionKind().String(), logging.NameKey, obj.GetName())
+	log.V(logging.ObjectLevel).Info("rendered template", logging.ObjectKey, logging.Object(obj))
+
+	if policy != nil {
+		if err := policy.check(obj, gwParent, configmapKey); err != nil {
+			log.Info("policy violation", "reason", err.Error())
+			r.EventRecorder().Event(gwParent, corev1.EventTypeWarning, EventReasonPolicyViolation, err.Error())
+			return nil, err
+		}
+	}
 
 	if err := ctrl.SetControllerReference(gwParent, obj, r.Scheme()); err != nil {
-		log.Error(err, "unable to set controllerreference for obj", "obj", obj)
-		return err
+		log.Error(err, "unable to set controllerreference for obj")
+		return nil, err
 	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[templateLabel] = configmapKey
+	obj.SetLabels(labels)
 
-	if err := patch(ctx, r, obj, gwParent.ObjectMeta.Namespace); err != nil {
-		log.Error(err, "unable to patch", "obj", obj)
-		return err
+	applied, err := patch(ctx, r, dc, obj, gwParent.ObjectMeta.Namespace)
+	if err != nil {
+		log.Error(err, "unable to patch")
+		r.EventRecorder().Eventf(gwParent, corev1.EventTypeWarning, EventReasonApplyFailed,
+			"Unable to apply %s %s from template %s: %v", obj.GetKind(), obj.GetName(), configmapKey, err)
+		return nil, err
 	}
-	return nil
+
+	if recordAppliedVersion(r.ControllerName(), client.ObjectKeyFromObject(gwParent), applied) {
+		r.EventRecorder().Eventf(gwParent, corev1.EventTypeNormal, EventReasonApplied,
+			"Applied %s %s from template %s", applied.GetKind(), applied.GetName(), configmapKey)
+	}
+	return applied, nil
+}
+
+// applyTemplate renders and applies a template once for each set of template
+// values, checking objects against the class policy if not nil. The applied
+// objects are returned.
+func applyTemplate(ctx context.Context, r Controller, dc dynamic.Interface, instances []*albTemplateValues, configmap *corev1.ConfigMap, policy *templatePolicy, configmapKey string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	applied := map[schema.GroupVersionKind]map[string]bool{}
+	for _, values := range instances {
+		obj, err := createUpdateFromTemplate(ctx, r, dc, values, configmap, policy, configmapKey)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		if applied[gvk] == nil {
+			applied[gvk] = map[string]bool{}
+		}
+		if applied[gvk][obj.GetName()] {
+			return nil, fmt.Errorf("template %q rendered duplicate %s %q", configmapKey, gvk.Kind, obj.GetName())
+		}
+		applied[gvk][obj.GetName()] = true
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// templateKinds returns the kinds of objects created from templates recorded
+// on a Gateway
+func templateKinds(gw *gateway.Gateway) []schema.GroupVersionKind {
+	var kinds []schema.GroupVersionKind
+	for _, s := range strings.Split(gw.Annotations[templateKindsAnnotation], ",") {
+		i := strings.LastIndex(s, "/")
+		if i < 0 {
+			continue
+		}
+		kinds = append(kinds, schema.FromAPIVersionAndKind(s[:i], s[i+1:]))
+	}
+	return kinds
+}
+
+// setTemplateKinds records the kinds of objects created from templates on a
+// Gateway, such that objects of templates no longer applied can be found for
+// pruning.
+func setTemplateKinds(ctx context.Context, c client.Client, gw *gateway.Gateway, kinds []schema.GroupVersionKind) error {
+	var values []string
+	for _, gvk := range kinds {
+		apiVersion, kind := gvk.ToAPIVersionAndKind()
+		values = append(values, apiVersion+"/"+kind)
+	}
+	sort.Strings(values)
+	value := strings.Join(values, ",")
+	if gw.Annotations[templateKindsAnnotation] == value {
+		return nil
+	}
+	patch := client.MergeFrom(gw.DeepCopy())
+	if value == "" {
+		delete(gw.Annotations, templateKindsAnnotation)
+	} else {
+		metav1.SetMetaDataAnnotation(&gw.ObjectMeta, templateKindsAnnotation, value)
+	}
+	return c.Patch(ctx, gw, patch)
+}
+
+// mergeKinds returns the kinds given with the kinds of the objects added
+func mergeKinds(kinds []schema.GroupVersionKind, objs []*unstructured.Unstructured) []schema.GroupVersionKind {
+	found := map[schema.GroupVersionKind]bool{}
+	for _, gvk := range kinds {
+		found[gvk] = true
+	}
+	merged := append([]schema.GroupVersionKind{}, kinds...)
+	for _, obj := range objs {
+		if gvk := obj.GroupVersionKind(); !found[gvk] {
+			found[gvk] = true
+			merged = append(merged, gvk)
+		}
+	}
+	return merged
+}
+
+// pruneTemplateObjects deletes objects of the given kinds owned by the Gateway
+// that were created from templates but are not among the applied objects.
+// Objects of skipped templates, e.g. with unmet requirements, are kept. This
+// covers objects no longer rendered, e.g. for removed listeners, and all
+// objects of templates rendering nothing or removed from the class. The kinds
+// of objects remaining are returned.
+func pruneTemplateObjects(ctx context.Context, r Controller, dc dynamic.Interface, gwParent *gateway.Gateway, kinds []schema.GroupVersionKind, applied []*unstructured.Unstructured, skipped map[string]bool) (_ []schema.GroupVersionKind, err error) {
+	ctx, span := tracing.Start(ctx, "pruneTemplateObjects", tracing.GatewayKey.String(client.ObjectKeyFromObject(gwParent).String()))
+	defer func() { tracing.End(span, err) }()
+	log := log.FromContext(ctx)
+	keep := map[schema.GroupVersionKind]map[string]bool{}
+	for _, obj := range applied {
+		gvk := obj.GroupVersionKind()
+		if keep[gvk] == nil {
+			keep[gvk] = map[string]bool{}
+		}
+		keep[gvk][obj.GetName()] = true
+	}
+
+	var remaining []schema.GroupVersionKind
+	for _, gvk := range kinds {
+		mapping, err := r.GetClient().RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			// No objects can exist of kinds no longer served
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		c := dc.Resource(mapping.Resource).Namespace(gwParent.Namespace)
+		objList, err := c.List(ctx, metav1.ListOptions{LabelSelector: templateLabel})
+		if apierrors.IsForbidden(err) {
+			// Templates of a kind removed from the class may have taken the
+			// permissions along, the kind is kept for a later attempt
+			log.Info("unable to list objects for pruning", logging.GVKKey, gvk.String(), "reason", err.Error())
+			remaining = append(remaining, gvk)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found := keep[gvk] != nil
+		for i := range objList.Items {
+			obj := &objList.Items[i]
+			if !metav1.IsControlledBy(obj, gwParent) {
+				continue
+			}
+			configmapKey := obj.GetLabels()[templateLabel]
+			if keep[gvk][obj.GetName()] || skipped[configmapKey] {
+				found = true
+				continue
+			}
+			log.Info("prune obj", logging.GVKKey, gvk.String(), logging.NameKey, obj.GetName(), logging.TemplateKey, configmapKey)
+			if err := c.Delete(ctx, obj.GetName(), metav1.DeleteOptions{}); client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			forgetAppliedVersion(r.ControllerName(), client.ObjectKeyFromObject(gwParent), obj)
+			orphanedObjectsDeleted.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Inc()
+			r.EventRecorder().Eventf(gwParent, corev1.EventTypeNormal, EventReasonPruned,
+				"Deleted %s %s no longer rendered from template %s", gvk.Kind, obj.GetName(), configmapKey)
+		}
+		if found {
+			remaining = append(remaining, gvk)
+		}
+	}
+	return remaining, nil
 }
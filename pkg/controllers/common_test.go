@@ -0,0 +1,450 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/tracing"
+)
+
+// indexedClient returns a fake client with the cache field indexes of the
+// controllers
+func indexedClient(scheme *runtime.Scheme, objs ...client.Object) client.Client {
+	b := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...)
+	for _, index := range fieldIndexes {
+		b = b.WithIndex(index.obj, index.field, index.extract)
+	}
+	return b.Build()
+}
+
+func TestLookupTier2GatewayClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+
+	accepted := &gateway.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio"},
+		Spec:       gateway.GatewayClassSpec{ControllerName: "istio.io/gateway-controller"},
+		Status: gateway.GatewayClassStatus{Conditions: []metav1.Condition{{
+			Type:   string(gateway.GatewayClassConditionStatusAccepted),
+			Status: metav1.ConditionTrue,
+			Reason: string(gateway.GatewayClassReasonAccepted)}}},
+	}
+	pending := &gateway.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending"},
+		Spec:       gateway.GatewayClassSpec{ControllerName: "example.com/not-installed"},
+	}
+	r := &GatewayReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(accepted, pending).Build()}
+
+	tests := []struct {
+		tier2Class string
+		reason     string
+	}{
+		{"istio", ""},
+		{"pending", ReasonTier2GatewayClassNotAccepted},
+		{"missing", ReasonTier2GatewayClassNotFound},
+		{"", string(gateway.GatewayClassReasonInvalidParameters)},
+	}
+	for _, tc := range tests {
+		cm := &corev1.ConfigMap{Data: map[string]string{"tier2GatewayClass": tc.tier2Class}}
+		_, cond, err := lookupTier2GatewayClass(context.Background(), r, cm)
+		if err != nil {
+			t.Fatalf("Unexpected error for tier-2 class %q: %v", tc.tier2Class, err)
+		}
+		if tc.reason == "" {
+			if cond != nil {
+				t.Errorf("Unexpected condition for tier-2 class %q: %+v", tc.tier2Class, cond)
+			}
+			continue
+		}
+		if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != tc.reason {
+			t.Errorf("Expected reason %q for tier-2 class %q, got %+v", tc.reason, tc.tier2Class, cond)
+		}
+	}
+}
+
+func TestParametersCondition(t *testing.T) {
+	cmNamespace := gateway.Namespace("gateway-classes")
+	gwc := &gateway.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "cloud"},
+		Spec: gateway.GatewayClassSpec{ParametersRef: &gateway.ParametersReference{Group: "v1", Kind: "ConfigMap",
+			Name: "cloud-params", Namespace: &cmNamespace}}}
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "cloud-params")
+
+	tests := []struct {
+		name       string
+		err        error
+		namespaces []string
+		message    string
+	}{
+		{"not-found", notFound, nil, "class parameters ConfigMap gateway-classes/cloud-params not found"},
+		{"not-watched", notFound, []string{"foo-infra", "bar-infra"},
+			"class parameters ConfigMap gateway-classes/cloud-params is in a namespace not watched by the controller, " +
+				"watched namespaces are foo-infra, bar-infra"},
+		{"watched", notFound, []string{"gateway-classes"}, "class parameters ConfigMap gateway-classes/cloud-params not found"},
+		{"other-error", errors.New("timeout"), nil, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cond := parametersCondition(gwc, fmt.Errorf("configmap for GatewayClass not found: %w", tc.err), tc.namespaces)
+			if tc.message == "" {
+				if cond != nil {
+					t.Errorf("Unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Reason != string(gateway.GatewayClassReasonInvalidParameters) || cond.Message != tc.message {
+				t.Errorf("Expected InvalidParameters with message %q, got %+v", tc.message, cond)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateHostnames(t *testing.T) {
+	cm := &corev1.ConfigMap{}
+	cmdata, err := os.ReadFile("../../test-data/gateway-class-configmap.yaml")
+	if err != nil {
+		t.Fatalf("Cannot read configmap: %v", err)
+	}
+	_ = yaml.Unmarshal(cmdata, cm)
+
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-gateway", Namespace: "foo-infra"}}
+	hostnames := []string{"foo.example.com", "bar.example.com"}
+	certs := splitCertificates(hostnames, 1)
+	values := &albTemplateValues{Gateway: gw, Hostnames: hostnames, Certificates: certs}
+	obj, err := renderTemplate(values, cm, "albTemplate")
+	if err != nil {
+		t.Fatalf("Cannot render template: %v", err)
+	}
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	if len(rules) != 2 {
+		t.Fatalf("Expected a rule per hostname, got %+v", rules)
+	}
+	host, _, _ := unstructured.NestedString(rules[1].(map[string]any), "host")
+	if host != "bar.example.com" {
+		t.Errorf("Unexpected host in rule: %q", host)
+	}
+	tls, _, _ := unstructured.NestedSlice(obj.Object, "spec", "tls")
+	if len(tls) != 2 {
+		t.Fatalf("Expected a TLS entry per certificate, got %+v", tls)
+	}
+	secretName, _, _ := unstructured.NestedString(tls[1].(map[string]any), "secretName")
+	if secretName != "foo-gateway-tls-1" {
+		t.Errorf("Unexpected TLS secret name: %q", secretName)
+	}
+
+	values.Certificate = &certs[1]
+	obj, err = renderTemplate(values, cm, "tlsCertificateTemplate")
+	if err != nil {
+		t.Fatalf("Cannot render template: %v", err)
+	}
+	dnsNames, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "dnsNames")
+	if obj.GetName() != "foo-gateway-cert-1" || !reflect.DeepEqual(dnsNames, []string{"foo.example.com"}) {
+		t.Errorf("Unexpected certificate: %+v", obj)
+	}
+}
+
+func TestTemplateErrorEvent(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &GatewayReconciler{recorder: recorder}
+	cm := &corev1.ConfigMap{Data: map[string]string{"albTemplate": "name: {{ .Name "}}
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-gateway", Namespace: "foo-infra"}}
+
+	_, err := createUpdateFromTemplate(context.Background(), r, nil, &albTemplateValues{Gateway: gw}, cm, nil, "albTemplate")
+	if err == nil {
+		t.Fatalf("Expected template error")
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, "Warning TemplateError Unable to render template albTemplate") {
+			t.Errorf("Unexpected event: %q", event)
+		}
+	default:
+		t.Errorf("Expected event for template error")
+	}
+}
+
+func TestLookupGatewayClassSpan(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+	r := &GatewayReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+	if _, _, err := lookupGatewayClass(context.Background(), r, "missing"); err == nil {
+		t.Fatalf("Expected error looking up missing class")
+	}
+	ended := spans.Ended()
+	if len(ended) != 1 || ended[0].Name() != "lookupGatewayClass" {
+		t.Fatalf("Expected lookupGatewayClass span, got %v", ended)
+	}
+	attrs := ended[0].Attributes()
+	if len(attrs) != 1 || attrs[0] != tracing.GatewayClassKey.String("missing") {
+		t.Errorf("Unexpected span attributes: %v", attrs)
+	}
+	if len(ended[0].Events()) != 1 {
+		t.Errorf("Expected error recorded on span")
+	}
+}
+
+func TestControllerInstances(t *testing.T) {
+	const otherControllerName = "example.com/internal-gateway-controller"
+	cm := &corev1.ConfigMap{Data: map[string]string{"tier2GatewayClass": "istio"}}
+	rt := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo-site"}}
+
+	defaultRt, _ := (&HTTPRouteReconciler{controllerName: config.DefaultControllerName}).constructHTTPRoute(rt, cm, nil)
+	otherRt, _ := (&HTTPRouteReconciler{controllerName: otherControllerName}).constructHTTPRoute(rt, cm, nil)
+	if defaultRt.Name != "foo-istio" {
+		t.Errorf("Unexpected shadow route name %q", defaultRt.Name)
+	}
+	if otherRt.Name == defaultRt.Name {
+		t.Errorf("Expected separate shadow routes, got %q", otherRt.Name)
+	}
+
+	tests := []struct {
+		obj            client.Object
+		controllerName gateway.GatewayController
+		managed        bool
+	}{
+		{defaultRt, config.DefaultControllerName, true},
+		{defaultRt, otherControllerName, false},
+		{otherRt, otherControllerName, true},
+		{otherRt, config.DefaultControllerName, false},
+		{rt, config.DefaultControllerName, true},
+		{rt, otherControllerName, false},
+	}
+	for _, tc := range tests {
+		if managed := managedBy(tc.obj, tc.controllerName); managed != tc.managed {
+			t.Errorf("Expected %s managed by %s to be %t", tc.obj.GetName(), tc.controllerName, tc.managed)
+		}
+	}
+}
+
+func TestPruneTemplateObjects(t *testing.T) {
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		"albListenerTemplate": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}
+data:
+  port: "{{ .Listener.Port }}"`,
+	}}
+	gw := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-gateway", Namespace: "foo-infra", UID: "foo-uid"},
+		Spec: gateway.GatewaySpec{
+			Listeners: []gateway.Listener{
+				{Name: "web", Port: 80, Protocol: gateway.HTTPProtocolType},
+				{Name: "websecure", Port: 443, Protocol: gateway.HTTPSProtocolType},
+			},
+		},
+	}
+	owned := func(obj *unstructured.Unstructured, configmapKey string) *unstructured.Unstructured {
+		obj.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(gw, gateway.SchemeGroupVersion.WithKind("Gateway"))})
+		obj.SetLabels(map[string]string{templateLabel: configmapKey})
+		return obj
+	}
+	configMap := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace(gw.Namespace)
+		return obj
+	}
+
+	// Objects applied in a previous reconcile, of a template since removed
+	// from the class and of a template with unmet requirements
+	var existing []runtime.Object
+	for _, values := range templateInstances(&albTemplateValues{Gateway: gw}, templateModeListener, nil) {
+		obj, err := renderTemplate(values, cm, "albListenerTemplate")
+		if err != nil {
+			t.Fatalf("Cannot render template: %v", err)
+		}
+		obj.SetNamespace(gw.Namespace)
+		existing = append(existing, owned(obj, "albListenerTemplate"))
+	}
+	existing = append(existing,
+		owned(configMap("foo-gateway-removed"), "removedTemplate"),
+		owned(configMap("foo-gateway-pending"), "pendingTemplate"),
+		configMap("not-owned"))
+	dc := dynamicfake.NewSimpleDynamicClient(clientgoscheme.Scheme, existing...)
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	r := &GatewayReconciler{
+		Client:   fake.NewClientBuilder().WithRESTMapper(mapper).Build(),
+		recorder: record.NewFakeRecorder(10),
+	}
+
+	// Listener websecure is removed from the Gateway
+	gw.Spec.Listeners = gw.Spec.Listeners[:1]
+	applied, err := renderTemplate(templateInstances(&albTemplateValues{Gateway: gw}, templateModeListener, nil)[0], cm, "albListenerTemplate")
+	if err != nil {
+		t.Fatalf("Cannot render template: %v", err)
+	}
+	kinds := mergeKinds(nil, []*unstructured.Unstructured{applied})
+	remaining, err := pruneTemplateObjects(ctx, r, dc, gw, kinds, []*unstructured.Unstructured{applied},
+		map[string]bool{"pendingTemplate": true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(remaining, kinds) {
+		t.Errorf("Unexpected remaining kinds %v", remaining)
+	}
+
+	list, err := dc.Resource(corev1.SchemeGroupVersion.WithResource("configmaps")).Namespace(gw.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, obj := range list.Items {
+		names = append(names, obj.GetName())
+	}
+	sort.Strings(names)
+	expected := []string{"foo-gateway-pending", "foo-gateway-web", "not-owned"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v after pruning, got %v", expected, names)
+	}
+}
+
+func TestTemplateKinds(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-gateway", Namespace: "foo-infra"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gw).Build()
+	kinds := []schema.GroupVersionKind{
+		{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+		{Version: "v1", Kind: "ConfigMap"},
+	}
+	if err := setTemplateKinds(context.Background(), c, gw, kinds); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value := gw.Annotations[templateKindsAnnotation]; value != "networking.k8s.io/v1/Ingress,v1/ConfigMap" {
+		t.Errorf("Unexpected annotation %q", value)
+	}
+	if parsed := templateKinds(gw); !reflect.DeepEqual(parsed, kinds) {
+		t.Errorf("Expected kinds %v, got %v", kinds, parsed)
+	}
+
+	if err := setTemplateKinds(context.Background(), c, gw, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, found := gw.Annotations[templateKindsAnnotation]; found || len(templateKinds(gw)) != 0 {
+		t.Errorf("Expected annotation removed, got %v", gw.Annotations)
+	}
+}
+
+func TestAppliedVersions(t *testing.T) {
+	gw := types.NamespacedName{Namespace: "foo-infra", Name: "foo-gateway"}
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace(gw.Namespace)
+	obj.SetName("foo-gateway-web")
+	obj.SetResourceVersion("1")
+
+	ctrlName := gateway.GatewayController(config.DefaultControllerName)
+	if !recordAppliedVersion(ctrlName, gw, obj) || recordAppliedVersion(ctrlName, gw, obj) {
+		t.Errorf("Expected only first apply of version recorded as changed")
+	}
+	if !recordAppliedVersion("example.com/other", gw, obj) {
+		t.Errorf("Expected versions of other controller instances kept separately")
+	}
+	obj.SetResourceVersion("2")
+	if !recordAppliedVersion(ctrlName, gw, obj) {
+		t.Errorf("Expected new version recorded as changed")
+	}
+
+	forgetAppliedVersion(ctrlName, gw, obj)
+	if !recordAppliedVersion(ctrlName, gw, obj) {
+		t.Errorf("Expected version of pruned object forgotten")
+	}
+	forgetAppliedVersion(ctrlName, gw, nil)
+	if _, found := appliedResourceVersions.Load(appliedGatewayKey{ctrlName, gw}); found {
+		t.Errorf("Expected versions of deleted Gateway forgotten")
+	}
+	if _, found := appliedResourceVersions.Load(appliedGatewayKey{"example.com/other", gw}); !found {
+		t.Errorf("Expected versions of other controller instances kept")
+	}
+}
+
+func TestClassRequests(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = gateway.AddToScheme(scheme)
+	cmNamespace := gateway.Namespace("default")
+	parent := gateway.Namespace("foo-infra")
+	c := indexedClient(scheme,
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cloud-params", Namespace: "default"},
+			Data: map[string]string{"tier2GatewayClass": "istio"}},
+		&gateway.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "cloud"},
+			Spec: gateway.GatewayClassSpec{ControllerName: config.DefaultControllerName,
+				ParametersRef: &gateway.ParametersReference{Group: "v1", Kind: "ConfigMap",
+					Name: "cloud-params", Namespace: &cmNamespace}}},
+		&gateway.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "istio"},
+			Spec: gateway.GatewayClassSpec{ControllerName: "istio.io/gateway-controller"}},
+		&gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo-infra"},
+			Spec: gateway.GatewaySpec{GatewayClassName: "cloud"}},
+		&gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio", Namespace: "foo-infra"},
+			Spec: gateway.GatewaySpec{GatewayClassName: "istio"}},
+		&gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo"},
+			Spec: gateway.HTTPRouteSpec{CommonRouteSpec: gateway.CommonRouteSpec{ParentRefs: []gateway.ParentReference{
+				{Name: "foo", Namespace: &parent}}}}})
+	istio := &gateway.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "istio"}}
+	params := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cloud-params", Namespace: "default"}}
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+
+	gwcr := &GatewayClassReconciler{Client: c, controllerName: config.DefaultControllerName}
+	if requests := gwcr.tier2ClassRequests(istio); len(requests) != 1 || requests[0].Name != "cloud" {
+		t.Errorf("Expected GatewayClass request cloud for tier-2 class, got %v", requests)
+	}
+	if requests := gwcr.configMapRequests(params); len(requests) != 1 || requests[0].Name != "cloud" {
+		t.Errorf("Expected GatewayClass request cloud for parameters, got %v", requests)
+	}
+
+	gwr := &GatewayReconciler{Client: c, controllerName: config.DefaultControllerName}
+	requests := gwr.gatewayClassRequests(istio)
+	sort.Slice(requests, func(i, j int) bool { return requests[i].String() < requests[j].String() })
+	if len(requests) != 2 || requests[0].String() != "foo-infra/foo" || requests[1].String() != "foo-infra/foo-istio" {
+		t.Errorf("Expected Gateway requests for tier-2 class, got %v", requests)
+	}
+	if requests := gwr.configMapRequests(params); len(requests) != 1 || requests[0].String() != "foo-infra/foo" {
+		t.Errorf("Expected Gateway request foo-infra/foo for parameters, got %v", requests)
+	}
+	if requests := gwr.configMapRequests(other); len(requests) != 0 {
+		t.Errorf("Expected no Gateway requests for unrelated ConfigMap, got %v", requests)
+	}
+
+	rtr := &HTTPRouteReconciler{Client: c, controllerName: config.DefaultControllerName}
+	if requests := rtr.configMapRequests(params); len(requests) != 1 || requests[0].String() != "foo/foo" {
+		t.Errorf("Expected HTTPRoute request foo/foo for parameters, got %v", requests)
+	}
+}
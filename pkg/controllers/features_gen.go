@@ -0,0 +1,83 @@
+//go:build ignore
+
+// features_gen writes features.go from a report of the conformance tests in
+// pkg/conformance, listing the features passing as SupportedFeatures. Run
+// with make supported-features.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// report is the part of a conformance report used, see pkg/conformance
+type report struct {
+	GatewayAPIVersion string `json:"gatewayAPIVersion"`
+	Features          []struct {
+		Name   string `json:"name"`
+		Result string `json:"result"`
+	} `json:"features"`
+}
+
+var (
+	reportPath = flag.String("report", "conformance-report.yaml", "Report of the conformance tests")
+	outputPath = flag.String("output", "pkg/controllers/features.go", "File to write")
+)
+
+func main() {
+	flag.Parse()
+	if err := generate(*reportPath, *outputPath); err != nil {
+		fmt.Fprintf(os.Stderr, "features_gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(reportPath, outputPath string) error {
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return err
+	}
+	var r report
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("parsing %s: %w", reportPath, err)
+	}
+
+	var features []string
+	for _, f := range r.Features {
+		if f.Result == "Passed" && f.Name != "Core" {
+			features = append(features, f.Name)
+		}
+	}
+	sort.Strings(features)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `// Code generated by features_gen.go from a conformance report of Gateway API %s. DO NOT EDIT.
+
+package controllers
+
+// SupportedFeatures is the features of the Gateway API conformance suite
+// supported by the controller, given a tier-2 implementation supporting them.
+// Core features are implied. The list is the features passing in a run of the
+// conformance tests in pkg/conformance with -all-features and is updated with
+// make supported-features. The GatewayClass status of the Gateway API version
+// in use has no field for supported features, the list is to be reported
+// there once it has.
+var SupportedFeatures = []string{
+`, r.GatewayAPIVersion)
+	for _, f := range features {
+		fmt.Fprintf(&buf, "\t%q,\n", f)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, src, 0o644)
+}
@@ -2,39 +2,107 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/health"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/logging"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/multicluster"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/tracing"
 )
 
+// Interval for re-checking address assignment of objects created from
+// templates
+const addressRequeueInterval = 30 * time.Second
+
 type GatewayReconciler struct {
 	client.Client
-	dynamicClient dynamic.Interface
-	scheme        *runtime.Scheme
+	dynamicClient  dynamic.Interface
+	scheme         *runtime.Scheme
+	recorder       record.EventRecorder
+	controllerName gateway.GatewayController
+	fieldManager   string
+	features       config.FeatureGates
+	namespaces     []string
+	impersonating  *impersonatingClients
+	health         *health.Registry
+	clusters       *multicluster.Registry
 }
 
 type albTemplateValues struct {
 	//Tier1 *gateway.Gateway
 	*gateway.Gateway
+
+	// Addresses is the addresses requested for the Gateway, which should be
+	// implemented by the front load balancer
+	Addresses []gateway.GatewayAddress
+
+	// Hostnames is the effective hostnames of routes attached to the Gateway
+	Hostnames []string
+
+	// Certificates is the certificates needed to cover listener and route
+	// hostnames
+	Certificates []certificateValues
+
+	// Certificate is the certificate being rendered by templates rendered
+	// per certificate and nil for other templates
+	Certificate *certificateValues
+
+	// Listener is the listener being rendered by templates rendered per
+	// listener and nil for other templates. For such templates, Hostnames is
+	// the effective hostnames of routes attached to the listener.
+	Listener *gateway.Listener
+
+	// Clusters is the member clusters with shadow Gateways in hub mode, and
+	// empty otherwise
+	Clusters []clusterValues
+
+	// Backends is the weighted addresses of shadow Gateways in member
+	// clusters in hub mode, and empty otherwise
+	Backends []backendValues
 }
 
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch
 
-func NewGatewayController(mgr ctrl.Manager) *GatewayReconciler {
+func NewGatewayController(mgr ctrl.Manager, cfg *config.ControllerConfiguration, clusters *multicluster.Registry) *GatewayReconciler {
 	r := &GatewayReconciler{
-		Client:        mgr.GetClient(),
-		dynamicClient: dynamic.NewForConfigOrDie(ctrl.GetConfigOrDie()),
-		scheme:        mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		dynamicClient:  dynamic.NewForConfigOrDie(mgr.GetConfig()),
+		scheme:         mgr.GetScheme(),
+		recorder:       mgr.GetEventRecorderFor(eventRecorderName),
+		controllerName: gateway.GatewayController(cfg.ControllerName),
+		fieldManager:   cfg.FieldManager,
+		features:       cfg.FeatureGates,
+		namespaces:     cfg.Namespaces,
+		impersonating:  newImpersonatingClients(mgr.GetConfig()),
+		health:         health.NewRegistry(),
+		clusters:       clusters,
 	}
 	return r
 }
@@ -51,89 +119,575 @@ func (r *GatewayReconciler) Scheme() *runtime.Scheme {
 	return r.scheme
 }
 
+func (r *GatewayReconciler) EventRecorder() record.EventRecorder {
+	return r.recorder
+}
+
+func (r *GatewayReconciler) ControllerName() gateway.GatewayController {
+	return r.controllerName
+}
+
+func (r *GatewayReconciler) FieldManager() string {
+	return r.fieldManager
+}
+
 func (r *GatewayReconciler) constructGateway(gwIn *gateway.Gateway, configmap *corev1.ConfigMap) (*gateway.Gateway, error) {
-	name := fmt.Sprintf("%s-%s", gwIn.ObjectMeta.Name, configmap.Data["tier2GatewayClass"])
+	name := shadowGatewayName(gwIn.ObjectMeta.Name, configmap)
 	gwOut := gwIn.DeepCopy()
 	gwOut.ResourceVersion = ""
+	// Finalizers of the original, e.g. for cleanup in hub mode, do not apply
+	// to the shadow
+	gwOut.Finalizers = nil
 	gwOut.ObjectMeta.Name = name
 	if gwOut.ObjectMeta.Annotations == nil {
 		gwOut.ObjectMeta.Annotations = map[string]string{}
 	}
 	gwOut.ObjectMeta.Annotations["networking.istio.io/service-type"] = "ClusterIP"
 	gwOut.Spec.GatewayClassName = gateway.ObjectName(configmap.Data["tier2GatewayClass"])
+	// The shadow Gateway is internal, requested addresses are implemented by the front load balancer
+	gwOut.Spec.Addresses = nil
 
 	return gwOut, nil
 }
 
-func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "GatewayReconciler.Reconcile", tracing.GatewayKey.String(req.String()))
+	defer func() { tracing.End(span, err) }()
 	log := log.FromContext(ctx)
 
 	gw := &gateway.Gateway{}
-	err := r.Get(ctx, req.NamespacedName, gw)
-	if err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	err = r.Get(ctx, req.NamespacedName, gw)
+	if apierrors.IsNotFound(err) {
+		forgetAppliedVersion(r.controllerName, req.NamespacedName, nil)
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	log = log.WithValues(logging.GatewayKey, req.String())
+	ctx = ctrl.LoggerInto(ctx, log)
+	log.V(logging.DebugLevel).Info("reconcile")
+	log.V(logging.ObjectLevel).Info("reconcile", logging.ObjectKey, logging.Object(gw))
+
+	if !gw.DeletionTimestamp.IsZero() {
+		// Objects in the local cluster are deleted by owner references
+		forgetAppliedVersion(r.controllerName, req.NamespacedName, nil)
+		if controllerutil.ContainsFinalizer(gw, memberCleanupFinalizer) {
+			return ctrl.Result{}, r.finalizeMemberGateways(ctx, gw)
+		}
+		return ctrl.Result{}, nil
 	}
-	log.Info("reconcile", "gateway", gw)
 
 	// Lookup class and configuration
 	gwclass, configmap, err := lookupGatewayClass(ctx, r, string(gw.Spec.GatewayClassName))
+	if err != nil && gwclass != nil {
+		if paramsCond := parametersCondition(gwclass, err, r.namespaces); paramsCond != nil {
+			log.Info("class parameters not usable", "reason", paramsCond.Reason, "message", paramsCond.Message)
+			changed, err := r.setCondition(ctx, gw, *paramsCond)
+			if changed {
+				r.recorder.Event(gw, corev1.EventTypeWarning, paramsCond.Reason, paramsCond.Message)
+			}
+			return ctrl.Result{}, err
+		}
+	}
 	if err != nil {
 		return ctrl.Result{}, err
 	} else if gwclass == nil || configmap == nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	span.SetAttributes(tracing.GatewayClassKey.String(gwclass.Name))
+	log = log.WithValues(logging.GatewayClassKey, gwclass.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	_, tier2Cond, err := lookupTier2GatewayClass(ctx, r, configmap)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if tier2Cond != nil {
+		log.Info("tier-2 GatewayClass not usable", "reason", tier2Cond.Reason, "message", tier2Cond.Message)
+		changed, err := r.setCondition(ctx, gw, *tier2Cond)
+		if changed {
+			r.recorder.Event(gw, corev1.EventTypeWarning, tier2Cond.Reason, tier2Cond.Message)
+		}
+		return ctrl.Result{}, err
+	}
+	_, err = r.setCondition(ctx, gw, metav1.Condition{
+		Type:   string(gateway.GatewayConditionAccepted),
+		Status: metav1.ConditionTrue,
+		Reason: string(gateway.GatewayReasonAccepted)})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.clusters != nil && controllerutil.AddFinalizer(gw, memberCleanupFinalizer) {
+		if err := r.Update(ctx, gw); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	// Create Gateway resource
 	gwOut, err := r.constructGateway(gw, configmap)
 	if err != nil {
-		log.Error(err, "unable to build Gateway object", "gateway", gw)
+		log.Error(err, "unable to build Gateway object")
 		return ctrl.Result{}, err
 	}
 
-	log.Info("create gateway", "gwOut", gwOut)
+	log.V(logging.ObjectLevel).Info("shadow gateway", logging.ObjectKey, logging.Object(gwOut))
 
 	if err := ctrl.SetControllerReference(gw, gwOut, r.Scheme()); err != nil {
-		log.Error(err, "unable to set controllerreference for gateway", "gwOut", gwOut)
+		log.Error(err, "unable to set controllerreference for gateway", logging.NameKey, gwOut.Name)
 		return ctrl.Result{}, err
 	}
 
-	gwFound := &gateway.Gateway{}
-	err = r.Get(ctx, types.NamespacedName{Name: gwOut.Name, Namespace: gwOut.Namespace}, gwFound)
-	if err != nil && errors.IsNotFound(err) {
-		log.Info("create gateway")
-		if err := r.Create(ctx, gwOut); err != nil {
-			log.Error(err, "unable to create Gateway", "gateway", gwOut)
+	// Shadow Gateways are created in the local cluster, or in each member
+	// cluster in hub mode
+	var shadows []memberGateway
+	var clusters []clusterValues
+	if r.clusters == nil {
+		gwFound, err := r.applyShadowGateway(ctx, r.Client, gw, gwOut, "")
+		if err != nil {
 			return ctrl.Result{}, err
 		}
-	} else if err == nil {
-		gwFound.Spec = gwOut.Spec
-		log.Info("update gateway", "gw", gwFound)
-		if err := r.Update(ctx, gwFound); err != nil {
-			log.Error(err, "unable to update Gateway", "gateway", gwFound)
+		shadows = []memberGateway{{gw: gwFound}}
+	} else {
+		selector, err := clusterSelector(configmap)
+		if err != nil {
+			log.Error(err, "invalid class parameters")
+			r.recorder.Eventf(gw, corev1.EventTypeWarning, string(gateway.GatewayClassReasonInvalidParameters),
+				"Invalid parameters of GatewayClass %s: %v", gwclass.Name, err)
+			return ctrl.Result{}, err
+		}
+		shadows, err = r.applyMemberGateways(ctx, gw, gwOut, selector)
+		if err != nil {
+			log.Error(err, "unable to lookup member clusters")
 			return ctrl.Result{}, err
 		}
+		clusters = memberClusterValues(shadows)
 	}
 
-	// Create ALB resource
-	err = createUpdateFromTemplate(ctx, r, gw, configmap, "albTemplate")
+	hostnames, listenerHostnames, err := r.attachedRouteHostnames(ctx, gw)
 	if err != nil {
-		log.Error(err, "unable to build alb object", "gateway", gw)
 		return ctrl.Result{}, err
 	}
+	maxNames, err := certificateMaxDNSNames(configmap)
+	if err != nil {
+		log.Error(err, "invalid class parameters")
+		r.recorder.Eventf(gw, corev1.EventTypeWarning, string(gateway.GatewayClassReasonInvalidParameters),
+			"Invalid parameters of GatewayClass %s: %v", gwclass.Name, err)
+		return ctrl.Result{}, err
+	}
+	values := templateValues(gw, hostnames, clusters, maxNames)
 
-	// Create TLS certificate resource
-	err = createUpdateFromTemplate(ctx, r, gw, configmap, "tlsCertificateTemplate")
+	templates, err := classTemplates(configmap)
+	var policy *templatePolicy
+	if err == nil {
+		policy, err = classPolicy(configmap)
+	}
+	var healthRegistry *health.Registry
+	if err == nil {
+		healthRegistry, err = r.healthRegistry(configmap)
+	}
+	if err == nil && !r.features.Enabled(config.ListenerTemplates) {
+		for _, t := range templates {
+			if t.Mode == templateModeListener {
+				err = fmt.Errorf("template %s: mode %q requires feature gate %s", t.Key, t.Mode, config.ListenerTemplates)
+			}
+		}
+	}
 	if err != nil {
-		log.Error(err, "unable to build certificate object", "gateway", gw)
+		log.Error(err, "invalid class parameters")
+		r.recorder.Eventf(gw, corev1.EventTypeWarning, string(gateway.GatewayClassReasonInvalidParameters),
+			"Invalid parameters of GatewayClass %s: %v", gwclass.Name, err)
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	dc, err := r.templateClient(configmap)
+	if err != nil {
+		log.Error(err, "invalid class parameters")
+		r.recorder.Eventf(gw, corev1.EventTypeWarning, string(gateway.GatewayClassReasonInvalidParameters),
+			"Invalid parameters of GatewayClass %s: %v", gwclass.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	// Create resources from templates, e.g. ALB and TLS certificates.
+	// Templates with unmet requirements are not applied until a later
+	// reconcile.
+	var objs []*unstructured.Unstructured
+	var pending []string
+	appliedByTemplate := map[string][]*unstructured.Unstructured{}
+	skipped := map[string]bool{}
+	for i := range templates {
+		t := &templates[i]
+		unmet, err := unmetRequirements(ctx, r.Client, healthRegistry, t, shadows, appliedByTemplate, values)
+		if err != nil {
+			log.Error(err, "unable to check template requirements", logging.TemplateKey, t.Key)
+			return ctrl.Result{}, err
+		}
+		if len(unmet) > 0 {
+			log.V(logging.DebugLevel).Info("template requirements not met", logging.TemplateKey, t.Key, "unmet", unmet)
+			pending = append(pending, fmt.Sprintf("%s waiting for %s", t.Key, strings.Join(unmet, ", ")))
+			skipped[t.Key] = true
+			continue
+		}
+		instances := templateInstances(values, t.Mode, listenerHostnames)
+		applied, err := applyTemplate(ctx, r, dc, instances, configmap, policy, t.Key)
+		var violation *policyViolationError
+		if errors.As(err, &violation) {
+			// Objects violating the policy are not applied until the
+			// class parameters or the Gateway change
+			_, err = r.setCondition(ctx, gw, metav1.Condition{
+				Type:    string(gateway.GatewayConditionProgrammed),
+				Status:  metav1.ConditionFalse,
+				Reason:  ReasonPolicyViolation,
+				Message: violation.Error()})
+			return ctrl.Result{}, err
+		}
+		if err != nil {
+			log.Error(err, "unable to build object from template", logging.TemplateKey, t.Key)
+			return ctrl.Result{}, err
+		}
+		objs = append(objs, applied...)
+		appliedByTemplate[t.Key] = applied
+	}
+
+	// Prune objects no longer rendered from templates. Kinds applied are
+	// recorded on the Gateway before pruning, such that objects are found
+	// also when their template renders nothing or is removed from the class.
+	kinds := mergeKinds(templateKinds(gw), objs)
+	if err := setTemplateKinds(ctx, r.Client, gw, kinds); err != nil {
+		return ctrl.Result{}, err
+	}
+	kinds, err = pruneTemplateObjects(ctx, r, dc, gw, kinds, objs, skipped)
+	if err != nil {
+		log.Error(err, "unable to prune objects created from templates")
+		return ctrl.Result{}, err
+	}
+	if err := setTemplateKinds(ctx, r.Client, gw, kinds); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Report addresses assigned to the objects created from templates and
+	// whether they honor the requested addresses
+	assigned := assignedAddresses(objs)
+	programmed := metav1.Condition{
+		Type:   string(gateway.GatewayConditionProgrammed),
+		Status: metav1.ConditionTrue,
+		Reason: string(gateway.GatewayReasonProgrammed)}
+	if unassigned := unassignedAddresses(values.Addresses, assigned); len(unassigned) > 0 {
+		programmed.Status = metav1.ConditionFalse
+		programmed.Reason = string(gateway.GatewayReasonAddressNotAssigned)
+		programmed.Message = fmt.Sprintf("%d of %d requested addresses not assigned", len(unassigned), len(values.Addresses))
+		result.RequeueAfter = addressRequeueInterval
+	}
+	if objHealth := healthRegistry.Aggregate(objs); objHealth.Status != health.Healthy {
+		programmed.Status = metav1.ConditionFalse
+		programmed.Reason = string(gateway.GatewayReasonPending)
+		if objHealth.Status == health.Degraded {
+			programmed.Reason = ReasonDegraded
+		}
+		programmed.Message = objHealth.Message
+		result.RequeueAfter = readinessRequeueInterval
+	}
+	if r.clusters != nil && len(shadows) == 0 {
+		programmed.Status = metav1.ConditionFalse
+		programmed.Reason = string(gateway.GatewayReasonNoResources)
+		programmed.Message = "No member clusters selected in namespace " + r.clusters.Namespace()
+	}
+	if len(pending) > 0 {
+		programmed.Status = metav1.ConditionFalse
+		programmed.Reason = string(gateway.GatewayReasonPending)
+		programmed.Message = fmt.Sprintf("%d of %d templates applied; %s", len(templates)-len(pending),
+			len(templates), strings.Join(pending, "; "))
+		result.RequeueAfter = readinessRequeueInterval
+	}
+
+	// Listener status is propagated from the shadow Gateway
+	listeners := shadowListeners(shadows)
+	propagated := propagatedListenerConditions(gw.Status.Listeners, listeners)
+	changed, err := r.updateStatus(ctx, gw, func(status *gateway.GatewayStatus) {
+		status.Addresses = assigned
+		status.Listeners = listeners
+		meta.SetStatusCondition(&status.Conditions, programmed)
+	})
+	if changed && programmed.Status == metav1.ConditionFalse {
+		r.recorder.Event(gw, corev1.EventTypeWarning, programmed.Reason, programmed.Message)
+	}
+	if r.clusters != nil && (result.RequeueAfter == 0 || result.RequeueAfter > memberRequeueInterval) {
+		result.RequeueAfter = memberRequeueInterval
+	}
+	if err == nil && changed {
+		observeStatusPropagation("Gateway", propagated)
+	}
+	return result, err
+}
+
+// applyShadowGateway creates or updates a shadow Gateway and returns the
+// shadow Gateway found or created. The cluster is the name of the member
+// cluster of the client, or empty for the local cluster.
+func (r *GatewayReconciler) applyShadowGateway(ctx context.Context, c client.Client, gw, gwOut *gateway.Gateway, cluster string) (*gateway.Gateway, error) {
+	log := log.FromContext(ctx, logging.NameKey, gwOut.Name)
+	where := ""
+	if cluster != "" {
+		log = log.WithValues(logging.ClusterKey, cluster)
+		where = " in cluster " + cluster
+	}
+
+	gwFound := &gateway.Gateway{}
+	err := c.Get(ctx, types.NamespacedName{Name: gwOut.Name, Namespace: gwOut.Namespace}, gwFound)
+	if err != nil && apierrors.IsNotFound(err) {
+		log.Info("create shadow gateway")
+		_, createSpan := tracing.Start(ctx, "createShadowGateway", tracing.NameKey.String(gwOut.Name))
+		err = c.Create(ctx, gwOut)
+		tracing.End(createSpan, err)
+		if err != nil {
+			log.Error(err, "unable to create Gateway")
+			return nil, err
+		}
+		r.recorder.Eventf(gw, corev1.EventTypeNormal, EventReasonShadowCreated, "Created shadow Gateway %s%s", gwOut.Name, where)
+		return gwOut, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !equality.Semantic.DeepEqual(gwFound.Spec, gwOut.Spec) {
+		gwFound.Spec = gwOut.Spec
+		log.Info("update shadow gateway")
+		_, updateSpan := tracing.Start(ctx, "updateShadowGateway", tracing.NameKey.String(gwFound.Name))
+		err = c.Update(ctx, gwFound)
+		tracing.End(updateSpan, err)
+		if err != nil {
+			log.Error(err, "unable to update Gateway")
+			return nil, err
+		}
+		r.recorder.Eventf(gw, corev1.EventTypeNormal, EventReasonShadowUpdated, "Updated shadow Gateway %s%s", gwFound.Name, where)
+	}
+	return gwFound, nil
+}
+
+// attachedRouteHostnames returns the effective hostnames of all HTTPRoutes
+// attached to the Gateway, both in total and per listener.
+func (r *GatewayReconciler) attachedRouteHostnames(ctx context.Context, gw *gateway.Gateway) ([]string, map[gateway.SectionName][]string, error) {
+	gwName := client.ObjectKeyFromObject(gw)
+	var rtList gateway.HTTPRouteList
+	if err := r.List(ctx, &rtList, client.MatchingFields{httpRouteParentIndex: gwName.String()}); err != nil {
+		return nil, nil, err
+	}
+
+	nsLabels := map[string]map[string]string{}
+	for i := range rtList.Items {
+		namespace := rtList.Items[i].Namespace
+		if _, found := nsLabels[namespace]; found {
+			continue
+		}
+		labels, err := routeNamespaceLabels(ctx, r, gw, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		nsLabels[namespace] = labels
+	}
+	hostnames, listenerHostnames := routeHostnames(gw, rtList.Items, nsLabels)
+	return hostnames, listenerHostnames, nil
+}
+
+// routeHostnames returns the effective hostnames of the given HTTPRoutes
+// attached to the Gateway, both in total and per listener. Labels of route
+// namespaces are keyed by namespace name.
+func routeHostnames(gw *gateway.Gateway, routes []gateway.HTTPRoute, nsLabels map[string]map[string]string) ([]string, map[gateway.SectionName][]string) {
+	gwName := client.ObjectKeyFromObject(gw)
+	hostnameSet := map[string]bool{}
+	listenerHostnameSets := map[gateway.SectionName]map[string]bool{}
+	for i := range routes {
+		rt := &routes[i]
+		for j := range rt.Spec.ParentRefs {
+			pref := &rt.Spec.ParentRefs[j]
+			if prefGwName, isGateway := parentRefGateway(pref, rt.Namespace); !isGateway || prefGwName != gwName {
+				continue
+			}
+			for k := range gw.Spec.Listeners {
+				l := &gw.Spec.Listeners[k]
+				hostnames, reason := matchRouteListener(gw, l, pref, rt, nsLabels[rt.Namespace])
+				if reason != gateway.RouteReasonAccepted {
+					continue
+				}
+				if listenerHostnameSets[l.Name] == nil {
+					listenerHostnameSets[l.Name] = map[string]bool{}
+				}
+				for _, h := range hostnames {
+					hostnameSet[h] = true
+					listenerHostnameSets[l.Name][h] = true
+				}
+			}
+		}
+	}
+
+	listenerHostnames := map[gateway.SectionName][]string{}
+	for name, set := range listenerHostnameSets {
+		listenerHostnames[name] = sortedKeys(set)
+	}
+	return sortedKeys(hostnameSet), listenerHostnames
+}
+
+// templateValues returns the values templates are rendered with for a
+// Gateway, given the effective hostnames of attached routes, the member
+// clusters in hub mode and the limit on DNS names per certificate.
+func templateValues(gw *gateway.Gateway, hostnames []string, clusters []clusterValues, maxNames int) *albTemplateValues {
+	certs := splitCertificates(certificateDNSNames(gw, hostnames), maxNames)
+	return &albTemplateValues{Gateway: gw, Hostnames: hostnames, Certificates: certs,
+		Addresses: requestedAddresses(gw), Clusters: clusters, Backends: memberBackends(clusters)}
+}
+
+// templateInstances returns the template values for each time a template is
+// to be rendered, depending on the template mode.
+func templateInstances(values *albTemplateValues, mode templateMode, listenerHostnames map[gateway.SectionName][]string) []*albTemplateValues {
+	var instances []*albTemplateValues
+	switch mode {
+	case templateModeGateway:
+		instances = append(instances, values)
+	case templateModeCertificate:
+		for i := range values.Certificates {
+			v := *values
+			v.Certificate = &values.Certificates[i]
+			instances = append(instances, &v)
+		}
+	case templateModeListener:
+		for i := range values.Gateway.Spec.Listeners {
+			v := *values
+			v.Listener = &values.Gateway.Spec.Listeners[i]
+			v.Hostnames = listenerHostnames[v.Listener.Name]
+			instances = append(instances, &v)
+		}
+	}
+	return instances
+}
+
+// templateClient returns the dynamic client used to apply objects created
+// from the templates of a class, i.e. a client impersonating the
+// ServiceAccount named in the class parameters if any.
+func (r *GatewayReconciler) templateClient(configmap *corev1.ConfigMap) (dynamic.Interface, error) {
+	user, err := templateServiceAccount(configmap)
+	if err != nil || user == "" {
+		return r.dynamicClient, err
+	}
+	return r.impersonating.forUser(user)
+}
+
+// healthRegistry returns the registry evaluating the health of objects
+// created from the templates of a class, i.e. including the rules given in
+// the 'healthRules' class parameter.
+func (r *GatewayReconciler) healthRegistry(configmap *corev1.ConfigMap) (*health.Registry, error) {
+	data, found := configmap.Data["healthRules"]
+	if !found {
+		return r.health, nil
+	}
+	rules, err := health.ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid healthRules: %w", err)
+	}
+	return r.health.With(rules)
+}
+
+// httpRouteRequests maps an HTTPRoute to requests for its parent Gateways.
+func (r *GatewayReconciler) httpRouteRequests(obj client.Object) []reconcile.Request {
+	rt := obj.(*gateway.HTTPRoute)
+	var requests []reconcile.Request
+	for i := range rt.Spec.ParentRefs {
+		if gwName, isGateway := parentRefGateway(&rt.Spec.ParentRefs[i], rt.Namespace); isGateway {
+			requests = append(requests, reconcile.Request{NamespacedName: gwName})
+		}
+	}
+	return requests
+}
+
+// setCondition sets a status condition on a Gateway and updates the status if
+// it changed.
+func (r *GatewayReconciler) setCondition(ctx context.Context, gw *gateway.Gateway, cond metav1.Condition) (bool, error) {
+	return r.updateStatus(ctx, gw, func(status *gateway.GatewayStatus) {
+		meta.SetStatusCondition(&status.Conditions, cond)
+	})
+}
+
+// updateStatus applies a modification to the status of a Gateway and updates
+// the status if it changed, which is returned. Conditions are stamped with
+// the Gateway generation.
+func (r *GatewayReconciler) updateStatus(ctx context.Context, gw *gateway.Gateway, modify func(status *gateway.GatewayStatus)) (bool, error) {
+	ctx, span := tracing.Start(ctx, "updateStatus", tracing.GatewayKey.String(client.ObjectKeyFromObject(gw).String()))
+	status := gw.Status.DeepCopy()
+	modify(status)
+	for i := range status.Conditions {
+		status.Conditions[i].ObservedGeneration = gw.Generation
+	}
+	// Listener conditions propagated from shadow Gateways carry the
+	// generation of the shadow, not of this Gateway
+	for i := range status.Listeners {
+		for j := range status.Listeners[i].Conditions {
+			status.Listeners[i].Conditions[j].ObservedGeneration = gw.Generation
+		}
+	}
+	if equality.Semantic.DeepEqual(status, &gw.Status) {
+		tracing.End(span, nil)
+		return false, nil
+	}
+	gw.Status = *status
+	err := r.Status().Update(ctx, gw)
+	tracing.End(span, err)
+	return true, err
+}
+
+// gatewayClassRequests maps a GatewayClass to requests for all Gateways using
+// the class, or using it as tier-2 class, e.g. such that changes in class
+// acceptance are reflected in Gateways.
+func (r *GatewayReconciler) gatewayClassRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	classes, err := tier2Classes(ctx, r.Client, r.controllerName, obj.GetName())
+	if err != nil {
+		log.Error(err, "unable to list GatewayClasses", logging.GatewayClassKey, obj.GetName())
+		return nil
+	}
+	gateways, err := classGateways(ctx, r.Client, append([]string{obj.GetName()}, classes...))
+	if err != nil {
+		log.Error(err, "unable to list Gateways", logging.GatewayClassKey, obj.GetName())
+		return nil
+	}
+	return requestsForGateways(gateways)
+}
+
+// configMapRequests maps a ConfigMap to requests for all Gateways of classes
+// using it as class parameters, such that parameter changes, e.g. of the
+// tier-2 class, are applied.
+func (r *GatewayReconciler) configMapRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	classes, err := parametersClasses(ctx, r.Client, r.controllerName, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		log.Error(err, "unable to list GatewayClasses")
+		return nil
+	}
+	gateways, err := classGateways(ctx, r.Client, classes)
+	if err != nil {
+		log.Error(err, "unable to list Gateways")
+		return nil
+	}
+	return requestsForGateways(gateways)
 }
 
 func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := registerResourceCollector(mgr, r.controllerName); err != nil {
+		return err
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&gateway.Gateway{}).
 		Owns(&gateway.Gateway{}). // FIXME, more types
-		Complete(r)
+		Watches(&source.Kind{Type: &gateway.GatewayClass{}},
+			handler.EnqueueRequestsFromMapFunc(r.gatewayClassRequests)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.configMapRequests)).
+		Watches(&source.Kind{Type: &gateway.HTTPRoute{}},
+			handler.EnqueueRequestsFromMapFunc(r.httpRouteRequests))
+	if r.clusters != nil {
+		b = b.Watches(r.clusters.Source(), handler.EnqueueRequestsFromMapFunc(r.gatewayClusterRequests),
+			builder.WithPredicates(gatewayClusterChanged))
+	}
+	return b.Complete(r)
 }
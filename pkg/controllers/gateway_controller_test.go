@@ -10,6 +10,7 @@ import (
 
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
@@ -23,6 +24,9 @@ metadata:
   namespace: foo-gateway-ns
 spec:
   gatewayClassName: default
+  addresses:
+  - type: IPAddress
+    value: 192.0.2.10
   listeners:
   - name: prod-web
     port: 80
@@ -54,6 +58,9 @@ func TestGatewayClass(t *testing.T) {
 	if gwOut.Spec.GatewayClassName != "istio" {
 		t.Errorf("Unexpected GatewayClassName: %+v", gwOut)
 	}
+	if len(gw.Spec.Addresses) != 1 || len(gwOut.Spec.Addresses) != 0 {
+		t.Errorf("Expected addresses to be removed from shadow gateway: %+v", gwOut.Spec.Addresses)
+	}
 }
 
 var _ = Describe("Gateway controller", func() {
@@ -86,4 +93,77 @@ var _ = Describe("Gateway controller", func() {
 			}, timeout, interval).Should(BeTrue())
 		})
 	})
+
+	Context("When the shadow gateway reports listener status", func() {
+		It("Should propagate listener status to the gateway", func() {
+			ctx := context.Background()
+
+			// Class without templates, such that the gateway status only
+			// depends on the shadow gateway
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "listener-status-gateway-class", Namespace: "default"},
+				Data:       map[string]string{"tier2GatewayClass": "istio"},
+			}
+			Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+			defaultGwc := &gateway.GatewayClass{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "cloud-gw"}, defaultGwc)).Should(Succeed())
+			cmNamespace := gateway.Namespace(cm.Namespace)
+			gwc := &gateway.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "listener-status"},
+				Spec: gateway.GatewayClassSpec{
+					ControllerName: defaultGwc.Spec.ControllerName,
+					ParametersRef: &gateway.ParametersReference{
+						Group: "v1", Kind: "ConfigMap", Name: cm.Name, Namespace: &cmNamespace},
+				},
+			}
+			Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
+
+			gw := &gateway.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Name: "listener-status-gateway", Namespace: "default"},
+				Spec: gateway.GatewaySpec{
+					GatewayClassName: "listener-status",
+					Listeners:        []gateway.Listener{{Name: "prod-web", Port: 80, Protocol: "HTTP"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, gw)).Should(Succeed())
+
+			listener := gateway.ListenerStatus{
+				Name:           "prod-web",
+				SupportedKinds: []gateway.RouteGroupKind{{Kind: "HTTPRoute"}},
+				AttachedRoutes: 2,
+			}
+			meta.SetStatusCondition(&listener.Conditions, metav1.Condition{
+				Type:               string(gateway.ListenerConditionProgrammed),
+				Status:             metav1.ConditionTrue,
+				Reason:             string(gateway.ListenerReasonProgrammed),
+				ObservedGeneration: 42})
+
+			shadowKey := types.NamespacedName{Name: gw.ObjectMeta.Name + "-istio", Namespace: gw.ObjectMeta.Namespace}
+			Eventually(func() error {
+				shadow := &gateway.Gateway{}
+				if err := k8sClient.Get(ctx, shadowKey, shadow); err != nil {
+					return err
+				}
+				shadow.Status.Listeners = []gateway.ListenerStatus{listener}
+				return k8sClient.Status().Update(ctx, shadow)
+			}, timeout, interval).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: gw.ObjectMeta.Name, Namespace: gw.ObjectMeta.Namespace}
+			Eventually(func() int32 {
+				updatedGw := &gateway.Gateway{}
+				if err := k8sClient.Get(ctx, lookupKey, updatedGw); err != nil || len(updatedGw.Status.Listeners) != 1 {
+					return -1
+				}
+				return updatedGw.Status.Listeners[0].AttachedRoutes
+			}, timeout, interval).Should(Equal(int32(2)))
+
+			By("Reporting the generation of the gateway in listener conditions")
+			updatedGw := &gateway.Gateway{}
+			Expect(k8sClient.Get(ctx, lookupKey, updatedGw)).Should(Succeed())
+			programmed := meta.FindStatusCondition(updatedGw.Status.Listeners[0].Conditions,
+				string(gateway.ListenerConditionProgrammed))
+			Expect(programmed).NotTo(BeNil())
+			Expect(programmed.ObservedGeneration).To(Equal(updatedGw.Generation))
+		})
+	})
 })
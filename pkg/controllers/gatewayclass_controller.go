@@ -2,32 +2,57 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/tracing"
 )
 
 type GatewayClassReconciler struct {
 	client.Client
-	dynamicClient dynamic.Interface
-	scheme        *runtime.Scheme
+	dynamicClient  dynamic.Interface
+	scheme         *runtime.Scheme
+	recorder       record.EventRecorder
+	controllerName gateway.GatewayController
+	fieldManager   string
+	features       config.FeatureGates
+	restMapper     meta.RESTMapper
+	namespaces     []string
 }
 
-//+kubebuilder:rbac:groups=gateway.networking.k8s.io.tutorial.kubebuilder.io,resources=gatewayclasses,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=gateway.networking.k8s.io.tutorial.kubebuilder.io,resources=gatewayclasses/status,verbs=get;update;patch
-//+kubebuilder:rbac:groups=gateway.networking.k8s.io.tutorial.kubebuilder.io,resources=gatewayclasses/finalizers,verbs=update
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=selfsubjectaccessreviews;subjectaccessreviews,verbs=create
+// Impersonating the ServiceAccount of a class is granted with a Role in the
+// namespace of the class parameters, see ServiceAccountRules
 
-func NewGatewayClassController(mgr ctrl.Manager) *GatewayClassReconciler {
+func NewGatewayClassController(mgr ctrl.Manager, cfg *config.ControllerConfiguration) *GatewayClassReconciler {
 	r := &GatewayClassReconciler{
-		Client:        mgr.GetClient(),
-		dynamicClient: dynamic.NewForConfigOrDie(ctrl.GetConfigOrDie()),
-		scheme:        mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		dynamicClient:  dynamic.NewForConfigOrDie(mgr.GetConfig()),
+		scheme:         mgr.GetScheme(),
+		recorder:       mgr.GetEventRecorderFor(eventRecorderName),
+		controllerName: gateway.GatewayController(cfg.ControllerName),
+		fieldManager:   cfg.FieldManager,
+		features:       cfg.FeatureGates,
+		restMapper:     mgr.GetRESTMapper(),
+		namespaces:     cfg.Namespaces,
 	}
 	return r
 }
@@ -44,28 +69,81 @@ func (r *GatewayClassReconciler) Scheme() *runtime.Scheme {
 	return r.scheme
 }
 
-func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *GatewayClassReconciler) EventRecorder() record.EventRecorder {
+	return r.recorder
+}
+
+func (r *GatewayClassReconciler) ControllerName() gateway.GatewayController {
+	return r.controllerName
+}
+
+func (r *GatewayClassReconciler) FieldManager() string {
+	return r.fieldManager
+}
+
+func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "GatewayClassReconciler.Reconcile", tracing.GatewayClassKey.String(req.Name))
+	defer func() { tracing.End(span, err) }()
 	//log := log.FromContext(ctx)
 
 	gwc, configmap, err := lookupGatewayClass(ctx, r, req.Name)
-	if err != nil {
+	var paramsCond *metav1.Condition
+	if err != nil && gwc != nil {
+		paramsCond = parametersCondition(gwc, err, r.namespaces)
+	}
+	if err != nil && paramsCond == nil {
 		return ctrl.Result{}, err
 	} else if gwc == nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if configmap == nil {
-		meta.SetStatusCondition(&gwc.Status.Conditions, metav1.Condition{
-			Type:   string(gateway.GatewayClassConditionStatusAccepted),
-			Status: "False",
-			Reason: string(gateway.GatewayClassReasonInvalidParameters)})
+	cond := metav1.Condition{
+		Type:   string(gateway.GatewayClassConditionStatusAccepted),
+		Status: "True",
+		Reason: string(gateway.GatewayClassReasonAccepted)}
+	if paramsCond != nil {
+		cond = *paramsCond
+	} else if configmap == nil {
+		cond.Status = "False"
+		cond.Reason = string(gateway.GatewayClassReasonInvalidParameters)
+		cond.Message = "class parameters ConfigMap not referenced"
 	} else {
-		meta.SetStatusCondition(&gwc.Status.Conditions, metav1.Condition{
-			Type:   string(gateway.GatewayClassConditionStatusAccepted),
-			Status: "True",
-			Reason: string(gateway.GatewayClassReasonAccepted)})
+		_, tier2Cond, err := lookupTier2GatewayClass(ctx, r, configmap)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if tier2Cond != nil {
+			cond = *tier2Cond
+		}
 	}
+	cond.ObservedGeneration = gwc.Generation
+	if existing := meta.FindStatusCondition(gwc.Status.Conditions, cond.Type); existing == nil ||
+		existing.Status != cond.Status || existing.Reason != cond.Reason {
+		eventType := corev1.EventTypeNormal
+		if cond.Status != metav1.ConditionTrue {
+			eventType = corev1.EventTypeWarning
+		}
+		message := fmt.Sprintf("GatewayClass accepted: %s", cond.Status)
+		if cond.Message != "" {
+			message += " " + cond.Message
+		}
+		r.recorder.Event(gwc, eventType, cond.Reason, message)
+	}
+	meta.SetStatusCondition(&gwc.Status.Conditions, cond)
+
+	if configmap != nil {
+		permCond := r.permissionsCondition(ctx, configmap)
+		permCond.ObservedGeneration = gwc.Generation
+		if existing := meta.FindStatusCondition(gwc.Status.Conditions, permCond.Type); permCond.Status != metav1.ConditionTrue &&
+			(existing == nil || existing.Status != permCond.Status || existing.Message != permCond.Message) {
+			r.recorder.Event(gwc, corev1.EventTypeWarning, permCond.Reason, permCond.Message)
+		}
+		meta.SetStatusCondition(&gwc.Status.Conditions, permCond)
+	}
+
+	_, statusSpan := tracing.Start(ctx, "updateStatus", tracing.GatewayClassKey.String(gwc.Name))
 	err = r.Status().Update(ctx, gwc)
+	tracing.End(statusSpan, err)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -73,8 +151,83 @@ func (r *GatewayClassReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	return ctrl.Result{}, nil
 }
 
+// permissionsCondition returns a condition reporting the RBAC rules needed by
+// the templates of a class and whether the controller has them.
+func (r *GatewayClassReconciler) permissionsCondition(ctx context.Context, configmap *corev1.ConfigMap) metav1.Condition {
+	cond := metav1.Condition{Type: ConditionPermissions, Status: metav1.ConditionUnknown,
+		Reason: ReasonPermissionsNotComputed}
+	rules, err := TemplateRules(r.restMapper, configmap)
+	if err != nil {
+		cond.Message = fmt.Sprintf("Unable to compute rules required by templates: %v", err)
+		return cond
+	}
+	user, err := templateServiceAccount(configmap)
+	if err != nil {
+		cond.Message = err.Error()
+		return cond
+	}
+	missing, err := MissingRules(ctx, r.Client, user, rules, r.namespaces)
+	if err != nil {
+		cond.Message = fmt.Sprintf("Unable to check rules required by templates: %v", err)
+		return cond
+	}
+	if len(missing) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = ReasonPermissionsMissing
+		cond.Message = "Missing rules required by templates: " + FormatRules(missing)
+		if user != "" {
+			cond.Message += " for " + user
+		}
+		return cond
+	}
+	cond.Status = metav1.ConditionTrue
+	cond.Reason = ReasonPermissionsGranted
+	cond.Message = "Rules required by templates: " + FormatRules(rules)
+	if user != "" {
+		cond.Message += " granted to " + user
+	}
+	return cond
+}
+
+// tier2ClassRequests maps a GatewayClass to requests for all GatewayClasses
+// we own that use it as tier-2 class, such that changes in acceptance of the
+// tier-2 class is reflected in our classes.
+func (r *GatewayClassReconciler) tier2ClassRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	classes, err := tier2Classes(ctx, r.Client, r.controllerName, obj.GetName())
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list GatewayClasses")
+		return nil
+	}
+	return classRequests(classes)
+}
+
+// configMapRequests maps a ConfigMap to requests for all GatewayClasses we
+// own that use it as class parameters.
+func (r *GatewayClassReconciler) configMapRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	classes, err := parametersClasses(ctx, r.Client, r.controllerName, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list GatewayClasses")
+		return nil
+	}
+	return classRequests(classes)
+}
+
+func classRequests(classes []string) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(classes))
+	for _, className := range classes {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: className}})
+	}
+	return requests
+}
+
 func (r *GatewayClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&gateway.GatewayClass{}).
+		Watches(&source.Kind{Type: &gateway.GatewayClass{}},
+			handler.EnqueueRequestsFromMapFunc(r.tier2ClassRequests)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.configMapRequests)).
 		Complete(r)
 }
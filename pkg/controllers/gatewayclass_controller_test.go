@@ -8,8 +8,13 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
 )
 
 var _ = Describe("GatewayClass controller", func() {
@@ -39,4 +44,56 @@ var _ = Describe("GatewayClass controller", func() {
 			}, timeout, interval).Should(BeTrue())
 		})
 	})
+
+	Context("When a gatewayclass we own references a missing tier-2 class", func() {
+		It("Should not be accepted until the tier-2 class is accepted", func() {
+			ctx := context.Background()
+
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "missing-tier2-gateway-class", Namespace: "default"},
+				Data:       map[string]string{"tier2GatewayClass": "not-installed"},
+			}
+			Expect(k8sClient.Create(ctx, cm)).Should(Succeed())
+			ns := gateway.Namespace("default")
+			gwc := &gateway.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "missing-tier2"},
+				Spec: gateway.GatewayClassSpec{
+					ControllerName: config.DefaultControllerName,
+					ParametersRef: &gateway.ParametersReference{Group: "v1", Kind: "ConfigMap",
+						Name: cm.ObjectMeta.Name, Namespace: &ns},
+				},
+			}
+			Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: gwc.ObjectMeta.Name}
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, lookupKey, gwc); err != nil {
+					return ""
+				}
+				cond := meta.FindStatusCondition(gwc.Status.Conditions, string(gateway.GatewayClassConditionStatusAccepted))
+				if cond == nil || cond.Status != metav1.ConditionFalse {
+					return ""
+				}
+				return cond.Reason
+			}, timeout, interval).Should(Equal(ReasonTier2GatewayClassNotFound))
+
+			By("Accepting the tier-2 class once it is installed and accepted")
+			tier2gwc := &gateway.GatewayClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "not-installed"},
+				Spec:       gateway.GatewayClassSpec{ControllerName: "example.com/tier2-controller"},
+			}
+			Expect(k8sClient.Create(ctx, tier2gwc)).Should(Succeed())
+			meta.SetStatusCondition(&tier2gwc.Status.Conditions, metav1.Condition{
+				Type:   string(gateway.GatewayClassConditionStatusAccepted),
+				Status: metav1.ConditionTrue,
+				Reason: string(gateway.GatewayClassReasonAccepted)})
+			Expect(k8sClient.Status().Update(ctx, tier2gwc)).Should(Succeed())
+			Eventually(func() bool {
+				if err := k8sClient.Get(ctx, lookupKey, gwc); err != nil {
+					return false
+				}
+				return meta.IsStatusConditionTrue(gwc.Status.Conditions, string(gateway.GatewayClassConditionStatusAccepted))
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
 })
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// hostnameMatches returns true if hostname matches pattern, where a pattern
+// prefixed with a wildcard label ('*.') is interpreted as a suffix match,
+// i.e. '*.example.com' matches 'foo.example.com' and 'foo.bar.example.com' but
+// not 'example.com'.
+func hostnameMatches(pattern, hostname string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(hostname, pattern[1:])
+	}
+	return pattern == hostname
+}
+
+// intersectHostname returns the intersection of a listener and route hostname
+// as defined by the Gateway API, i.e. the most specific of the two if one
+// matches the other.
+func intersectHostname(listenerHostname, routeHostname string) (string, bool) {
+	if hostnameMatches(listenerHostname, routeHostname) {
+		return routeHostname, true
+	}
+	if hostnameMatches(routeHostname, listenerHostname) {
+		return listenerHostname, true
+	}
+	return "", false
+}
+
+// intersectHostnames returns the effective hostnames of a route attached to
+// a listener. If neither listener nor route specify hostnames, any hostname
+// matches and an empty list is returned together with a true match.
+func intersectHostnames(listenerHostname *gateway.Hostname, routeHostnames []gateway.Hostname) ([]string, bool) {
+	if listenerHostname == nil || *listenerHostname == "" {
+		hostnames := make([]string, 0, len(routeHostnames))
+		for _, h := range routeHostnames {
+			hostnames = append(hostnames, string(h))
+		}
+		return hostnames, true
+	}
+	if len(routeHostnames) == 0 {
+		return []string{string(*listenerHostname)}, true
+	}
+
+	var hostnames []string
+	for _, h := range routeHostnames {
+		if hostname, found := intersectHostname(string(*listenerHostname), string(h)); found {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames, len(hostnames) > 0
+}
+
+// listenerAllowsRoute returns true if the listener allows HTTPRoutes from the
+// given namespace to attach. The namespace labels are only used with
+// selector-based namespace matching.
+func listenerAllowsRoute(l *gateway.Listener, gwNamespace, rtNamespace string, rtNamespaceLabels map[string]string) bool {
+	if l.Protocol != gateway.HTTPProtocolType && l.Protocol != gateway.HTTPSProtocolType {
+		return false
+	}
+
+	if l.AllowedRoutes == nil {
+		return gwNamespace == rtNamespace
+	}
+
+	if len(l.AllowedRoutes.Kinds) > 0 {
+		kindFound := false
+		for _, k := range l.AllowedRoutes.Kinds {
+			if k.Kind == "HTTPRoute" && (k.Group == nil || *k.Group == gateway.GroupName) {
+				kindFound = true
+			}
+		}
+		if !kindFound {
+			return false
+		}
+	}
+
+	from := gateway.NamespacesFromSame
+	if l.AllowedRoutes.Namespaces != nil && l.AllowedRoutes.Namespaces.From != nil {
+		from = *l.AllowedRoutes.Namespaces.From
+	}
+	switch from {
+	case gateway.NamespacesFromAll:
+		return true
+	case gateway.NamespacesFromSame:
+		return gwNamespace == rtNamespace
+	case gateway.NamespacesFromSelector:
+		if l.AllowedRoutes.Namespaces.Selector == nil {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(l.AllowedRoutes.Namespaces.Selector)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(labels.Set(rtNamespaceLabels))
+	}
+	return false
+}
+
+// listenersUseNamespaceSelector returns true if any listener selects allowed
+// route namespaces by label selector.
+func listenersUseNamespaceSelector(gw *gateway.Gateway) bool {
+	for i := range gw.Spec.Listeners {
+		ar := gw.Spec.Listeners[i].AllowedRoutes
+		if ar != nil && ar.Namespaces != nil && ar.Namespaces.From != nil &&
+			*ar.Namespaces.From == gateway.NamespacesFromSelector {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRouteParent matches a route against the listeners of a parent Gateway
+// selected by the parent reference. The effective hostnames across all
+// matching listeners are returned, or a reason if the route cannot attach to
+// the parent.
+func matchRouteParent(gw *gateway.Gateway, pref *gateway.ParentReference, rt *gateway.HTTPRoute,
+	rtNamespaceLabels map[string]string) ([]string, gateway.RouteConditionReason) {
+	reason := gateway.RouteReasonNoMatchingParent
+	hostnameSet := map[string]bool{}
+	for i := range gw.Spec.Listeners {
+		hostnames, listenerReason := matchRouteListener(gw, &gw.Spec.Listeners[i], pref, rt, rtNamespaceLabels)
+		switch listenerReason {
+		case gateway.RouteReasonAccepted:
+			reason = gateway.RouteReasonAccepted
+			for _, h := range hostnames {
+				hostnameSet[h] = true
+			}
+		case gateway.RouteReasonNotAllowedByListeners:
+			if reason == gateway.RouteReasonNoMatchingParent {
+				reason = listenerReason
+			}
+		case gateway.RouteReasonNoMatchingListenerHostname:
+			if reason != gateway.RouteReasonAccepted {
+				reason = listenerReason
+			}
+		}
+	}
+
+	if reason != gateway.RouteReasonAccepted {
+		return nil, reason
+	}
+	return sortedKeys(hostnameSet), reason
+}
+
+// matchRouteListener matches a route against a single listener of a parent
+// Gateway. The effective hostnames are returned, or a reason if the route
+// cannot attach to the listener.
+func matchRouteListener(gw *gateway.Gateway, l *gateway.Listener, pref *gateway.ParentReference, rt *gateway.HTTPRoute,
+	rtNamespaceLabels map[string]string) ([]string, gateway.RouteConditionReason) {
+	if pref.SectionName != nil && *pref.SectionName != l.Name {
+		return nil, gateway.RouteReasonNoMatchingParent
+	}
+	if pref.Port != nil && *pref.Port != l.Port {
+		return nil, gateway.RouteReasonNoMatchingParent
+	}
+	if !listenerAllowsRoute(l, gw.Namespace, rt.Namespace, rtNamespaceLabels) {
+		return nil, gateway.RouteReasonNotAllowedByListeners
+	}
+	hostnames, match := intersectHostnames(l.Hostname, rt.Spec.Hostnames)
+	if !match {
+		return nil, gateway.RouteReasonNoMatchingListenerHostname
+	}
+	return hostnames, gateway.RouteReasonAccepted
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
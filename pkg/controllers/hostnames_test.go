@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestIntersectHostnames(t *testing.T) {
+	hostname := func(h string) *gateway.Hostname {
+		gh := gateway.Hostname(h)
+		return &gh
+	}
+	tests := []struct {
+		listener *gateway.Hostname
+		route    []gateway.Hostname
+		expected []string
+		match    bool
+	}{
+		{nil, nil, []string{}, true},
+		{nil, []gateway.Hostname{"foo.example.com"}, []string{"foo.example.com"}, true},
+		{hostname("foo.example.com"), nil, []string{"foo.example.com"}, true},
+		{hostname("foo.example.com"), []gateway.Hostname{"foo.example.com", "bar.example.com"}, []string{"foo.example.com"}, true},
+		{hostname("*.example.com"), []gateway.Hostname{"foo.example.com", "a.b.example.com", "example.com"}, []string{"foo.example.com", "a.b.example.com"}, true},
+		{hostname("foo.example.com"), []gateway.Hostname{"*.example.com"}, []string{"foo.example.com"}, true},
+		{hostname("*.example.com"), []gateway.Hostname{"*.foo.example.com"}, []string{"*.foo.example.com"}, true},
+		{hostname("*.foo.example.com"), []gateway.Hostname{"*.example.com"}, []string{"*.foo.example.com"}, true},
+		{hostname("foo.example.com"), []gateway.Hostname{"bar.example.com"}, nil, false},
+		{hostname("*.example.com"), []gateway.Hostname{"example.com"}, nil, false},
+	}
+	for _, tc := range tests {
+		hostnames, match := intersectHostnames(tc.listener, tc.route)
+		if match != tc.match || !reflect.DeepEqual(hostnames, tc.expected) {
+			t.Errorf("Unexpected intersection of %v and %v: %v %v", tc.listener, tc.route, hostnames, match)
+		}
+	}
+}
+
+func TestMatchRouteParent(t *testing.T) {
+	hostname := gateway.Hostname("*.example.com")
+	fromAll := gateway.NamespacesFromAll
+	fromSelector := gateway.NamespacesFromSelector
+	gw := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-gateway", Namespace: "foo-infra"},
+		Spec: gateway.GatewaySpec{
+			Listeners: []gateway.Listener{
+				{Name: "all", Port: 80, Protocol: gateway.HTTPProtocolType, Hostname: &hostname,
+					AllowedRoutes: &gateway.AllowedRoutes{Namespaces: &gateway.RouteNamespaces{From: &fromAll}}},
+				{Name: "selected", Port: 443, Protocol: gateway.HTTPSProtocolType,
+					AllowedRoutes: &gateway.AllowedRoutes{Namespaces: &gateway.RouteNamespaces{From: &fromSelector,
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"gateway-access": "true"}}}}},
+				{Name: "tcp", Port: 9000, Protocol: gateway.TCPProtocolType},
+			},
+		},
+	}
+	section := func(s string) *gateway.SectionName {
+		sn := gateway.SectionName(s)
+		return &sn
+	}
+	tests := []struct {
+		section   *gateway.SectionName
+		hostnames []gateway.Hostname
+		nsLabels  map[string]string
+		expected  []string
+		reason    gateway.RouteConditionReason
+	}{
+		{nil, []gateway.Hostname{"foo.example.com"}, nil, []string{"foo.example.com"}, gateway.RouteReasonAccepted},
+		{nil, []gateway.Hostname{"foo.example.org"}, nil, nil, gateway.RouteReasonNoMatchingListenerHostname},
+		{nil, []gateway.Hostname{"foo.example.org"}, map[string]string{"gateway-access": "true"}, []string{"foo.example.org"}, gateway.RouteReasonAccepted},
+		{section("selected"), nil, nil, nil, gateway.RouteReasonNotAllowedByListeners},
+		{section("tcp"), nil, nil, nil, gateway.RouteReasonNotAllowedByListeners},
+		{section("missing"), nil, nil, nil, gateway.RouteReasonNoMatchingParent},
+	}
+	for _, tc := range tests {
+		rt := &gateway.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-route", Namespace: "foo-site"},
+			Spec:       gateway.HTTPRouteSpec{Hostnames: tc.hostnames},
+		}
+		pref := &gateway.ParentReference{Name: "foo-gateway", SectionName: tc.section}
+		hostnames, reason := matchRouteParent(gw, pref, rt, tc.nsLabels)
+		if reason != tc.reason || !reflect.DeepEqual(hostnames, tc.expected) {
+			t.Errorf("Unexpected match of section %v hostnames %v: %v %v", tc.section, tc.hostnames, hostnames, reason)
+		}
+	}
+}
@@ -3,33 +3,60 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/logging"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/multicluster"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/tracing"
 )
 
 type HTTPRouteReconciler struct {
 	client.Client
-	dynamicClient dynamic.Interface
-	scheme        *runtime.Scheme
+	dynamicClient  dynamic.Interface
+	scheme         *runtime.Scheme
+	recorder       record.EventRecorder
+	controllerName gateway.GatewayController
+	fieldManager   string
+	features       config.FeatureGates
+	clusters       *multicluster.Registry
 }
 
-//+kubebuilder:rbac:groups=gateway.networking.k8s.io.tutorial.kubebuilder.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=gateway.networking.k8s.io.tutorial.kubebuilder.io,resources=httproutes/status,verbs=get;update;patch
-//+kubebuilder:rbac:groups=gateway.networking.k8s.io.tutorial.kubebuilder.io,resources=httproutes/finalizers,verbs=update
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
-func NewHTTPRouteController(mgr ctrl.Manager) *HTTPRouteReconciler {
+func NewHTTPRouteController(mgr ctrl.Manager, cfg *config.ControllerConfiguration, clusters *multicluster.Registry) *HTTPRouteReconciler {
 	r := &HTTPRouteReconciler{
-		Client:        mgr.GetClient(),
-		dynamicClient: dynamic.NewForConfigOrDie(ctrl.GetConfigOrDie()),
-		scheme:        mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		dynamicClient:  dynamic.NewForConfigOrDie(mgr.GetConfig()),
+		scheme:         mgr.GetScheme(),
+		recorder:       mgr.GetEventRecorderFor(eventRecorderName),
+		controllerName: gateway.GatewayController(cfg.ControllerName),
+		fieldManager:   cfg.FieldManager,
+		features:       cfg.FeatureGates,
+		clusters:       clusters,
 	}
 	return r
 }
@@ -46,82 +73,392 @@ func (r *HTTPRouteReconciler) Scheme() *runtime.Scheme {
 	return r.scheme
 }
 
-func (r *HTTPRouteReconciler) constructHTTPRoute(rtIn *gateway.HTTPRoute, configmap *corev1.ConfigMap) (*gateway.HTTPRoute, error) {
+func (r *HTTPRouteReconciler) EventRecorder() record.EventRecorder {
+	return r.recorder
+}
+
+func (r *HTTPRouteReconciler) ControllerName() gateway.GatewayController {
+	return r.controllerName
+}
+
+func (r *HTTPRouteReconciler) FieldManager() string {
+	return r.fieldManager
+}
+
+func (r *HTTPRouteReconciler) constructHTTPRoute(rtIn *gateway.HTTPRoute, configmap *corev1.ConfigMap, parents []gateway.ParentReference) (*gateway.HTTPRoute, error) {
+	id := config.InstanceID(string(r.controllerName))
 	name := fmt.Sprintf("%s-%s", rtIn.ObjectMeta.Name, configmap.Data["tier2GatewayClass"])
+	if r.controllerName != config.DefaultControllerName {
+		// Separate shadow routes of routes attached to Gateways of
+		// several controller instances
+		name = fmt.Sprintf("%s-%s", name, id)
+	}
 	rtOut := rtIn.DeepCopy()
 	rtOut.ResourceVersion = ""
+	// Finalizers of the original, e.g. for cleanup in hub mode, do not apply
+	// to the shadow
+	rtOut.Finalizers = nil
 	rtOut.ObjectMeta.Name = name
-	// FIXME, should follow pattern in gateway-controller and remap parents of type gateway similarly
-	rtOut.Spec.CommonRouteSpec.ParentRefs[0].Name = "foo-gateway-istio"
+	if rtOut.Labels == nil {
+		rtOut.Labels = map[string]string{}
+	}
+	rtOut.Labels[controllerLabel] = id
+	rtOut.Spec.CommonRouteSpec.ParentRefs = parents
+	rtOut.Status = gateway.HTTPRouteStatus{}
 
 	return rtOut, nil
 }
 
-func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *HTTPRouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "HTTPRouteReconciler.Reconcile", tracing.HTTPRouteKey.String(req.String()))
+	defer func() { tracing.End(span, err) }()
 	log := log.FromContext(ctx)
 
 	rt := &gateway.HTTPRoute{}
-	err := r.Get(ctx, req.NamespacedName, rt)
+	err = r.Get(ctx, req.NamespacedName, rt)
 	if err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	log.Info("reconcile", "httproute", rt)
+	log = log.WithValues(logging.HTTPRouteKey, req.String())
+	ctx = ctrl.LoggerInto(ctx, log)
+	log.V(logging.DebugLevel).Info("reconcile")
+	log.V(logging.ObjectLevel).Info("reconcile", logging.ObjectKey, logging.Object(rt))
 
-	prefs := rt.Spec.CommonRouteSpec.ParentRefs
-	// FIXME check kind of parent ref is Gateway and missing parentRef. Accepts more than one parent ref
-	pref := prefs[0]
+	if !rt.DeletionTimestamp.IsZero() {
+		// Objects in the local cluster are deleted by owner references
+		if controllerutil.ContainsFinalizer(rt, memberCleanupFinalizer) {
+			return ctrl.Result{}, r.finalizeMemberRoutes(ctx, rt)
+		}
+		return ctrl.Result{}, nil
+	}
 
-	gw := &gateway.Gateway{}
-	err = r.Get(ctx, types.NamespacedName{Name: string(pref.Name), Namespace: string(*pref.Namespace)}, gw)
-	if err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	// Match route against parent Gateways of our classes. Accepted parents
+	// are remapped to the corresponding shadow Gateways, grouped by tier-2
+	// class.
+	var groups []*shadowRouteParents
+	groupsByClass := map[string]*shadowRouteParents{}
+	var parentStatuses []gateway.RouteParentStatus
+	for i := range rt.Spec.ParentRefs {
+		pref := &rt.Spec.ParentRefs[i]
+		gwName, isGateway := parentRefGateway(pref, rt.Namespace)
+		if !isGateway {
+			continue
+		}
+
+		gw := &gateway.Gateway{}
+		err = r.Get(ctx, gwName, gw)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		gwclass, cm, err := lookupGatewayClass(ctx, r, string(gw.Spec.GatewayClassName))
+		if err != nil {
+			return ctrl.Result{}, err
+		} else if gwclass == nil || cm == nil {
+			continue
+		}
+
+		nsLabels, err := routeNamespaceLabels(ctx, r, gw, rt.Namespace)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		hostnames, reason := matchRouteParent(gw, pref, rt, nsLabels)
+		log.V(logging.DebugLevel).Info("parent match", logging.GatewayKey, gwName.String(), "reason", reason, "hostnames", hostnames)
+
+		status := metav1.ConditionTrue
+		if reason != gateway.RouteReasonAccepted {
+			status = metav1.ConditionFalse
+		}
+		parentStatuses = append(parentStatuses, gateway.RouteParentStatus{
+			ParentRef:      *pref,
+			ControllerName: r.controllerName,
+			Conditions: []metav1.Condition{{
+				Type:               string(gateway.RouteConditionAccepted),
+				Status:             status,
+				Reason:             string(reason),
+				ObservedGeneration: rt.Generation,
+			}},
+		})
+		if reason != gateway.RouteReasonAccepted {
+			continue
+		}
+
+		shadowPref := pref.DeepCopy()
+		shadowNamespace := gateway.Namespace(gwName.Namespace)
+		shadowPref.Name = gateway.ObjectName(shadowGatewayName(gw.Name, cm))
+		shadowPref.Namespace = &shadowNamespace
+		class := cm.Data["tier2GatewayClass"]
+		group, found := groupsByClass[class]
+		if !found {
+			group = &shadowRouteParents{configmap: cm}
+			groupsByClass[class] = group
+			groups = append(groups, group)
+		}
+		group.parents = append(group.parents, *shadowPref)
+		group.statuses = append(group.statuses, len(parentStatuses)-1)
 	}
-	log.Info("reconcile", "gateway", gw)
 
-	gwclass, configmap, err := lookupGatewayClass(ctx, r, string(gw.Spec.GatewayClassName))
-	if err != nil {
-		return ctrl.Result{}, err
-	} else if gwclass == nil || configmap == nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	// In hub mode, parent status is updated below with the status of the
+	// shadow routes in member clusters
+	if r.clusters == nil || len(groups) == 0 {
+		if err := r.updateParentStatuses(ctx, rt, parentStatuses); err != nil {
+			log.Error(err, "unable to update HTTPRoute status")
+			return ctrl.Result{}, err
+		}
 	}
 
-	// Create HTTPRoute resource
-	rtOut, err := r.constructHTTPRoute(rt, configmap)
-	if err != nil {
-		log.Error(err, "unable to build HTTPRoute object", "httproute", rt)
-		return ctrl.Result{}, err
+	if len(groups) == 0 {
+		if controllerutil.ContainsFinalizer(rt, memberCleanupFinalizer) {
+			if err := r.finalizeMemberRoutes(ctx, rt); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, r.deleteShadowRoutes(ctx, rt, nil)
+	}
+
+	if r.clusters != nil {
+		if controllerutil.AddFinalizer(rt, memberCleanupFinalizer) {
+			if err := r.Update(ctx, rt); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 	}
 
-	log.Info("create httproute", "rtOut", rtOut)
+	// Create a shadow HTTPRoute per tier-2 class, in the local cluster or in
+	// each member cluster in hub mode
+	shadowNames := map[string]bool{}
+	var applyErr error
+	for _, group := range groups {
+		rtOut, err := r.constructHTTPRoute(rt, group.configmap, group.parents)
+		if err != nil {
+			log.Error(err, "unable to build HTTPRoute object")
+			return ctrl.Result{}, err
+		}
+		shadowNames[rtOut.Name] = true
+
+		log.V(logging.ObjectLevel).Info("shadow httproute", logging.ObjectKey, logging.Object(rtOut))
+
+		if err := ctrl.SetControllerReference(rt, rtOut, r.Scheme()); err != nil {
+			log.Error(err, "unable to set controllerreference for httproute", logging.NameKey, rtOut.Name)
+			return ctrl.Result{}, err
+		}
 
-	if err := ctrl.SetControllerReference(rt, rtOut, r.Scheme()); err != nil {
-		log.Error(err, "unable to set controllerreference for httproute", "rtOut", rtOut)
+		if r.clusters == nil {
+			if _, err := r.applyShadowRoute(ctx, r.Client, rt, rtOut, ""); err != nil {
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+		selector, err := clusterSelector(group.configmap)
+		if err != nil {
+			log.Error(err, "invalid class parameters")
+			return ctrl.Result{}, err
+		}
+		members, err := r.applyMemberRoutes(ctx, rt, rtOut, selector)
+		if err != nil && applyErr == nil {
+			applyErr = err
+		}
+		for i := range group.parents {
+			status := &parentStatuses[group.statuses[i]]
+			status.Conditions = append(status.Conditions, memberParentConditions(members, &group.parents[i], rt)...)
+		}
+	}
+
+	// Shadow routes of tier-2 classes no longer used by any parent are
+	// deleted
+	if r.clusters == nil {
+		return ctrl.Result{}, r.deleteShadowRoutes(ctx, rt, shadowNames)
+	}
+	if err := r.pruneMemberRoutes(ctx, rt, shadowNames); err != nil && applyErr == nil {
+		applyErr = err
+	}
+	if err := r.updateParentStatuses(ctx, rt, parentStatuses); err != nil {
+		log.Error(err, "unable to update HTTPRoute status")
 		return ctrl.Result{}, err
 	}
+	return ctrl.Result{RequeueAfter: memberRequeueInterval}, applyErr
+}
+
+// shadowRouteParents is the shadow parents of a route with the same tier-2
+// class, which share a shadow route
+type shadowRouteParents struct {
+	// Class parameters of the first parent
+	configmap *corev1.ConfigMap
+	parents   []gateway.ParentReference
+	// Index of the parent status of each shadow parent
+	statuses []int
+}
+
+// applyShadowRoute creates or updates a shadow route and returns the shadow
+// route found or created. The cluster is the name of the member cluster of
+// the client, or empty for the local cluster.
+func (r *HTTPRouteReconciler) applyShadowRoute(ctx context.Context, c client.Client, rt, rtOut *gateway.HTTPRoute, cluster string) (*gateway.HTTPRoute, error) {
+	log := log.FromContext(ctx, logging.NameKey, rtOut.Name)
+	where := ""
+	if cluster != "" {
+		log = log.WithValues(logging.ClusterKey, cluster)
+		where = " in cluster " + cluster
+	}
 
 	rtFound := &gateway.HTTPRoute{}
-	err = r.Get(ctx, types.NamespacedName{Name: rtOut.Name, Namespace: rtOut.Namespace}, rtFound)
+	err := c.Get(ctx, types.NamespacedName{Name: rtOut.Name, Namespace: rtOut.Namespace}, rtFound)
 	if err != nil && errors.IsNotFound(err) {
-		log.Info("create gateway")
-		if err := r.Create(ctx, rtOut); err != nil {
-			log.Error(err, "unable to create Gateway", "httproute", rtOut)
-			return ctrl.Result{}, err
+		log.Info("create shadow httproute")
+		_, createSpan := tracing.Start(ctx, "createShadowHTTPRoute", tracing.NameKey.String(rtOut.Name))
+		err = c.Create(ctx, rtOut)
+		tracing.End(createSpan, err)
+		if err != nil {
+			log.Error(err, "unable to create HTTPRoute")
+			return nil, err
 		}
-	} else if err == nil {
+		r.recorder.Eventf(rt, corev1.EventTypeNormal, EventReasonShadowCreated, "Created shadow HTTPRoute %s%s", rtOut.Name, where)
+		return rtOut, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !equality.Semantic.DeepEqual(rtFound.Spec, rtOut.Spec) {
 		rtFound.Spec = rtOut.Spec
-		log.Info("update httproute", "rt", rtFound)
-		if err := r.Update(ctx, rtFound); err != nil {
-			log.Error(err, "unable to update HTTPRoute", "httproute", rtFound)
-			return ctrl.Result{}, err
+		log.Info("update shadow httproute")
+		_, updateSpan := tracing.Start(ctx, "updateShadowHTTPRoute", tracing.NameKey.String(rtFound.Name))
+		err = c.Update(ctx, rtFound)
+		tracing.End(updateSpan, err)
+		if err != nil {
+			log.Error(err, "unable to update HTTPRoute")
+			return nil, err
+		}
+		r.recorder.Eventf(rt, corev1.EventTypeNormal, EventReasonShadowUpdated, "Updated shadow HTTPRoute %s%s", rtFound.Name, where)
+	}
+	return rtFound, nil
+}
+
+// updateParentStatuses replaces the route parent statuses managed by us,
+// leaving those of other controllers untouched. Condition transition times
+// are retained for conditions that did not change.
+func (r *HTTPRouteReconciler) updateParentStatuses(ctx context.Context, rt *gateway.HTTPRoute, statuses []gateway.RouteParentStatus) (err error) {
+	ctx, span := tracing.Start(ctx, "updateStatus", tracing.HTTPRouteKey.String(client.ObjectKeyFromObject(rt).String()))
+	defer func() { tracing.End(span, err) }()
+
+	var parents []gateway.RouteParentStatus
+	for i := range rt.Status.Parents {
+		if rt.Status.Parents[i].ControllerName != r.controllerName {
+			parents = append(parents, rt.Status.Parents[i])
+		}
+	}
+	for i := range statuses {
+		var existingConditions, conditions []metav1.Condition
+		for j := range rt.Status.Parents {
+			existing := &rt.Status.Parents[j]
+			if existing.ControllerName == r.controllerName && reflect.DeepEqual(existing.ParentRef, statuses[i].ParentRef) {
+				existingConditions = existing.Conditions
+				conditions = existing.DeepCopy().Conditions
+				break
+			}
+		}
+		// Conditions are set rather than assigned, such that new conditions
+		// get a transition time, which is required by the API
+		for _, cond := range statuses[i].Conditions {
+			meta.SetStatusCondition(&conditions, cond)
+		}
+		statuses[i].Conditions = conditions
+		accepted := meta.FindStatusCondition(statuses[i].Conditions, string(gateway.RouteConditionAccepted))
+		previous := meta.FindStatusCondition(existingConditions, string(gateway.RouteConditionAccepted))
+		if accepted != nil && accepted.Status == metav1.ConditionFalse &&
+			(previous == nil || previous.Status != accepted.Status || previous.Reason != accepted.Reason) {
+			r.recorder.Eventf(rt, corev1.EventTypeWarning, EventReasonParentRejected,
+				"Not accepted by parent %s: %s", statuses[i].ParentRef.Name, accepted.Reason)
+		}
+		parents = append(parents, statuses[i])
+	}
+
+	if reflect.DeepEqual(parents, rt.Status.Parents) || (len(parents) == 0 && len(rt.Status.Parents) == 0) {
+		return nil
+	}
+	rt.Status.Parents = parents
+	return r.Status().Update(ctx, rt)
+}
+
+// deleteShadowRoutes deletes shadow routes of a route, except the shadow
+// routes to keep, i.e. of tier-2 classes still used by parents of the route.
+func (r *HTTPRouteReconciler) deleteShadowRoutes(ctx context.Context, rt *gateway.HTTPRoute, keep map[string]bool) error {
+	log := log.FromContext(ctx)
+
+	var rtList gateway.HTTPRouteList
+	if err := r.List(ctx, &rtList, client.InNamespace(rt.Namespace)); err != nil {
+		return err
+	}
+	for i := range rtList.Items {
+		rtShadow := &rtList.Items[i]
+		if !metav1.IsControlledBy(rtShadow, rt) || !managedBy(rtShadow, r.controllerName) || keep[rtShadow.Name] {
+			continue
+		}
+		log.Info("delete shadow httproute", logging.NameKey, rtShadow.Name)
+		if err := r.Delete(ctx, rtShadow); client.IgnoreNotFound(err) != nil {
+			return err
 		}
+		orphanedObjectsDeleted.WithLabelValues(gateway.GroupName, gateway.GroupVersion.Version, "HTTPRoute").Inc()
+		r.recorder.Eventf(rt, corev1.EventTypeNormal, EventReasonShadowDeleted,
+			"Deleted shadow HTTPRoute %s, no parents of its tier-2 class accepted the route", rtShadow.Name)
+	}
+	return nil
+}
+
+// gatewayRequests maps a Gateway to requests for all HTTPRoutes attached to
+// it, e.g. such that listener changes are reflected in route status.
+func (r *HTTPRouteReconciler) gatewayRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	log := log.FromContext(ctx)
+
+	var rtList gateway.HTTPRouteList
+	gwName := client.ObjectKeyFromObject(obj).String()
+	if err := r.List(ctx, &rtList, client.MatchingFields{httpRouteParentIndex: gwName}); err != nil {
+		log.Error(err, "unable to list HTTPRoutes", logging.GatewayKey, gwName)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(rtList.Items))
+	for i := range rtList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&rtList.Items[i])})
 	}
+	return requests
+}
+
+// configMapRequests maps a ConfigMap to requests for all HTTPRoutes attached
+// to Gateways of classes using it as class parameters, such that shadow
+// routes follow changes of the tier-2 class.
+func (r *HTTPRouteReconciler) configMapRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	log := log.FromContext(ctx)
 
-	return ctrl.Result{}, nil
+	classes, err := parametersClasses(ctx, r.Client, r.controllerName, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		log.Error(err, "unable to list GatewayClasses")
+		return nil
+	}
+	gateways, err := classGateways(ctx, r.Client, classes)
+	if err != nil {
+		log.Error(err, "unable to list Gateways")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range gateways {
+		requests = append(requests, r.gatewayRequests(&gateways[i])...)
+	}
+	return requests
 }
 
 func (r *HTTPRouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&gateway.HTTPRoute{}).
 		Owns(&gateway.HTTPRoute{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &gateway.Gateway{}},
+			handler.EnqueueRequestsFromMapFunc(r.gatewayRequests)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.configMapRequests))
+	if r.clusters != nil {
+		b = b.Watches(r.clusters.Source(), handler.EnqueueRequestsFromMapFunc(r.gatewayClusterRequests),
+			builder.WithPredicates(gatewayClusterChanged))
+	}
+	return b.Complete(r)
 }
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// templateServiceAccount returns the user name of the ServiceAccount named in
+// the 'serviceAccount' class parameter, or an empty string if none is named.
+// The ServiceAccount is given as 'name', or 'namespace/name', and must be in
+// the namespace of the class parameters. Otherwise anyone able to create class
+// parameters could apply templates as any ServiceAccount in the cluster.
+func templateServiceAccount(configmap *corev1.ConfigMap) (string, error) {
+	value, found := configmap.Data["serviceAccount"]
+	if !found || value == "" {
+		return "", nil
+	}
+	namespace, name, found := strings.Cut(value, "/")
+	if !found {
+		namespace, name = configmap.Namespace, value
+	}
+	for _, v := range []string{namespace, name} {
+		if errs := validation.IsDNS1123Subdomain(v); len(errs) > 0 {
+			return "", fmt.Errorf("invalid serviceAccount %q: %s", value, strings.Join(errs, ", "))
+		}
+	}
+	if namespace != configmap.Namespace {
+		return "", fmt.Errorf("invalid serviceAccount %q: must be in namespace %s of the class parameters",
+			value, configmap.Namespace)
+	}
+	return serviceaccount.MakeUsername(namespace, name), nil
+}
+
+// impersonatingClients creates dynamic clients impersonating users, cached
+// per user
+type impersonatingClients struct {
+	config  *rest.Config
+	mu      sync.Mutex
+	clients map[string]dynamic.Interface
+}
+
+func newImpersonatingClients(config *rest.Config) *impersonatingClients {
+	return &impersonatingClients{config: config, clients: map[string]dynamic.Interface{}}
+}
+
+// forUser returns a dynamic client impersonating a user. Groups are not
+// impersonated, the API server adds the groups of ServiceAccount users.
+func (c *impersonatingClients) forUser(user string) (dynamic.Interface, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if dc, found := c.clients[user]; found {
+		return dc, nil
+	}
+	config := rest.CopyConfig(c.config)
+	config.Impersonate = rest.ImpersonationConfig{UserName: user}
+	dc, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[user] = dc
+	return dc, nil
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestTemplateServiceAccount(t *testing.T) {
+	tests := []struct {
+		value string
+		user  string
+		err   bool
+	}{
+		{"", "", false},
+		{"templates", "system:serviceaccount:default:templates", false},
+		{"default/templates", "system:serviceaccount:default:templates", false},
+		{"kube-system/templates", "", true},
+		{"default/Templates", "", true},
+		{"/templates", "", true},
+	}
+	for _, tc := range tests {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Data: map[string]string{"serviceAccount": tc.value}}
+		user, err := templateServiceAccount(cm)
+		if (err != nil) != tc.err {
+			t.Errorf("Unexpected error for %q: %v", tc.value, err)
+		}
+		if user != tc.user {
+			t.Errorf("Expected user %q for %q, got %q", tc.user, tc.value, user)
+		}
+	}
+}
+
+func TestImpersonatingClients(t *testing.T) {
+	clients := newImpersonatingClients(&rest.Config{Host: "https://localhost:6443"})
+	user := "system:serviceaccount:net-team:templates"
+	dc, err := clients.forUser(user)
+	if err != nil {
+		t.Fatalf("Cannot create client: %v", err)
+	}
+	cached, _ := clients.forUser(user)
+	if dc != cached {
+		t.Errorf("Expected client cached per user")
+	}
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const metricsNamespace = "cloud_gateway_controller"
+
+var (
+	templateRenderFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "template_render_failures_total",
+		Help:      "Number of failures rendering class templates",
+	}, []string{"template"})
+
+	applyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "apply_duration_seconds",
+		Help:      "Latency of applying objects created from templates",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"group", "version", "kind"})
+
+	orphanedObjectsDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "orphaned_objects_deleted_total",
+		Help:      "Number of objects deleted because they are no longer rendered from templates or routed",
+	}, []string{"group", "version", "kind"})
+
+	statusPropagationLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "status_propagation_lag_seconds",
+		Help:      "Time from a condition transition of a shadow resource until it is propagated to the status of its parent",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(templateRenderFailures, applyDuration, orphanedObjectsDeleted, statusPropagationLag)
+}
+
+// resourceCollector collects metrics on resources from the manager cache when
+// scraped. Metrics are labeled with the controller name, such that controller
+// instances in the same process register separate collectors.
+type resourceCollector struct {
+	client           client.Client
+	controllerName   gateway.GatewayController
+	gatewaysDesc     *prometheus.Desc
+	shadowRoutesDesc *prometheus.Desc
+}
+
+func newResourceCollector(c client.Client, controllerName gateway.GatewayController) *resourceCollector {
+	labels := prometheus.Labels{"controller": string(controllerName)}
+	return &resourceCollector{
+		client:         c,
+		controllerName: controllerName,
+		gatewaysDesc: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "gateways"),
+			"Number of Gateways managed by the controller by class and Programmed condition status",
+			[]string{"class", "programmed"}, labels),
+		shadowRoutesDesc: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "shadow_routes"),
+			"Number of shadow HTTPRoutes managed by the controller", nil, labels),
+	}
+}
+
+// registerResourceCollector registers a resource collector for a controller
+// name with the controller-runtime metrics registry, unless already
+// registered.
+func registerResourceCollector(mgr ctrl.Manager, controllerName gateway.GatewayController) error {
+	err := metrics.Registry.Register(newResourceCollector(mgr.GetClient(), controllerName))
+	if are := (prometheus.AlreadyRegisteredError{}); errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
+
+func (c *resourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.gatewaysDesc
+	ch <- c.shadowRoutesDesc
+}
+
+func (c *resourceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	var gwcList gateway.GatewayClassList
+	var gwList gateway.GatewayList
+	if c.client.List(ctx, &gwcList) == nil && c.client.List(ctx, &gwList) == nil {
+		type key struct{ class, programmed string }
+		counts := map[key]int{}
+		for i := range gwcList.Items {
+			if gwcList.Items[i].Spec.ControllerName != c.controllerName {
+				continue
+			}
+			for _, status := range []metav1.ConditionStatus{metav1.ConditionTrue, metav1.ConditionFalse, metav1.ConditionUnknown} {
+				counts[key{gwcList.Items[i].Name, string(status)}] = 0
+			}
+		}
+		for i := range gwList.Items {
+			gw := &gwList.Items[i]
+			programmed := string(metav1.ConditionUnknown)
+			if cond := meta.FindStatusCondition(gw.Status.Conditions, string(gateway.GatewayConditionProgrammed)); cond != nil {
+				programmed = string(cond.Status)
+			}
+			k := key{string(gw.Spec.GatewayClassName), programmed}
+			if _, found := counts[k]; found {
+				counts[k]++
+			}
+		}
+		for k, count := range counts {
+			ch <- prometheus.MustNewConstMetric(c.gatewaysDesc, prometheus.GaugeValue, float64(count), k.class, k.programmed)
+		}
+	}
+
+	var rtList gateway.HTTPRouteList
+	if c.client.List(ctx, &rtList) == nil {
+		shadowRoutes := 0
+		for i := range rtList.Items {
+			if owner := metav1.GetControllerOf(&rtList.Items[i]); owner != nil && owner.Kind == "HTTPRoute" &&
+				managedBy(&rtList.Items[i], c.controllerName) {
+				shadowRoutes++
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(c.shadowRoutesDesc, prometheus.GaugeValue, float64(shadowRoutes))
+	}
+}
+
+// observeApplyDuration records the latency of applying an object
+func observeApplyDuration(gvk schema.GroupVersionKind, start time.Time) {
+	applyDuration.WithLabelValues(gvk.Group, gvk.Version, gvk.Kind).Observe(time.Since(start).Seconds())
+}
+
+// observeStatusPropagation records the lag from each condition transition
+// of a shadow resource until it was propagated to the parent. Only conditions
+// not already present in the status of the parent are to be given, such that
+// conditions propagated in earlier reconciles are not observed again.
+func observeStatusPropagation(kind string, propagated []metav1.Condition) {
+	for i := range propagated {
+		if t := propagated[i].LastTransitionTime; !t.IsZero() {
+			statusPropagationLag.WithLabelValues(kind).Observe(time.Since(t.Time).Seconds())
+		}
+	}
+}
+
+// propagatedListenerConditions returns the conditions of listeners that are
+// not in the previous listener status with the same status and transition
+// time, i.e. the condition transitions propagated from a shadow Gateway
+func propagatedListenerConditions(previous, listeners []gateway.ListenerStatus) []metav1.Condition {
+	var propagated []metav1.Condition
+	for i := range listeners {
+		var conditions []metav1.Condition
+		for j := range previous {
+			if previous[j].Name == listeners[i].Name {
+				conditions = previous[j].Conditions
+			}
+		}
+		for _, cond := range listeners[i].Conditions {
+			found := meta.FindStatusCondition(conditions, cond.Type)
+			if found == nil || found.Status != cond.Status || !found.LastTransitionTime.Equal(&cond.LastTransitionTime) {
+				propagated = append(propagated, cond)
+			}
+		}
+	}
+	return propagated
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+)
+
+func TestResourceCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+
+	gwc := &gateway.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "cloud-gw"},
+		Spec:       gateway.GatewayClassSpec{ControllerName: config.DefaultControllerName},
+	}
+	programmed := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "programmed", Namespace: "default"},
+		Spec:       gateway.GatewaySpec{GatewayClassName: "cloud-gw"},
+		Status: gateway.GatewayStatus{Conditions: []metav1.Condition{{
+			Type:   string(gateway.GatewayConditionProgrammed),
+			Status: metav1.ConditionTrue,
+			Reason: string(gateway.GatewayReasonProgrammed)}}},
+	}
+	pending := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"},
+		Spec:       gateway.GatewaySpec{GatewayClassName: "cloud-gw"},
+	}
+	shadow := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending-istio", Namespace: "default"},
+		Spec:       gateway.GatewaySpec{GatewayClassName: "istio"},
+	}
+	isController := true
+	rt := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt", Namespace: "default", UID: "1234"}}
+	rtShadow := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt-istio", Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: gateway.GroupVersion.String(), Kind: "HTTPRoute",
+			Name: "rt", UID: "1234", Controller: &isController}}}}
+
+	otherShadow := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "rt-other", Namespace: "default",
+		Labels: map[string]string{controllerLabel: config.InstanceID("example.com/other")},
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: gateway.GroupVersion.String(), Kind: "HTTPRoute",
+			Name: "rt", UID: "1234", Controller: &isController}}}}
+
+	c := newResourceCollector(fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(gwc, programmed, pending, shadow, rt, rtShadow, otherShadow).Build(), config.DefaultControllerName)
+
+	expected := `
+# HELP cloud_gateway_controller_gateways Number of Gateways managed by the controller by class and Programmed condition status
+# TYPE cloud_gateway_controller_gateways gauge
+cloud_gateway_controller_gateways{class="cloud-gw",controller="%[1]s",programmed="False"} 0
+cloud_gateway_controller_gateways{class="cloud-gw",controller="%[1]s",programmed="True"} 1
+cloud_gateway_controller_gateways{class="cloud-gw",controller="%[1]s",programmed="Unknown"} 1
+# HELP cloud_gateway_controller_shadow_routes Number of shadow HTTPRoutes managed by the controller
+# TYPE cloud_gateway_controller_shadow_routes gauge
+cloud_gateway_controller_shadow_routes{controller="%[1]s"} 1
+`
+	expected = fmt.Sprintf(expected, config.DefaultControllerName)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Errorf("Unexpected metrics: %v", err)
+	}
+}
+
+func TestPropagatedListenerConditions(t *testing.T) {
+	transition := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	programmed := metav1.Condition{
+		Type:               string(gateway.ListenerConditionProgrammed),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gateway.ListenerReasonProgrammed),
+		LastTransitionTime: transition,
+	}
+	previous := []gateway.ListenerStatus{{Name: "http", AttachedRoutes: 1, Conditions: []metav1.Condition{programmed}}}
+
+	// Route attachment changes without condition transitions are not
+	// propagated transitions
+	listeners := []gateway.ListenerStatus{{Name: "http", AttachedRoutes: 2, Conditions: []metav1.Condition{programmed}}}
+	if propagated := propagatedListenerConditions(previous, listeners); len(propagated) != 0 {
+		t.Errorf("Expected no propagated conditions, got %+v", propagated)
+	}
+
+	notProgrammed := programmed
+	notProgrammed.Status = metav1.ConditionFalse
+	notProgrammed.LastTransitionTime = metav1.Now()
+	listeners = []gateway.ListenerStatus{
+		{Name: "http", Conditions: []metav1.Condition{notProgrammed}},
+		{Name: "https", Conditions: []metav1.Condition{programmed}},
+	}
+	propagated := propagatedListenerConditions(previous, listeners)
+	if len(propagated) != 2 || propagated[0].Status != metav1.ConditionFalse || propagated[1].Status != metav1.ConditionTrue {
+		t.Errorf("Expected transition and condition of new listener propagated, got %+v", propagated)
+	}
+}
+
+func TestRegisterResourceCollectors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	c := fake.NewClientBuilder().Build()
+	if err := registry.Register(newResourceCollector(c, config.DefaultControllerName)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := registry.Register(newResourceCollector(c, "example.com/other")); err != nil {
+		t.Errorf("Expected collectors of other controller names registered, got %v", err)
+	}
+}
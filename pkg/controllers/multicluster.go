@@ -0,0 +1,535 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/logging"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/multicluster"
+)
+
+// Finalizer set on Gateways and HTTPRoutes in hub mode, such that shadow
+// objects in member clusters, which cannot be owned by the hub object, are
+// deleted with it
+const memberCleanupFinalizer = "cloud-gateway-controller.pixelperfekt.dk/member-cleanup"
+
+// Label set on shadow objects in member clusters identifying the hub object
+const hubObjectLabel = "cloud-gateway-controller.pixelperfekt.dk/hub-object"
+
+// Interval between reconciles in hub mode, since the status of shadow objects
+// in member clusters is not watched. Changes of the member clusters themselves
+// are watched through GatewayClusters.
+const memberRequeueInterval = 30 * time.Second
+
+// Condition type and reasons of route parents in hub mode, reporting how many
+// member clusters accepted the shadow route
+const (
+	RouteConditionClustersAccepted = "ClustersAccepted"
+	ReasonAllClustersAccepted      = "AllClustersAccepted"
+	ReasonPartiallyAccepted        = "PartiallyAccepted"
+	ReasonNoClustersAccepted       = "NoClustersAccepted"
+)
+
+// clusterValues describes a member cluster to templates
+type clusterValues struct {
+	// Name of the member cluster
+	Name string
+
+	// Addresses of the shadow Gateway in the member cluster, i.e. the
+	// backends of the front load balancer
+	Addresses []gateway.GatewayAddress
+
+	// Programmed is true if the shadow Gateway in the member cluster is
+	// programmed
+	Programmed bool
+
+	// Labels of the GatewayCluster registering the member cluster, e.g.
+	// region and zone labels
+	Labels map[string]string
+
+	// Weight of traffic to the member cluster relative to other clusters,
+	// zero if draining or not ready
+	Weight int
+
+	// Draining is true if traffic is drained from the member cluster
+	Draining bool
+
+	// Ready is false if the last probe of the member cluster failed
+	Ready bool
+}
+
+// backendValues is a backend of the front load balancer, i.e. an address of
+// the shadow Gateway in a member cluster
+type backendValues struct {
+	// Cluster is the name of the member cluster
+	Cluster string
+
+	// Address of the shadow Gateway
+	Address gateway.GatewayAddress
+
+	// Weight of traffic to the backend, i.e. the weight of the member
+	// cluster, zero if draining or not ready
+	Weight int
+}
+
+// memberGateway is a shadow Gateway in a cluster, with an empty cluster name
+// and nil member for the local cluster. The Gateway is nil if it could not be
+// applied.
+type memberGateway struct {
+	cluster string
+	member  *multicluster.Cluster
+	gw      *gateway.Gateway
+}
+
+// memberRoute is a shadow route in a member cluster. The route is nil if it
+// could not be applied.
+type memberRoute struct {
+	cluster string
+	rt      *gateway.HTTPRoute
+}
+
+// NewClusterRegistry returns the registry of member clusters shared by the
+// controllers if hub mode is enabled, and nil otherwise
+func NewClusterRegistry(mgr ctrl.Manager, cfg *config.ControllerConfiguration) (*multicluster.Registry, error) {
+	if cfg.Multicluster.ClusterSecretNamespace == "" {
+		return nil, nil
+	}
+	return multicluster.NewCachedRegistry(mgr, cfg.Multicluster.ClusterSecretNamespace)
+}
+
+// NewClusterProber returns a prober of the member clusters of a registry, or
+// nil if hub mode is not enabled
+func NewClusterProber(mgr ctrl.Manager, clusters *multicluster.Registry) *multicluster.Prober {
+	if clusters == nil {
+		return nil
+	}
+	return multicluster.NewProber(clusters, mgr.GetClient(), memberRequeueInterval)
+}
+
+// gatewayClusterChanged filters out GatewayCluster status updates, e.g. from
+// probing, which do not change the member clusters of Gateways. Annotation
+// changes may change the weight of a cluster.
+var gatewayClusterChanged = predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{},
+	predicate.AnnotationChangedPredicate{})
+
+// managedGateways returns the Gateways of classes managed by the controller
+func managedGateways(ctx context.Context, c client.Client, controllerName gateway.GatewayController) ([]gateway.Gateway, error) {
+	var gwcList gateway.GatewayClassList
+	if err := c.List(ctx, &gwcList); err != nil {
+		return nil, err
+	}
+	var classes []string
+	for i := range gwcList.Items {
+		if gwcList.Items[i].Spec.ControllerName == controllerName {
+			classes = append(classes, gwcList.Items[i].Name)
+		}
+	}
+	return classGateways(ctx, c, classes)
+}
+
+// gatewayClusterRequests maps a GatewayCluster to requests for all Gateways
+// managed, since the member clusters of any Gateway may change
+func (r *GatewayReconciler) gatewayClusterRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	gateways, err := managedGateways(ctx, r.Client, r.controllerName)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Gateways", logging.ClusterKey, obj.GetName())
+		return nil
+	}
+	return requestsForGateways(gateways)
+}
+
+// gatewayClusterRequests maps a GatewayCluster to requests for all HTTPRoutes
+// attached to Gateways managed, since the member clusters of any route may
+// change
+func (r *HTTPRouteReconciler) gatewayClusterRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	gateways, err := managedGateways(ctx, r.Client, r.controllerName)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Gateways", logging.ClusterKey, obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	seen := map[reconcile.Request]bool{}
+	for i := range gateways {
+		for _, req := range r.gatewayRequests(&gateways[i]) {
+			if !seen[req] {
+				seen[req] = true
+				requests = append(requests, req)
+			}
+		}
+	}
+	return requests
+}
+
+// clusterSelector returns the selector of member clusters given by class
+// parameters, selecting all member clusters if not specified
+func clusterSelector(configmap *corev1.ConfigMap) (labels.Selector, error) {
+	data, found := configmap.Data["clusterSelector"]
+	if !found {
+		return labels.Everything(), nil
+	}
+	var selector metav1.LabelSelector
+	if err := yaml.UnmarshalStrict([]byte(data), &selector); err != nil {
+		return nil, fmt.Errorf("invalid clusterSelector: %w", err)
+	}
+	s, err := metav1.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clusterSelector: %w", err)
+	}
+	return s, nil
+}
+
+// hubObjectLabelValue returns the value of the hub object label, i.e. the
+// name of the hub object or an identifier of the name if the name is not a
+// valid label value
+func hubObjectLabelValue(name string) string {
+	if len(validation.IsValidLabelValue(name)) == 0 {
+		return name
+	}
+	return config.InstanceID(name)
+}
+
+// memberLabels returns the labels of shadow objects in member clusters
+func memberLabels(controllerName gateway.GatewayController, hubObject client.Object) map[string]string {
+	return map[string]string{
+		controllerLabel: config.InstanceID(string(controllerName)),
+		hubObjectLabel:  hubObjectLabelValue(hubObject.GetName()),
+	}
+}
+
+// applyMemberGateways creates or updates the shadow Gateway in each member
+// cluster selected and deletes it from clusters not selected. Clusters where
+// the shadow Gateway cannot be applied are logged and reported with a nil
+// Gateway, such that unavailable clusters do not affect other clusters.
+func (r *GatewayReconciler) applyMemberGateways(ctx context.Context, gw, gwOut *gateway.Gateway, selector labels.Selector) ([]memberGateway, error) {
+	log := log.FromContext(ctx)
+	clusters, err := r.clusters.Clusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]memberGateway, 0, len(clusters))
+	for _, cluster := range clusters {
+		if !selector.Matches(labels.Set(cluster.Labels)) {
+			if err := r.deleteMemberGateways(ctx, cluster, gw); err != nil {
+				log.Error(err, "unable to delete shadow gateway", logging.ClusterKey, cluster.Name)
+			}
+			continue
+		}
+		desired := gwOut.DeepCopy()
+		desired.OwnerReferences = nil
+		if desired.Labels == nil {
+			desired.Labels = map[string]string{}
+		}
+		for k, v := range memberLabels(r.controllerName, gw) {
+			desired.Labels[k] = v
+		}
+		shadow, err := r.applyShadowGateway(ctx, cluster.Client, gw, desired, cluster.Name)
+		if err != nil {
+			log.Error(err, "unable to apply shadow gateway", logging.ClusterKey, cluster.Name)
+			r.recorder.Eventf(gw, corev1.EventTypeWarning, EventReasonApplyFailed,
+				"Unable to apply shadow Gateway %s in cluster %s: %v", desired.Name, cluster.Name, err)
+		}
+		members = append(members, memberGateway{cluster: cluster.Name, member: cluster, gw: shadow})
+	}
+	return members, nil
+}
+
+// memberClusterValues returns the template values of member clusters
+func memberClusterValues(members []memberGateway) []clusterValues {
+	values := make([]clusterValues, 0, len(members))
+	for _, m := range members {
+		v := clusterValues{Name: m.cluster, Ready: true}
+		if m.member != nil {
+			v.Labels = m.member.Labels
+			v.Weight = m.member.EffectiveWeight()
+			v.Draining = m.member.Draining
+			v.Ready = m.member.Ready
+		}
+		if m.gw != nil {
+			v.Addresses = m.gw.Status.Addresses
+			v.Programmed = meta.IsStatusConditionTrue(m.gw.Status.Conditions, string(gateway.GatewayConditionProgrammed))
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// memberBackends returns the backends of the front load balancer, i.e. each
+// address of the shadow Gateways in member clusters with the weight of the
+// cluster. Draining clusters are included with zero weight, such that
+// load balancers can drain connections.
+func memberBackends(clusters []clusterValues) []backendValues {
+	var backends []backendValues
+	for _, c := range clusters {
+		for _, a := range c.Addresses {
+			backends = append(backends, backendValues{Cluster: c.Name, Address: a, Weight: c.Weight})
+		}
+	}
+	return backends
+}
+
+// finalizeMemberGateways deletes the shadow Gateways of a Gateway in all
+// member clusters and removes the finalizer. The finalizer is removed right
+// away if hub mode has been disabled. Clusters that are not ready are skipped,
+// such that unreachable clusters do not block deletion, leaving their shadow
+// Gateways behind.
+func (r *GatewayReconciler) finalizeMemberGateways(ctx context.Context, gw *gateway.Gateway) error {
+	if r.clusters != nil {
+		clusters, err := r.clusters.Clusters(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range clusters {
+			if !cluster.Ready {
+				r.recorder.Eventf(gw, corev1.EventTypeWarning, EventReasonShadowOrphaned,
+					"Shadow Gateways in cluster %s not deleted, cluster not ready", cluster.Name)
+				continue
+			}
+			if err := r.deleteMemberGateways(ctx, cluster, gw); err != nil {
+				return err
+			}
+		}
+	}
+	controllerutil.RemoveFinalizer(gw, memberCleanupFinalizer)
+	return r.Update(ctx, gw)
+}
+
+// deleteMemberGateways deletes the shadow Gateways of a Gateway in a member
+// cluster
+func (r *GatewayReconciler) deleteMemberGateways(ctx context.Context, cluster *multicluster.Cluster, gw *gateway.Gateway) error {
+	log := log.FromContext(ctx)
+	var gwList gateway.GatewayList
+	if err := cluster.Client.List(ctx, &gwList, client.InNamespace(gw.Namespace),
+		client.MatchingLabels(memberLabels(r.controllerName, gw))); err != nil {
+		return err
+	}
+	for i := range gwList.Items {
+		shadow := &gwList.Items[i]
+		log.Info("delete shadow gateway", logging.NameKey, shadow.Name, logging.ClusterKey, cluster.Name)
+		if err := cluster.Client.Delete(ctx, shadow); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.recorder.Eventf(gw, corev1.EventTypeNormal, EventReasonShadowDeleted,
+			"Deleted shadow Gateway %s in cluster %s", shadow.Name, cluster.Name)
+	}
+	return nil
+}
+
+// shadowListeners returns the listener status of the first shadow Gateway
+// found, i.e. of the shadow Gateway in the local cluster or of the first member
+// cluster in hub mode
+func shadowListeners(shadows []memberGateway) []gateway.ListenerStatus {
+	for _, s := range shadows {
+		if s.gw != nil {
+			return s.gw.Status.DeepCopy().Listeners
+		}
+	}
+	return nil
+}
+
+// applyMemberRoutes creates or updates the shadow route in each member
+// cluster selected and deletes it from clusters not selected. All clusters
+// are attempted and the shadow routes of selected clusters are returned with
+// the first error.
+func (r *HTTPRouteReconciler) applyMemberRoutes(ctx context.Context, rt, rtOut *gateway.HTTPRoute, selector labels.Selector) ([]memberRoute, error) {
+	log := log.FromContext(ctx)
+	clusters, err := r.clusters.Clusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var members []memberRoute
+	var firstErr error
+	for _, cluster := range clusters {
+		if !selector.Matches(labels.Set(cluster.Labels)) {
+			remove := func(name string) bool { return name == rtOut.Name }
+			if err := r.deleteMemberRoutes(ctx, cluster, rt, remove); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		desired := rtOut.DeepCopy()
+		desired.OwnerReferences = nil
+		for k, v := range memberLabels(r.controllerName, rt) {
+			desired.Labels[k] = v
+		}
+		shadow, err := r.applyShadowRoute(ctx, cluster.Client, rt, desired, cluster.Name)
+		if err != nil {
+			log.Error(err, "unable to apply shadow httproute", logging.ClusterKey, cluster.Name)
+			r.recorder.Eventf(rt, corev1.EventTypeWarning, EventReasonApplyFailed,
+				"Unable to apply shadow HTTPRoute %s in cluster %s: %v", desired.Name, cluster.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		members = append(members, memberRoute{cluster: cluster.Name, rt: shadow})
+	}
+	return members, firstErr
+}
+
+// memberParentConditions returns the conditions of a route parent merged from
+// the status of the shadow routes in member clusters, i.e. a ClustersAccepted
+// condition counting the clusters where the parent accepted the shadow route,
+// and a ResolvedRefs condition if reported by any cluster, which is false if
+// false in any cluster. The shadow parent is the parent of the shadow routes
+// corresponding to the route parent.
+func memberParentConditions(members []memberRoute, shadowParent *gateway.ParentReference, rt *gateway.HTTPRoute) []metav1.Condition {
+	var accepted int
+	var rejected []string
+	var resolvedRefs *metav1.Condition
+	for _, m := range members {
+		var status *gateway.RouteParentStatus
+		if m.rt != nil {
+			status = findParentStatus(m.rt.Status.Parents, shadowParent, rt.Namespace)
+		}
+		if status == nil {
+			rejected = append(rejected, m.cluster+": pending")
+			continue
+		}
+		if cond := meta.FindStatusCondition(status.Conditions, string(gateway.RouteConditionAccepted)); cond != nil &&
+			cond.Status == metav1.ConditionTrue {
+			accepted++
+		} else if cond != nil {
+			rejected = append(rejected, m.cluster+": "+cond.Reason)
+		} else {
+			rejected = append(rejected, m.cluster+": pending")
+		}
+		cond := meta.FindStatusCondition(status.Conditions, string(gateway.RouteConditionResolvedRefs))
+		if cond != nil && (resolvedRefs == nil || resolvedRefs.Status == metav1.ConditionTrue) {
+			resolvedRefs = &metav1.Condition{
+				Type:    cond.Type,
+				Status:  cond.Status,
+				Reason:  cond.Reason,
+				Message: fmt.Sprintf("Cluster %s: %s", m.cluster, cond.Message),
+			}
+		}
+	}
+
+	clustersAccepted := metav1.Condition{
+		Type:               RouteConditionClustersAccepted,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonAllClustersAccepted,
+		Message:            fmt.Sprintf("%d of %d member clusters accepted the route", accepted, len(members)),
+		ObservedGeneration: rt.Generation,
+	}
+	if len(rejected) > 0 {
+		clustersAccepted.Message += "; " + strings.Join(rejected, ", ")
+	}
+	switch {
+	case accepted == 0:
+		clustersAccepted.Status = metav1.ConditionFalse
+		clustersAccepted.Reason = ReasonNoClustersAccepted
+	case accepted < len(members):
+		clustersAccepted.Status = metav1.ConditionFalse
+		clustersAccepted.Reason = ReasonPartiallyAccepted
+	}
+	conditions := []metav1.Condition{clustersAccepted}
+	if resolvedRefs != nil {
+		resolvedRefs.ObservedGeneration = rt.Generation
+		conditions = append(conditions, *resolvedRefs)
+	}
+	return conditions
+}
+
+// findParentStatus returns the status of a parent in a list of route parent
+// statuses, or nil if not found. Parents without namespace are in the
+// namespace of the route.
+func findParentStatus(statuses []gateway.RouteParentStatus, parent *gateway.ParentReference, routeNamespace string) *gateway.RouteParentStatus {
+	namespace := func(p *gateway.ParentReference) gateway.Namespace {
+		if p.Namespace == nil {
+			return gateway.Namespace(routeNamespace)
+		}
+		return *p.Namespace
+	}
+	for i := range statuses {
+		ref := &statuses[i].ParentRef
+		if ref.Name == parent.Name && namespace(ref) == namespace(parent) &&
+			reflect.DeepEqual(ref.SectionName, parent.SectionName) && reflect.DeepEqual(ref.Port, parent.Port) {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// finalizeMemberRoutes deletes the shadow routes of a route in all member
+// clusters and removes the finalizer. The finalizer is removed right away if
+// hub mode has been disabled. Clusters that are not ready are skipped like
+// for Gateways.
+func (r *HTTPRouteReconciler) finalizeMemberRoutes(ctx context.Context, rt *gateway.HTTPRoute) error {
+	if r.clusters != nil {
+		clusters, err := r.clusters.Clusters(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cluster := range clusters {
+			if !cluster.Ready {
+				r.recorder.Eventf(rt, corev1.EventTypeWarning, EventReasonShadowOrphaned,
+					"Shadow routes in cluster %s not deleted, cluster not ready", cluster.Name)
+				continue
+			}
+			if err := r.deleteMemberRoutes(ctx, cluster, rt, nil); err != nil {
+				return err
+			}
+		}
+	}
+	controllerutil.RemoveFinalizer(rt, memberCleanupFinalizer)
+	return r.Update(ctx, rt)
+}
+
+// pruneMemberRoutes deletes the shadow routes of a route in all member
+// clusters, except the shadow routes to keep
+func (r *HTTPRouteReconciler) pruneMemberRoutes(ctx context.Context, rt *gateway.HTTPRoute, keep map[string]bool) error {
+	clusters, err := r.clusters.Clusters(ctx)
+	if err != nil {
+		return err
+	}
+	remove := func(name string) bool { return !keep[name] }
+	for _, cluster := range clusters {
+		if err := r.deleteMemberRoutes(ctx, cluster, rt, remove); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMemberRoutes deletes the shadow routes of a route in a member
+// cluster, or only those with names selected by remove if not nil
+func (r *HTTPRouteReconciler) deleteMemberRoutes(ctx context.Context, cluster *multicluster.Cluster, rt *gateway.HTTPRoute, remove func(name string) bool) error {
+	log := log.FromContext(ctx)
+	var rtList gateway.HTTPRouteList
+	if err := cluster.Client.List(ctx, &rtList, client.InNamespace(rt.Namespace),
+		client.MatchingLabels(memberLabels(r.controllerName, rt))); err != nil {
+		return err
+	}
+	for i := range rtList.Items {
+		shadow := &rtList.Items[i]
+		if remove != nil && !remove(shadow.Name) {
+			continue
+		}
+		log.Info("delete shadow httproute", logging.NameKey, shadow.Name, logging.ClusterKey, cluster.Name)
+		if err := cluster.Client.Delete(ctx, shadow); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.recorder.Eventf(rt, corev1.EventTypeNormal, EventReasonShadowDeleted,
+			"Deleted shadow HTTPRoute %s in cluster %s", shadow.Name, cluster.Name)
+	}
+	return nil
+}
@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/multicluster"
+)
+
+// unreachableClient fails all requests like a client of an unreachable
+// cluster
+type unreachableClient struct {
+	client.Client
+}
+
+func (unreachableClient) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("cluster unreachable")
+}
+
+// testClusters returns a registry of member clusters with fake clients and
+// the clients by cluster name. Clusters are labeled with their name as
+// region. A cluster named unreachable is not ready and its client fails.
+func testClusters(t *testing.T, scheme *runtime.Scheme, names ...string) (*multicluster.Registry, map[string]client.Client) {
+	hubScheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(hubScheme)
+	_ = v1alpha1.AddToScheme(hubScheme)
+	var objs []client.Object
+	members := map[string]client.Client{}
+	hosts := map[string]client.Client{}
+	for _, name := range names {
+		host := fmt.Sprintf("https://%s.example.com", name)
+		gc := &v1alpha1.GatewayCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "clusters",
+				Labels: map[string]string{corev1.LabelTopologyRegion: name}},
+			Spec: v1alpha1.GatewayClusterSpec{KubeconfigSecretRef: v1alpha1.SecretKeyReference{Name: name}},
+		}
+		members[name] = fake.NewClientBuilder().WithScheme(scheme).Build()
+		if name == "unreachable" {
+			gc.Status.Conditions = []metav1.Condition{{Type: v1alpha1.GatewayClusterConditionReady,
+				Status: metav1.ConditionFalse, Reason: v1alpha1.GatewayClusterReasonUnreachable}}
+			members[name] = unreachableClient{members[name]}
+		}
+		hosts[host] = members[name]
+		objs = append(objs, gc, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "clusters"},
+			Data: map[string][]byte{v1alpha1.DefaultKubeconfigKey: []byte(fmt.Sprintf(
+				"apiVersion: v1\nkind: Config\nclusters: [{name: c, cluster: {server: %q}}]\n"+
+					"contexts: [{name: c, context: {cluster: c}}]\ncurrent-context: c\n", host))},
+		})
+	}
+	hub := fake.NewClientBuilder().WithScheme(hubScheme).WithObjects(objs...).Build()
+	return multicluster.NewRegistry(hub, "clusters", func(config *rest.Config) (client.Client, error) {
+		return hosts[config.Host], nil
+	}), members
+}
+
+func TestMemberGateways(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+	registry, members := testClusters(t, scheme, "blue", "green")
+
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo-infra",
+		Finalizers: []string{memberCleanupFinalizer}}}
+	r := &GatewayReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gw).Build(),
+		recorder: record.NewFakeRecorder(10), controllerName: config.DefaultControllerName, clusters: registry}
+	gwOut := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio", Namespace: "foo-infra",
+		OwnerReferences: []metav1.OwnerReference{{Name: "foo"}}},
+		Spec: gateway.GatewaySpec{GatewayClassName: "istio"}}
+
+	shadows, err := r.applyMemberGateways(ctx, gw, gwOut, labels.Everything())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(shadows) != 2 || shadows[0].cluster != "blue" || shadows[1].cluster != "green" {
+		t.Fatalf("Expected shadow Gateways in blue and green, got %+v", shadows)
+	}
+	for name, c := range members {
+		shadow := &gateway.Gateway{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: "foo-infra", Name: "foo-istio"}, shadow); err != nil {
+			t.Fatalf("Expected shadow Gateway in cluster %s: %v", name, err)
+		}
+		if len(shadow.OwnerReferences) != 0 || shadow.Labels[hubObjectLabel] != "foo" {
+			t.Errorf("Unexpected shadow Gateway in cluster %s: %+v", name, shadow.ObjectMeta)
+		}
+	}
+
+	// Member shadow status is passed to templates
+	shadows[0].gw.Status.Addresses = []gateway.GatewayAddress{{Value: "192.0.2.1"}}
+	shadows[0].gw.Status.Conditions = []metav1.Condition{{Type: string(gateway.GatewayConditionProgrammed),
+		Status: metav1.ConditionTrue}}
+	values := memberClusterValues(append(shadows, memberGateway{cluster: "down"}))
+	if len(values) != 3 || !values[0].Programmed || len(values[0].Addresses) != 1 ||
+		values[1].Programmed || values[2].Name != "down" {
+		t.Errorf("Unexpected cluster values: %+v", values)
+	}
+	if values[0].Weight != v1alpha1.DefaultWeight || values[0].Labels[corev1.LabelTopologyRegion] != "blue" {
+		t.Errorf("Expected default weight and labels, got %+v", values[0])
+	}
+
+	// Only clusters with addresses are backends of the front load balancer
+	backends := memberBackends(values)
+	if len(backends) != 1 || backends[0].Cluster != "blue" || backends[0].Address.Value != "192.0.2.1" ||
+		backends[0].Weight != v1alpha1.DefaultWeight {
+		t.Errorf("Unexpected backends: %+v", backends)
+	}
+
+	// Shadow Gateways are deleted from clusters no longer selected
+	selector, _ := clusterSelector(&corev1.ConfigMap{Data: map[string]string{
+		"clusterSelector": "matchLabels: {topology.kubernetes.io/region: green}"}})
+	shadows, err = r.applyMemberGateways(ctx, gw, gwOut, selector)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(shadows) != 1 || shadows[0].cluster != "green" {
+		t.Fatalf("Expected shadow Gateway in green, got %+v", shadows)
+	}
+	var blueList gateway.GatewayList
+	_ = members["blue"].List(ctx, &blueList)
+	if len(blueList.Items) != 0 {
+		t.Errorf("Expected shadow Gateway in blue deleted")
+	}
+
+	if err := r.finalizeMemberGateways(ctx, gw); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, c := range members {
+		var gwList gateway.GatewayList
+		_ = c.List(ctx, &gwList)
+		if len(gwList.Items) != 0 {
+			t.Errorf("Expected shadow Gateway in cluster %s deleted", name)
+		}
+	}
+	if controllerutil.ContainsFinalizer(gw, memberCleanupFinalizer) {
+		t.Errorf("Expected finalizer removed")
+	}
+}
+
+func TestMemberRoutes(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+	registry, members := testClusters(t, scheme, "blue", "green")
+
+	rt := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "foo-store",
+		Finalizers: []string{memberCleanupFinalizer}}}
+	r := &HTTPRouteReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(rt).Build(),
+		recorder: record.NewFakeRecorder(10), controllerName: config.DefaultControllerName, clusters: registry}
+	rtOut, _ := r.constructHTTPRoute(rt, &corev1.ConfigMap{Data: map[string]string{"tier2GatewayClass": "istio"}}, nil)
+
+	shadows, err := r.applyMemberRoutes(ctx, rt, rtOut, labels.Everything())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(shadows) != 2 || shadows[0].cluster != "blue" || shadows[1].cluster != "green" {
+		t.Fatalf("Expected shadow routes in blue and green, got %+v", shadows)
+	}
+	for name, c := range members {
+		shadow := &gateway.HTTPRoute{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: "foo-store", Name: "store-istio"}, shadow); err != nil {
+			t.Fatalf("Expected shadow route in cluster %s: %v", name, err)
+		}
+	}
+
+	// Status of shadow routes in member clusters is returned
+	shadow := &gateway.HTTPRoute{}
+	_ = members["blue"].Get(ctx, client.ObjectKey{Namespace: "foo-store", Name: "store-istio"}, shadow)
+	shadow.Status.Parents = []gateway.RouteParentStatus{{ParentRef: gateway.ParentReference{Name: "foo-istio"}}}
+	if err := members["blue"].Status().Update(ctx, shadow); err != nil {
+		t.Fatalf("Cannot update shadow route status: %v", err)
+	}
+	shadows, _ = r.applyMemberRoutes(ctx, rt, rtOut, labels.Everything())
+	if len(shadows[0].rt.Status.Parents) != 1 || len(shadows[1].rt.Status.Parents) != 0 {
+		t.Errorf("Expected status of shadow route in blue, got %+v", shadows)
+	}
+
+	if err := r.finalizeMemberRoutes(ctx, rt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for name, c := range members {
+		var rtList gateway.HTTPRouteList
+		_ = c.List(ctx, &rtList)
+		if len(rtList.Items) != 0 {
+			t.Errorf("Expected shadow route in cluster %s deleted", name)
+		}
+	}
+}
+
+func TestFinalizeUnreadyMembers(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(scheme)
+	registry, members := testClusters(t, scheme, "blue", "unreachable")
+	shadow := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio", Namespace: "foo-infra",
+		Labels: memberLabels(config.DefaultControllerName, &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})}}
+	if err := members["blue"].Create(ctx, shadow); err != nil {
+		t.Fatalf("Cannot create shadow Gateway: %v", err)
+	}
+
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo-infra",
+		Finalizers: []string{memberCleanupFinalizer}}}
+	rt := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "foo-store",
+		Finalizers: []string{memberCleanupFinalizer}}}
+	hub := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gw, rt).Build()
+	recorder := record.NewFakeRecorder(10)
+	gwr := &GatewayReconciler{Client: hub, recorder: recorder, controllerName: config.DefaultControllerName,
+		clusters: registry}
+	rtr := &HTTPRouteReconciler{Client: hub, recorder: recorder, controllerName: config.DefaultControllerName,
+		clusters: registry}
+
+	// Unreachable clusters do not block removal of finalizers
+	if err := gwr.finalizeMemberGateways(ctx, gw); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := rtr.finalizeMemberRoutes(ctx, rt); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if controllerutil.ContainsFinalizer(gw, memberCleanupFinalizer) ||
+		controllerutil.ContainsFinalizer(rt, memberCleanupFinalizer) {
+		t.Errorf("Expected finalizers removed")
+	}
+	var gwList gateway.GatewayList
+	_ = members["blue"].List(ctx, &gwList)
+	if len(gwList.Items) != 0 {
+		t.Errorf("Expected shadow Gateway in blue deleted")
+	}
+
+	var orphaned []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, EventReasonShadowOrphaned) {
+			orphaned = append(orphaned, event)
+		}
+	}
+	if len(orphaned) != 2 || !strings.Contains(orphaned[0], "in cluster unreachable not deleted") {
+		t.Errorf("Expected events for shadow objects left in cluster unreachable, got %v", orphaned)
+	}
+}
+
+func TestClusterSelector(t *testing.T) {
+	testCases := map[string]struct {
+		selector    string
+		expectError bool
+		matches     map[string]bool
+	}{
+		"all": {
+			matches: map[string]bool{"eu-west-1": true, "us-east-1": true},
+		},
+		"region": {
+			selector: "matchLabels: {topology.kubernetes.io/region: eu-west-1}",
+			matches:  map[string]bool{"eu-west-1": true, "us-east-1": false},
+		},
+		"expression": {
+			selector: "matchExpressions: [{key: topology.kubernetes.io/region, operator: NotIn, values: [eu-west-1]}]",
+			matches:  map[string]bool{"eu-west-1": false, "us-east-1": true},
+		},
+		"invalid": {
+			selector:    "matchExpressions: [{key: region, operator: Near}]",
+			expectError: true,
+		},
+		"unknown field": {
+			selector:    "region: eu-west-1",
+			expectError: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{Data: map[string]string{}}
+			if tc.selector != "" {
+				cm.Data["clusterSelector"] = tc.selector
+			}
+			selector, err := clusterSelector(cm)
+			if tc.expectError {
+				if err == nil {
+					t.Fatalf("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for region, expected := range tc.matches {
+				if selector.Matches(labels.Set{corev1.LabelTopologyRegion: region}) != expected {
+					t.Errorf("Expected match of region %s to be %v", region, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestMemberParentConditions(t *testing.T) {
+	rt := &gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "foo-store", Generation: 2}}
+	ns := gateway.Namespace("foo-infra")
+	shadowParent := gateway.ParentReference{Name: "foo-istio", Namespace: &ns}
+	shadow := func(accepted metav1.ConditionStatus, reason string, resolvedRefs metav1.ConditionStatus) *gateway.HTTPRoute {
+		status := gateway.RouteParentStatus{ParentRef: shadowParent, Conditions: []metav1.Condition{{
+			Type: string(gateway.RouteConditionAccepted), Status: accepted, Reason: reason}}}
+		if resolvedRefs != "" {
+			status.Conditions = append(status.Conditions, metav1.Condition{
+				Type: string(gateway.RouteConditionResolvedRefs), Status: resolvedRefs, Reason: "Refs"})
+		}
+		return &gateway.HTTPRoute{Status: gateway.HTTPRouteStatus{RouteStatus: gateway.RouteStatus{
+			Parents: []gateway.RouteParentStatus{status}}}}
+	}
+	otherParent := &gateway.HTTPRoute{Status: gateway.HTTPRouteStatus{RouteStatus: gateway.RouteStatus{
+		Parents: []gateway.RouteParentStatus{{ParentRef: gateway.ParentReference{Name: "other"}}}}}}
+
+	testCases := map[string]struct {
+		members        []memberRoute
+		expectStatus   metav1.ConditionStatus
+		expectReason   string
+		expectMessage  string
+		expectResolved metav1.ConditionStatus
+	}{
+		"all accepted": {
+			members: []memberRoute{
+				{cluster: "blue", rt: shadow(metav1.ConditionTrue, "Accepted", metav1.ConditionTrue)},
+				{cluster: "green", rt: shadow(metav1.ConditionTrue, "Accepted", "")},
+			},
+			expectStatus:   metav1.ConditionTrue,
+			expectReason:   ReasonAllClustersAccepted,
+			expectMessage:  "2 of 2 member clusters accepted the route",
+			expectResolved: metav1.ConditionTrue,
+		},
+		"partially accepted": {
+			members: []memberRoute{
+				{cluster: "blue", rt: shadow(metav1.ConditionTrue, "Accepted", metav1.ConditionTrue)},
+				{cluster: "green", rt: shadow(metav1.ConditionFalse, "NotAllowedByListeners", metav1.ConditionFalse)},
+				{cluster: "red", rt: otherParent},
+			},
+			expectStatus:   metav1.ConditionFalse,
+			expectReason:   ReasonPartiallyAccepted,
+			expectMessage:  "1 of 3 member clusters accepted the route; green: NotAllowedByListeners, red: pending",
+			expectResolved: metav1.ConditionFalse,
+		},
+		"none accepted": {
+			members: []memberRoute{
+				{cluster: "blue"},
+			},
+			expectStatus:  metav1.ConditionFalse,
+			expectReason:  ReasonNoClustersAccepted,
+			expectMessage: "0 of 1 member clusters accepted the route; blue: pending",
+		},
+		"no clusters": {
+			expectStatus:  metav1.ConditionFalse,
+			expectReason:  ReasonNoClustersAccepted,
+			expectMessage: "0 of 0 member clusters accepted the route",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conditions := memberParentConditions(tc.members, &shadowParent, rt)
+			accepted := meta.FindStatusCondition(conditions, RouteConditionClustersAccepted)
+			if accepted == nil || accepted.Status != tc.expectStatus || accepted.Reason != tc.expectReason ||
+				accepted.Message != tc.expectMessage || accepted.ObservedGeneration != rt.Generation {
+				t.Errorf("Unexpected condition %+v", accepted)
+			}
+			resolved := meta.FindStatusCondition(conditions, string(gateway.RouteConditionResolvedRefs))
+			if (resolved == nil && tc.expectResolved != "") || (resolved != nil && resolved.Status != tc.expectResolved) {
+				t.Errorf("Expected ResolvedRefs %q, got %+v", tc.expectResolved, resolved)
+			}
+		})
+	}
+}
+
+func TestGatewayClusterRequests(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = gateway.AddToScheme(scheme)
+	parent := gateway.Namespace("foo-infra")
+	objs := []client.Object{
+		&gateway.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "cloud"},
+			Spec: gateway.GatewayClassSpec{ControllerName: config.DefaultControllerName}},
+		&gateway.GatewayClass{ObjectMeta: metav1.ObjectMeta{Name: "istio"},
+			Spec: gateway.GatewayClassSpec{ControllerName: "istio.io/gateway-controller"}},
+		&gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo-infra"},
+			Spec: gateway.GatewaySpec{GatewayClassName: "cloud"}},
+		&gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio", Namespace: "foo-infra"},
+			Spec: gateway.GatewaySpec{GatewayClassName: "istio"}},
+		&gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "foo"},
+			Spec: gateway.HTTPRouteSpec{CommonRouteSpec: gateway.CommonRouteSpec{ParentRefs: []gateway.ParentReference{
+				{Name: "foo", Namespace: &parent}}}}},
+		&gateway.HTTPRoute{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio", Namespace: "foo"},
+			Spec: gateway.HTTPRouteSpec{CommonRouteSpec: gateway.CommonRouteSpec{ParentRefs: []gateway.ParentReference{
+				{Name: "foo-istio", Namespace: &parent}}}}},
+	}
+	c := indexedClient(scheme, objs...)
+	gwc := &v1alpha1.GatewayCluster{ObjectMeta: metav1.ObjectMeta{Name: "blue", Namespace: "clusters"}}
+
+	gwr := &GatewayReconciler{Client: c, controllerName: config.DefaultControllerName}
+	requests := gwr.gatewayClusterRequests(gwc)
+	if len(requests) != 1 || requests[0].String() != "foo-infra/foo" {
+		t.Errorf("Expected Gateway request foo-infra/foo, got %v", requests)
+	}
+
+	rtr := &HTTPRouteReconciler{Client: c, controllerName: config.DefaultControllerName}
+	requests = rtr.gatewayClusterRequests(gwc)
+	if len(requests) != 1 || requests[0].String() != "foo/foo" {
+		t.Errorf("Expected HTTPRoute request foo/foo, got %v", requests)
+	}
+}
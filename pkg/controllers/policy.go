@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// Condition reason used when objects rendered from templates violate the
+// policy of the class
+const ReasonPolicyViolation = "PolicyViolation"
+
+// templatePolicy is the 'policy' class parameter restricting the objects
+// rendered from templates. Objects are checked before they are applied.
+type templatePolicy struct {
+	// Kinds objects may have. Any kind is allowed if empty.
+	AllowedKinds []policyKind `json:"allowedKinds,omitempty"`
+
+	// Namespaces objects may be applied to, where objects without a namespace
+	// are applied to the namespace of the Gateway. Any namespace is allowed
+	// if empty.
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// CEL expressions objects must satisfy
+	Rules []policyRule `json:"rules,omitempty"`
+
+	programs []cel.Program
+}
+
+// policyKind is a kind allowed by a policy. An empty group is the core group.
+type policyKind struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+}
+
+// policyRule is a CEL expression evaluated with the rendered object as
+// 'object' and the parent Gateway as 'gateway'. The expression must evaluate
+// to true for the object to be applied.
+type policyRule struct {
+	Expression string `json:"expression"`
+	Message    string `json:"message,omitempty"`
+}
+
+// policyViolationError is returned when a rendered object violates the
+// policy of the class
+type policyViolationError struct {
+	Template string
+	Kind     string
+	Name     string
+	Reason   string
+}
+
+func (e *policyViolationError) Error() string {
+	return fmt.Sprintf("%s %s from template %s violates policy: %s", e.Kind, e.Name, e.Template, e.Reason)
+}
+
+var (
+	policyEnvOnce sync.Once
+	policyEnv     *cel.Env
+	policyEnvErr  error
+
+	// Compiled CEL programs keyed by expression
+	policyPrograms sync.Map
+)
+
+// classPolicy returns the policy given in the 'policy' class parameter, or
+// nil if the class has no policy
+func classPolicy(configmap *corev1.ConfigMap) (*templatePolicy, error) {
+	data, found := configmap.Data["policy"]
+	if !found {
+		return nil, nil
+	}
+	policy := &templatePolicy{}
+	if err := yaml.UnmarshalStrict([]byte(data), policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	for _, k := range policy.AllowedKinds {
+		if k.Kind == "" {
+			return nil, fmt.Errorf("invalid policy: allowed kind without kind in group %q", k.Group)
+		}
+	}
+	for _, rule := range policy.Rules {
+		prg, err := compilePolicyRule(rule.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy rule %q: %w", rule.Expression, err)
+		}
+		policy.programs = append(policy.programs, prg)
+	}
+	return policy, nil
+}
+
+// compilePolicyRule compiles a CEL expression, caching the program
+func compilePolicyRule(expression string) (cel.Program, error) {
+	if prg, found := policyPrograms.Load(expression); found {
+		return prg.(cel.Program), nil
+	}
+	policyEnvOnce.Do(func() {
+		policyEnv, policyEnvErr = cel.NewEnv(
+			cel.Variable("object", cel.DynType),
+			cel.Variable("gateway", cel.DynType))
+	})
+	if policyEnvErr != nil {
+		return nil, policyEnvErr
+	}
+	ast, iss := policyEnv.Compile(expression)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must evaluate to bool, not %s", ast.OutputType())
+	}
+	prg, err := policyEnv.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	policyPrograms.Store(expression, prg)
+	return prg, nil
+}
+
+// check returns a policyViolationError if an object rendered from a template
+// for a Gateway violates the policy
+func (p *templatePolicy) check(obj *unstructured.Unstructured, gw *gateway.Gateway, configmapKey string) error {
+	violation := func(format string, args ...any) error {
+		return &policyViolationError{Template: configmapKey, Kind: obj.GetKind(), Name: obj.GetName(),
+			Reason: fmt.Sprintf(format, args...)}
+	}
+
+	gvk := obj.GroupVersionKind()
+	if len(p.AllowedKinds) > 0 {
+		allowed := false
+		for _, k := range p.AllowedKinds {
+			if k.Group == gvk.Group && k.Kind == gvk.Kind {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return violation("kind %s not allowed", gvk.GroupKind())
+		}
+	}
+
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = gw.Namespace
+	}
+	if len(p.AllowedNamespaces) > 0 {
+		allowed := false
+		for _, ns := range p.AllowedNamespaces {
+			if ns == namespace {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return violation("namespace %s not allowed", namespace)
+		}
+	}
+
+	if len(p.programs) == 0 {
+		return nil
+	}
+	gwData, err := runtime.DefaultUnstructuredConverter.ToUnstructured(gw)
+	if err != nil {
+		return err
+	}
+	vars := map[string]any{"object": obj.Object, "gateway": gwData}
+	for i, prg := range p.programs {
+		rule := p.Rules[i]
+		out, _, err := prg.Eval(vars)
+		if err != nil {
+			return violation("rule %q: %v", rule.Expression, err)
+		}
+		if allowed, ok := out.Value().(bool); !ok {
+			return violation("rule %q evaluated to %v, not bool", rule.Expression, out.Value())
+		} else if !allowed {
+			if rule.Message != "" {
+				return violation("%s", rule.Message)
+			}
+			return violation("rule %q not satisfied", rule.Expression)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const testPolicy = `
+allowedKinds:
+- group: networking.k8s.io
+  kind: Ingress
+- group: cert-manager.io
+  kind: Certificate
+allowedNamespaces: [default, infra]
+rules:
+- expression: object.metadata.name.startsWith(gateway.metadata.name)
+  message: names must be prefixed with the Gateway name
+- expression: object.kind != 'Ingress' || object.spec.ingressClassName == 'alb'
+`
+
+func TestClassPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		err    bool
+	}{
+		{testPolicy, false},
+		{"allowedKinds: [{group: apps}]", true},
+		{"rules: [{expression: 'object.metadata.name +'}]", true},
+		{"rules: [{expression: 'object.metadata.name'}]", false},
+		{"rules: [{expression: '1 + 1'}]", true},
+		{"unknownField: true", true},
+	}
+	for _, tc := range tests {
+		cm := &corev1.ConfigMap{Data: map[string]string{"policy": tc.policy}}
+		_, err := classPolicy(cm)
+		if (err != nil) != tc.err {
+			t.Errorf("Unexpected error for %q: %v", tc.policy, err)
+		}
+	}
+
+	policy, err := classPolicy(&corev1.ConfigMap{})
+	if err != nil || policy != nil {
+		t.Errorf("Expected no policy without parameter, got %v, %v", policy, err)
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := classPolicy(&corev1.ConfigMap{Data: map[string]string{"policy": testPolicy}})
+	if err != nil {
+		t.Fatalf("Cannot parse policy: %v", err)
+	}
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+
+	tests := []struct {
+		name   string
+		object map[string]any
+		reason string
+	}{
+		{"allowed", map[string]any{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress",
+			"metadata": map[string]any{"name": "foo-alb"},
+			"spec":     map[string]any{"ingressClassName": "alb"}}, ""},
+		{"allowed-namespace", map[string]any{"apiVersion": "cert-manager.io/v1", "kind": "Certificate",
+			"metadata": map[string]any{"name": "foo-cert", "namespace": "default"}}, ""},
+		{"kind", map[string]any{"apiVersion": "v1", "kind": "Secret",
+			"metadata": map[string]any{"name": "foo-secret"}}, "kind Secret not allowed"},
+		{"namespace", map[string]any{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress",
+			"metadata": map[string]any{"name": "foo-alb", "namespace": "kube-system"}}, "namespace kube-system not allowed"},
+		{"rule-message", map[string]any{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress",
+			"metadata": map[string]any{"name": "bar-alb"}}, "names must be prefixed with the Gateway name"},
+		{"rule-error", map[string]any{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress",
+			"metadata": map[string]any{"name": "foo-alb"}},
+			`rule "object.kind != 'Ingress' || object.spec.ingressClassName == 'alb'": no such key: spec`},
+		{"rule", map[string]any{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress",
+			"metadata": map[string]any{"name": "foo-alb"},
+			"spec":     map[string]any{"ingressClassName": "nginx"}},
+			`rule "object.kind != 'Ingress' || object.spec.ingressClassName == 'alb'" not satisfied`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.check(&unstructured.Unstructured{Object: tc.object}, gw, "albTemplate")
+			if tc.reason == "" {
+				if err != nil {
+					t.Errorf("Unexpected violation: %v", err)
+				}
+				return
+			}
+			var violation *policyViolationError
+			if !errors.As(err, &violation) {
+				t.Fatalf("Expected policy violation, got %v", err)
+			}
+			if violation.Reason != tc.reason || violation.Template != "albTemplate" {
+				t.Errorf("Expected reason %q from albTemplate, got %q from %s", tc.reason, violation.Reason, violation.Template)
+			}
+		})
+	}
+}
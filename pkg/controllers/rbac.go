@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+)
+
+// Condition type and reasons reporting whether the controller has the RBAC
+// permissions required by the templates of a GatewayClass
+const (
+	ConditionPermissions         = "Permissions"
+	ReasonPermissionsGranted     = "PermissionsGranted"
+	ReasonPermissionsMissing     = "PermissionsMissing"
+	ReasonPermissionsNotComputed = "PermissionsNotComputed"
+)
+
+// Verbs needed on objects created from templates, i.e. server-side apply
+// and pruning
+var templateVerbs = []string{"get", "list", "create", "patch", "delete"}
+
+// BaseRules returns the RBAC rules needed by the controller independent of
+// class templates
+func BaseRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{gateway.GroupName}, Resources: []string{"gatewayclasses"},
+			Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{gateway.GroupName}, Resources: []string{"gatewayclasses/status"},
+			Verbs: []string{"get", "update", "patch"}},
+		{APIGroups: []string{gateway.GroupName}, Resources: []string{"gateways", "httproutes"},
+			Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{APIGroups: []string{gateway.GroupName}, Resources: []string{"gateways/status", "httproutes/status"},
+			Verbs: []string{"get", "update", "patch"}},
+		// Owner references blocking owner deletion require updating finalizers
+		{APIGroups: []string{gateway.GroupName}, Resources: []string{"gateways/finalizers", "httproutes/finalizers"},
+			Verbs: []string{"update"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "endpoints", "namespaces"},
+			Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"events"},
+			Verbs: []string{"create", "patch"}},
+		{APIGroups: []string{"authorization.k8s.io"}, Resources: []string{"selfsubjectaccessreviews"},
+			Verbs: []string{"create"}},
+	}
+}
+
+// LeaderElectionRules returns the RBAC rules needed for leader election,
+// which are only needed in the namespace of the leader election lease
+func LeaderElectionRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"},
+			Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	}
+}
+
+// ImpersonationRules returns the cluster-wide RBAC rules needed by the
+// controller for classes applying templates as a ServiceAccount. Permission to
+// impersonate the ServiceAccount itself is granted in its namespace only, see
+// ServiceAccountRules.
+func ImpersonationRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{"authorization.k8s.io"}, Resources: []string{"subjectaccessreviews"},
+			Verbs: []string{"create"}},
+	}
+}
+
+// ServiceAccountRules returns the RBAC rules needed by the controller in the
+// namespace of class parameters to impersonate the ServiceAccount named by
+// them, and nil if none is named. The rules are to be granted with a Role and
+// RoleBinding in the namespace of the class parameters.
+func ServiceAccountRules(configmap *corev1.ConfigMap) ([]rbacv1.PolicyRule, error) {
+	user, err := templateServiceAccount(configmap)
+	if err != nil || user == "" {
+		return nil, err
+	}
+	_, name, err := serviceaccount.SplitUsername(user)
+	if err != nil {
+		return nil, err
+	}
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"serviceaccounts"}, ResourceNames: []string{name},
+			Verbs: []string{"impersonate"}},
+	}, nil
+}
+
+// MulticlusterRules returns the RBAC rules needed by the controller in the
+// namespace of GatewayClusters registering member clusters in hub mode
+func MulticlusterRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{v1alpha1.GroupVersion.Group}, Resources: []string{"gatewayclusters"},
+			Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{v1alpha1.GroupVersion.Group}, Resources: []string{"gatewayclusters/status"},
+			Verbs: []string{"update"}},
+	}
+}
+
+// TemplateGVKs returns the kinds of objects created from the templates of a
+// class. Templates are rendered with sample values for a Gateway with a
+// single listener, such that templates rendered conditionally on e.g.
+// attached routes may produce additional kinds not found.
+func TemplateGVKs(configmap *corev1.ConfigMap) ([]schema.GroupVersionKind, error) {
+	templates, err := classTemplates(configmap)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname := gateway.Hostname("www.example.com")
+	gw := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample", Namespace: "default"},
+		Spec: gateway.GatewaySpec{Listeners: []gateway.Listener{{
+			Name: "https", Hostname: &hostname, Port: 443, Protocol: gateway.HTTPSProtocolType}}},
+	}
+	hostnames := []string{string(hostname)}
+	values := &albTemplateValues{Gateway: gw, Hostnames: hostnames,
+		Certificates: splitCertificates(hostnames, defaultCertificateMaxDNSNames)}
+	listenerHostnames := map[gateway.SectionName][]string{"https": hostnames}
+
+	found := map[schema.GroupVersionKind]bool{}
+	var gvks []schema.GroupVersionKind
+	for _, t := range templates {
+		for _, v := range templateInstances(values, t.Mode, listenerHostnames) {
+			obj, err := renderTemplate(v, configmap, t.Key)
+			if err != nil {
+				return nil, fmt.Errorf("template %s: %w", t.Key, err)
+			}
+			if obj == nil || found[obj.GroupVersionKind()] {
+				continue
+			}
+			found[obj.GroupVersionKind()] = true
+			gvks = append(gvks, obj.GroupVersionKind())
+		}
+	}
+	return gvks, nil
+}
+
+// TemplateRules returns the RBAC rules needed to manage objects created from
+// the templates of a class
+func TemplateRules(mapper meta.RESTMapper, configmap *corev1.ConfigMap) ([]rbacv1.PolicyRule, error) {
+	gvks, err := TemplateGVKs(configmap)
+	if err != nil {
+		return nil, err
+	}
+	var rules []rbacv1.PolicyRule
+	for _, gvk := range gvks {
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{mapping.Resource.Group},
+			Resources: []string{mapping.Resource.Resource}, Verbs: templateVerbs})
+	}
+	return MergeRules(rules), nil
+}
+
+// MergeRules merges rules for the same API group and verbs and sorts them,
+// e.g. for stable output.
+func MergeRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	type key struct{ group, verbs string }
+	resources := map[key]map[string]bool{}
+	for _, rule := range rules {
+		verbs := append([]string{}, rule.Verbs...)
+		sort.Strings(verbs)
+		for _, group := range rule.APIGroups {
+			k := key{group, strings.Join(verbs, ",")}
+			if resources[k] == nil {
+				resources[k] = map[string]bool{}
+			}
+			for _, r := range rule.Resources {
+				resources[k][r] = true
+			}
+		}
+	}
+
+	keys := make([]key, 0, len(resources))
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].verbs < keys[j].verbs
+	})
+	merged := make([]rbacv1.PolicyRule, 0, len(keys))
+	for _, k := range keys {
+		merged = append(merged, rbacv1.PolicyRule{APIGroups: []string{k.group},
+			Resources: sortedKeys(resources[k]), Verbs: strings.Split(k.verbs, ",")})
+	}
+	return merged
+}
+
+// MissingRules checks the rules with access reviews and returns the rules not
+// granted, one resource and verb per rule. Rules are checked for the
+// controller itself if user is empty, otherwise for the given user. Rules are
+// checked cluster-wide or, if namespaces are given, in each namespace.
+func MissingRules(ctx context.Context, c client.Client, user string, rules []rbacv1.PolicyRule, namespaces []string) ([]rbacv1.PolicyRule, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	var missing []rbacv1.PolicyRule
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				resource, subresource, _ := strings.Cut(resource, "/")
+				for _, verb := range rule.Verbs {
+					for _, ns := range namespaces {
+						attrs := &authorizationv1.ResourceAttributes{Namespace: ns, Verb: verb, Group: group,
+							Resource: resource, Subresource: subresource}
+						allowed, err := accessAllowed(ctx, c, user, attrs)
+						if err != nil {
+							return nil, err
+						}
+						if !allowed {
+							name := resource
+							if subresource != "" {
+								name += "/" + subresource
+							}
+							missing = append(missing, rbacv1.PolicyRule{APIGroups: []string{group},
+								Resources: []string{name}, Verbs: []string{verb}})
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+	return MergeRules(missing), nil
+}
+
+// accessAllowed checks access with a SelfSubjectAccessReview, or a
+// SubjectAccessReview if user is given
+func accessAllowed(ctx context.Context, c client.Client, user string, attrs *authorizationv1.ResourceAttributes) (bool, error) {
+	if user == "" {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attrs}}
+		err := c.Create(ctx, review)
+		return review.Status.Allowed, err
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{User: user, Groups: userGroups(user), ResourceAttributes: attrs}}
+	err := c.Create(ctx, review)
+	return review.Status.Allowed, err
+}
+
+// userGroups returns the groups of a ServiceAccount user, as added by the API
+// server when authenticating or impersonating it, or nil for other users
+func userGroups(user string) []string {
+	namespace, _, err := serviceaccount.SplitUsername(user)
+	if err != nil {
+		return nil
+	}
+	return append(serviceaccount.MakeGroupNames(namespace), "system:authenticated")
+}
+
+// FormatRules formats rules compactly, e.g. for condition messages
+func FormatRules(rules []rbacv1.PolicyRule) string {
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				gr := resource
+				if group != "" {
+					gr = group + "/" + resource
+				}
+				parts = append(parts, fmt.Sprintf("%s[%s]", gr, strings.Join(rule.Verbs, ",")))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testConfigMap(t *testing.T) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{}
+	cmdata, err := os.ReadFile("../../test-data/gateway-class-configmap.yaml")
+	if err != nil {
+		t.Fatalf("Cannot read configmap: %v", err)
+	}
+	_ = yaml.Unmarshal(cmdata, cm)
+	return cm
+}
+
+func TestTemplateRules(t *testing.T) {
+	cm := testConfigMap(t)
+	ingress := schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}
+	certificate := schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+	gvks, err := TemplateGVKs(cm)
+	if err != nil {
+		t.Fatalf("Cannot compute template kinds: %v", err)
+	}
+	if !reflect.DeepEqual(gvks, []schema.GroupVersionKind{ingress, certificate}) {
+		t.Errorf("Unexpected template kinds %v", gvks)
+	}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(ingress, meta.RESTScopeNamespace)
+	mapper.Add(certificate, meta.RESTScopeNamespace)
+	rules, err := TemplateRules(mapper, cm)
+	if err != nil {
+		t.Fatalf("Cannot compute template rules: %v", err)
+	}
+	expected := []rbacv1.PolicyRule{
+		{APIGroups: []string{"cert-manager.io"}, Resources: []string{"certificates"},
+			Verbs: []string{"create", "delete", "get", "list", "patch"}},
+		{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"ingresses"},
+			Verbs: []string{"create", "delete", "get", "list", "patch"}},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Expected rules %v, got %v", expected, rules)
+	}
+
+	if _, err := TemplateRules(meta.NewDefaultRESTMapper(nil), cm); err == nil {
+		t.Errorf("Expected error for unknown kinds")
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	rules := MergeRules([]rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "services"}, Verbs: []string{"list", "get"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}},
+	})
+	expected := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "services"}, Verbs: []string{"get", "list"}},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Expected rules %v, got %v", expected, rules)
+	}
+	if s := FormatRules(expected); s != "events[create] configmaps[get,list] services[get,list]" {
+		t.Errorf("Unexpected formatted rules %q", s)
+	}
+}
+
+// accessReviewClient allows access reviews for the given resources
+type accessReviewClient struct {
+	client.Client
+	allowed map[string]bool
+}
+
+func (c *accessReviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authorizationv1.SelfSubjectAccessReview:
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = c.allowed[attrs.Namespace+"/"+attrs.Resource+"/"+attrs.Verb]
+	case *authorizationv1.SubjectAccessReview:
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = c.allowed[review.Spec.User+":"+attrs.Namespace+"/"+attrs.Resource+"/"+attrs.Verb]
+	}
+	return nil
+}
+
+func TestMissingRules(t *testing.T) {
+	c := &accessReviewClient{Client: fake.NewClientBuilder().Build(), allowed: map[string]bool{
+		"foo-infra/services/get": true,
+		"bar-infra/services/get": true,
+		"foo-infra/secrets/get":  true,
+	}}
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"services", "secrets"}, Verbs: []string{"get"}},
+	}
+	missing, err := MissingRules(context.Background(), c, "", rules, []string{"foo-infra", "bar-infra"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected missing rules %v, got %v", expected, missing)
+	}
+}
+
+func TestMissingRulesForUser(t *testing.T) {
+	user := "system:serviceaccount:net-team:templates"
+	c := &accessReviewClient{Client: fake.NewClientBuilder().Build(), allowed: map[string]bool{
+		"/services/get":        true,
+		user + ":/secrets/get": true,
+	}}
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"services", "secrets"}, Verbs: []string{"get"}},
+	}
+	missing, err := MissingRules(context.Background(), c, user, rules, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"get"}}}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected missing rules %v, got %v", expected, missing)
+	}
+}
+
+func TestServiceAccountRules(t *testing.T) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "net-team"}}
+	if rules, err := ServiceAccountRules(cm); err != nil || rules != nil {
+		t.Errorf("Expected no rules without serviceAccount, got %v, %v", rules, err)
+	}
+
+	cm.Data = map[string]string{"serviceAccount": "templates"}
+	rules, err := ServiceAccountRules(cm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"serviceaccounts"},
+		ResourceNames: []string{"templates"}, Verbs: []string{"impersonate"}}}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Expected rules %v, got %v", expected, rules)
+	}
+
+	cm.Data["serviceAccount"] = "kube-system/templates"
+	if _, err := ServiceAccountRules(cm); err == nil {
+		t.Errorf("Expected error for ServiceAccount in another namespace")
+	}
+}
+
+func TestUserGroups(t *testing.T) {
+	expected := []string{"system:serviceaccounts", "system:serviceaccounts:net-team", "system:authenticated"}
+	if groups := userGroups("system:serviceaccount:net-team:templates"); !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected groups %v, got %v", expected, groups)
+	}
+	if groups := userGroups("jane"); groups != nil {
+		t.Errorf("Unexpected groups for non-ServiceAccount user: %v", groups)
+	}
+}
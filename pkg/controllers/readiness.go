@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/health"
+)
+
+// Interval between reconciles while templates wait for their requirements,
+// since objects created from templates and endpoints are not watched
+const readinessRequeueInterval = 10 * time.Second
+
+// unmetRequirements returns a description of each requirement of a template
+// not met, given the shadow Gateways and the objects applied from preceding
+// templates keyed by template key. Objects are ready when healthy as
+// evaluated by the health registry of the class.
+func unmetRequirements(ctx context.Context, c client.Client, registry *health.Registry, t *classTemplate,
+	shadows []memberGateway, applied map[string][]*unstructured.Unstructured, values *albTemplateValues) ([]string, error) {
+	if t.Requires == nil {
+		return nil, nil
+	}
+	var unmet []string
+	if t.Requires.ShadowGatewayProgrammed {
+		for _, s := range shadows {
+			where := ""
+			if s.cluster != "" {
+				where = " in cluster " + s.cluster
+			}
+			if s.gw == nil {
+				unmet = append(unmet, "shadow Gateway"+where+" not applied")
+			} else if !meta.IsStatusConditionTrue(s.gw.Status.Conditions, string(gateway.GatewayConditionProgrammed)) {
+				unmet = append(unmet, fmt.Sprintf("shadow Gateway %s%s not programmed", s.gw.Name, where))
+			}
+		}
+	}
+	for _, key := range t.Requires.ReadyTemplates {
+		objs, found := applied[key]
+		if !found {
+			unmet = append(unmet, fmt.Sprintf("template %s not applied", key))
+			continue
+		}
+		for _, obj := range objs {
+			if res := registry.Evaluate(obj); res.Status != health.Healthy {
+				msg := fmt.Sprintf("%s %s not ready", obj.GetKind(), obj.GetName())
+				if res.Message != "" {
+					msg += ": " + res.Message
+				}
+				unmet = append(unmet, msg)
+			}
+		}
+	}
+	for _, nameTemplate := range t.Requires.ServiceEndpoints {
+		name, err := renderName(nameTemplate, values)
+		if err != nil {
+			return nil, fmt.Errorf("service name %q: %w", nameTemplate, err)
+		}
+		ready, err := serviceHasEndpoints(ctx, c, types.NamespacedName{Namespace: values.Gateway.Namespace, Name: name})
+		if err != nil {
+			return nil, err
+		}
+		if !ready {
+			unmet = append(unmet, fmt.Sprintf("Service %s has no ready endpoints", name))
+		}
+	}
+	return unmet, nil
+}
+
+// serviceHasEndpoints returns whether a Service has at least one ready
+// endpoint address
+func serviceHasEndpoints(ctx context.Context, c client.Client, name types.NamespacedName) (bool, error) {
+	var endpoints corev1.Endpoints
+	if err := c.Get(ctx, name, &endpoints); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// renderName renders a name given as template with the template values
+func renderName(nameTemplate string, values *albTemplateValues) (string, error) {
+	tmpl, err := template.New("name").Parse(nameTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/health"
+)
+
+func certificate(name, ready string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "cert-manager.io/v1", "kind": "Certificate",
+		"metadata": map[string]any{"name": name}}}
+	if ready != "" {
+		obj.Object["status"] = map[string]any{"conditions": []any{
+			map[string]any{"type": "Issuing", "status": "True"},
+			map[string]any{"type": "Ready", "status": ready}}}
+	}
+	return obj
+}
+
+func ingress(name, address string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "networking.k8s.io/v1", "kind": "Ingress",
+		"metadata": map[string]any{"name": name}}}
+	if address != "" {
+		obj.Object["status"] = map[string]any{"loadBalancer": map[string]any{
+			"ingress": []any{map[string]any{"ip": address}}}}
+	}
+	return obj
+}
+
+func TestUnmetRequirements(t *testing.T) {
+	gw := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+	values := &albTemplateValues{Gateway: gw}
+	ready := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "foo-ready", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}}
+	notReady := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "foo-not-ready", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}}}}}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ready, notReady).Build()
+
+	programmed := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio"},
+		Status: gateway.GatewayStatus{Conditions: []metav1.Condition{{
+			Type: string(gateway.GatewayConditionProgrammed), Status: metav1.ConditionTrue}}}}
+	unprogrammed := &gateway.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "foo-istio"}}
+
+	applied := map[string][]*unstructured.Unstructured{
+		"tlsCertificateTemplate": {certificate("foo-cert-0", "True"), certificate("foo-cert-1", "False")},
+		"dnsTemplate":            {certificate("foo-dns", "")},
+		"ingressTemplate":        {ingress("foo-alb-0", "10.0.0.10"), ingress("foo-alb-1", "")},
+	}
+
+	tests := []struct {
+		name     string
+		requires *templateRequirements
+		shadow   *gateway.Gateway
+		unmet    []string
+	}{
+		{"none", nil, unprogrammed, nil},
+		{"programmed", &templateRequirements{ShadowGatewayProgrammed: true}, programmed, nil},
+		{"not-programmed", &templateRequirements{ShadowGatewayProgrammed: true}, unprogrammed,
+			[]string{"shadow Gateway foo-istio not programmed"}},
+		{"ready-templates", &templateRequirements{ReadyTemplates: []string{"tlsCertificateTemplate", "dnsTemplate", "albTemplate"}},
+			programmed, []string{"Certificate foo-cert-1 not ready", "Certificate foo-dns not ready: not issued", "template albTemplate not applied"}},
+		{"ready-ingress", &templateRequirements{ReadyTemplates: []string{"ingressTemplate"}},
+			programmed, []string{"Ingress foo-alb-1 not ready: no load balancer address assigned"}},
+		{"endpoints", &templateRequirements{ServiceEndpoints: []string{"{{ .Gateway.Name }}-ready"}}, programmed, nil},
+		{"no-endpoints", &templateRequirements{ServiceEndpoints: []string{"{{ .Gateway.Name }}-not-ready", "missing"}},
+			programmed, []string{"Service foo-not-ready has no ready endpoints", "Service missing has no ready endpoints"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := &classTemplate{Key: "albTemplate", templateOptions: templateOptions{Requires: tc.requires}}
+			unmet, err := unmetRequirements(context.Background(), c, health.NewRegistry(), tmpl, []memberGateway{{gw: tc.shadow}}, applied, values)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(unmet, tc.unmet) {
+				t.Errorf("Expected unmet requirements %q, got %q", tc.unmet, unmet)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Directory with scenario fixtures, relative to the package directory
+var scenarioDir = filepath.Join("..", "..", "test-data", "scenarios")
+
+// scenario is a sequence of steps run against the test environment. Each step
+// applies and deletes objects and then waits for the cluster to converge to
+// the expected objects.
+type scenario struct {
+	Steps []scenarioStep `json:"steps"`
+}
+
+type scenarioStep struct {
+	Name string `json:"name"`
+
+	// Apply is objects created or updated with server-side apply
+	Apply []map[string]any `json:"apply,omitempty"`
+
+	// Delete is objects deleted, identified by apiVersion, kind, name and
+	// namespace
+	Delete []map[string]any `json:"delete,omitempty"`
+
+	// Expect is objects which must eventually exist and contain the given
+	// fields. Maps match if all given keys match, lists if they have the
+	// same length and all elements match.
+	Expect []map[string]any `json:"expect,omitempty"`
+
+	// ExpectAbsent is objects which must eventually not exist
+	ExpectAbsent []map[string]any `json:"expectAbsent,omitempty"`
+}
+
+// loadScenario reads a scenario fixture
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &scenario{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// scenarioObject returns an unstructured object with the identity of a
+// fixture object
+func scenarioObject(fixture map[string]any) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(fmt.Sprint(fixture["apiVersion"]))
+	obj.SetKind(fmt.Sprint(fixture["kind"]))
+	if metadata, ok := fixture["metadata"].(map[string]any); ok {
+		if name, ok := metadata["name"].(string); ok {
+			obj.SetName(name)
+		}
+		if namespace, ok := metadata["namespace"].(string); ok {
+			obj.SetNamespace(namespace)
+		}
+	}
+	return obj
+}
+
+// matchFields returns an error describing the first difference if the
+// expected value is not contained in the actual value. Values must be
+// decoded from JSON, i.e. numbers are float64.
+func matchFields(path string, expected, actual any) error {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected map, got %v", path, actual)
+		}
+		keys := make([]string, 0, len(e))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			av, found := a[k]
+			if !found {
+				return fmt.Errorf("%s.%s: missing", path, k)
+			}
+			if err := matchFields(path+"."+k, e[k], av); err != nil {
+				return err
+			}
+		}
+	case []any:
+		a, ok := actual.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected list, got %v", path, actual)
+		}
+		if len(e) != len(a) {
+			return fmt.Errorf("%s: expected %d elements, got %d: %v", path, len(e), len(a), a)
+		}
+		for i := range e {
+			if err := matchFields(fmt.Sprintf("%s[%d]", path, i), e[i], a[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		if expected != actual {
+			return fmt.Errorf("%s: expected %v, got %v", path, expected, actual)
+		}
+	}
+	return nil
+}
+
+// jsonObject returns the content of an object as decoded from JSON, such
+// that it compares with fixtures
+func jsonObject(obj *unstructured.Unstructured) (map[string]any, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	content := map[string]any{}
+	return content, json.Unmarshal(data, &content)
+}
+
+func runScenarioStep(ctx context.Context, step *scenarioStep) {
+	const (
+		timeout  = time.Second * 60
+		interval = time.Millisecond * 250
+	)
+
+	for _, fixture := range step.Apply {
+		obj := &unstructured.Unstructured{Object: fixture}
+		Expect(k8sClient.Patch(ctx, obj, client.Apply, client.FieldOwner("scenario"), client.ForceOwnership)).
+			Should(Succeed(), "apply %s %s", obj.GetKind(), obj.GetName())
+	}
+	for _, fixture := range step.Delete {
+		obj := scenarioObject(fixture)
+		Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, obj))).
+			Should(Succeed(), "delete %s %s", obj.GetKind(), obj.GetName())
+	}
+
+	Eventually(func(g Gomega) {
+		for _, fixture := range step.Expect {
+			obj := scenarioObject(fixture)
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)).
+				Should(Succeed(), "get %s %s", obj.GetKind(), obj.GetName())
+			content, err := jsonObject(obj)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(matchFields(obj.GetKind()+"/"+obj.GetName(), fixture, content)).Should(Succeed())
+		}
+		for _, fixture := range step.ExpectAbsent {
+			obj := scenarioObject(fixture)
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+			g.Expect(apierrors.IsNotFound(err)).Should(BeTrue(), "%s %s exists", obj.GetKind(), obj.GetName())
+		}
+	}, timeout, interval).Should(Succeed())
+}
+
+var _ = Describe("Scenarios", func() {
+	paths, err := filepath.Glob(filepath.Join(scenarioDir, "*.yaml"))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, path := range paths {
+		path := path
+		It("Should run scenario "+strings.TrimSuffix(filepath.Base(path), ".yaml"), func() {
+			ctx := context.Background()
+			s, err := loadScenario(path)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range s.Steps {
+				By(s.Steps[i].Name)
+				runScenarioStep(ctx, &s.Steps[i])
+			}
+		})
+	}
+})
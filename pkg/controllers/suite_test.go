@@ -17,17 +17,25 @@ import (
 
 	//"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	v1 "k8s.io/api/core/v1"
-	//metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	simulatorv1alpha1 "github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/config"
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/simulator"
 	//+kubebuilder:scaffold:imports
 )
 
 // These tests use Ginkgo (BDD-style Go testing framework). Refer to
 // http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
 
+// Name of the GatewayClass with templates targeting the simulator
+const simulatorGatewayClass = "cloud-gw-simulator"
+
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
@@ -47,8 +55,12 @@ var _ = BeforeSuite(func() {
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases"), filepath.Join("..", "..", "upstream-gateway-api-crds")},
-		ErrorIfCRDPathMissing: false,
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "test-data", "gateway-api", "crds"),
+			filepath.Join("..", "..", "charts", "cloud-gateway-controller", "crds"),
+			filepath.Join("..", "..", "test-data", "simulator", "crds"),
+		},
+		ErrorIfCRDPathMissing: true,
 	}
 
 	var err error
@@ -59,6 +71,8 @@ var _ = BeforeSuite(func() {
 
 	err = gateway.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = simulatorv1alpha1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 	//+kubebuilder:scaffold:scheme
 
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
@@ -83,19 +97,58 @@ var _ = BeforeSuite(func() {
 	_ = yaml.Unmarshal(gwcdata, gwc)
 	Expect(k8sClient.Create(ctx, gwc)).Should(Succeed())
 
+	// Setup GatewayClass with templates targeting the simulator, which
+	// provisions the objects rendered in scenarios
+	simcm := &v1.ConfigMap{}
+	simcmdata, err := os.ReadFile("../../test-data/simulator/gateway-class-configmap.yaml")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(yaml.Unmarshal(simcmdata, simcm)).Should(Succeed())
+	Expect(k8sClient.Create(ctx, simcm)).Should(Succeed())
+
+	simNamespace := gateway.Namespace(simcm.Namespace)
+	simgwc := &gateway.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: simulatorGatewayClass},
+		Spec: gateway.GatewayClassSpec{
+			ControllerName: gwc.Spec.ControllerName,
+			ParametersRef: &gateway.ParametersReference{
+				Group: "v1", Kind: "ConfigMap", Name: simcm.Name, Namespace: &simNamespace},
+		},
+	}
+	Expect(k8sClient.Create(ctx, simgwc)).Should(Succeed())
+
+	// Setup tier-2 GatewayClass, which is accepted by a (non-existing) tier-2 controller
+	tier2gwc := &gateway.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio"},
+		Spec:       gateway.GatewayClassSpec{ControllerName: "istio.io/gateway-controller"},
+	}
+	Expect(k8sClient.Create(ctx, tier2gwc)).Should(Succeed())
+	meta.SetStatusCondition(&tier2gwc.Status.Conditions, metav1.Condition{
+		Type:   string(gateway.GatewayClassConditionStatusAccepted),
+		Status: metav1.ConditionTrue,
+		Reason: string(gateway.GatewayClassReasonAccepted)})
+	Expect(k8sClient.Status().Update(ctx, tier2gwc)).Should(Succeed())
+
 	// Create controllers
-	gwcctrl := NewGatewayClassController(mgr)
+	controllerCfg := config.New()
+	controllerCfg.Complete()
+	err = SetupIndexes(ctx, mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	gwcctrl := NewGatewayClassController(mgr, controllerCfg)
 	err = gwcctrl.SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
-	gwctrl := NewGatewayController(mgr)
+	gwctrl := NewGatewayController(mgr, controllerCfg, nil)
 	err = gwctrl.SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
-	rtctrl := NewHTTPRouteController(mgr)
+	rtctrl := NewHTTPRouteController(mgr, controllerCfg, nil)
 	err = rtctrl.SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
+	err = simulator.SetupWithManager(mgr, simulator.Options{})
+	Expect(err).ToNot(HaveOccurred())
+
 	go func() {
 		defer GinkgoRecover()
 		err = mgr.Start(ctx)
@@ -104,8 +157,12 @@ var _ = BeforeSuite(func() {
 })
 
 var _ = AfterSuite(func() {
-	cancel()
+	if cancel != nil {
+		cancel()
+	}
 	By("tearing down the test environment")
-	err := testEnv.Stop()
-	Expect(err).NotTo(HaveOccurred())
+	if cfg != nil {
+		err := testEnv.Stop()
+		Expect(err).NotTo(HaveOccurred())
+	}
 })
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// templateMode defines how many times a template is rendered for a Gateway
+type templateMode string
+
+const (
+	// Template rendered once per Gateway
+	templateModeGateway templateMode = "Gateway"
+
+	// Template rendered once per Gateway listener. Names of rendered objects
+	// are suffixed with the listener name.
+	templateModeListener templateMode = "Listener"
+
+	// Template rendered once per certificate needed to cover the Gateway
+	// hostnames
+	templateModeCertificate templateMode = "Certificate"
+)
+
+// templateOptions is the options of a single template, given in the
+// 'templateOptions' class parameter keyed by template key
+type templateOptions struct {
+	Mode     templateMode          `json:"mode,omitempty"`
+	Requires *templateRequirements `json:"requires,omitempty"`
+}
+
+// templateRequirements are the prerequisites that must be met before a
+// template is applied
+type templateRequirements struct {
+	// The shadow Gateway must have condition Programmed true
+	ShadowGatewayProgrammed bool `json:"shadowGatewayProgrammed,omitempty"`
+
+	// Objects rendered from the given templates must have condition Ready
+	// true. Templates are applied after the templates they require.
+	ReadyTemplates []string `json:"readyTemplates,omitempty"`
+
+	// Services in the namespace of the Gateway which must have ready
+	// endpoints. Names are templates rendered with the template values.
+	ServiceEndpoints []string `json:"serviceEndpoints,omitempty"`
+}
+
+// classTemplate is a template to be rendered for Gateways of a class
+type classTemplate struct {
+	Key string
+	templateOptions
+}
+
+// Templates rendered if present in class parameters, together with their
+// default options. Additional templates are rendered if they have options
+// defined in the 'templateOptions' class parameter.
+var builtinTemplates = []classTemplate{
+	{Key: "albTemplate", templateOptions: templateOptions{Mode: templateModeGateway}},
+	{Key: "tlsCertificateTemplate", templateOptions: templateOptions{Mode: templateModeCertificate}},
+}
+
+// classTemplates returns the templates to render for Gateways of a class in
+// the order they should be rendered, i.e. builtin templates first and
+// additional templates sorted by key.
+func classTemplates(configmap *corev1.ConfigMap) ([]classTemplate, error) {
+	opts := map[string]templateOptions{}
+	if data, found := configmap.Data["templateOptions"]; found {
+		if err := yaml.UnmarshalStrict([]byte(data), &opts); err != nil {
+			return nil, fmt.Errorf("invalid templateOptions: %w", err)
+		}
+	}
+
+	var templates []classTemplate
+	for _, t := range builtinTemplates {
+		if o, found := opts[t.Key]; found {
+			if o.Mode != "" {
+				t.Mode = o.Mode
+			}
+			t.Requires = o.Requires
+			delete(opts, t.Key)
+		}
+		if _, found := configmap.Data[t.Key]; found {
+			templates = append(templates, t)
+		}
+	}
+
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, found := configmap.Data[k]; !found {
+			return nil, fmt.Errorf("templateOptions for unknown template %q", k)
+		}
+		t := classTemplate{Key: k, templateOptions: opts[k]}
+		if t.Mode == "" {
+			t.Mode = templateModeGateway
+		}
+		templates = append(templates, t)
+	}
+
+	for _, t := range templates {
+		switch t.Mode {
+		case templateModeGateway, templateModeListener, templateModeCertificate:
+		default:
+			return nil, fmt.Errorf("unknown mode %q for template %q", t.Mode, t.Key)
+		}
+	}
+	return orderTemplates(templates)
+}
+
+// orderTemplates orders templates such that templates are applied after the
+// templates they require to be ready, keeping the order otherwise
+func orderTemplates(templates []classTemplate) ([]classTemplate, error) {
+	index := map[string]int{}
+	for i, t := range templates {
+		index[t.Key] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(templates))
+	ordered := make([]classTemplate, 0, len(templates))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("template %q requires itself", templates[i].Key)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		if templates[i].Requires != nil {
+			for _, key := range templates[i].Requires.ReadyTemplates {
+				j, found := index[key]
+				if !found {
+					return fmt.Errorf("template %q requires unknown template %q", templates[i].Key, key)
+				}
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = visited
+		ordered = append(ordered, templates[i])
+		return nil
+	}
+	for i := range templates {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
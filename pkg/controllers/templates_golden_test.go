@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+var updateGolden = flag.Bool("update", false, "Update golden files of template rendering tests")
+
+// Directory with template rendering test cases, relative to the package
+// directory. Each case is a directory with files:
+//
+//   - class.yaml: ConfigMap with class parameters
+//   - gateway.yaml: Gateway
+//   - routes.yaml: HTTPRoutes and their Namespaces, optional
+//   - expected.yaml: objects rendered from the templates of the class,
+//     updated with 'go test ./pkg/controllers -run TestRenderTemplateGolden -update'
+var goldenDir = filepath.Join("..", "..", "test-data", "templates")
+
+// goldenCase is the inputs of a template rendering test case
+type goldenCase struct {
+	configmap  *corev1.ConfigMap
+	gateway    *gateway.Gateway
+	routes     []gateway.HTTPRoute
+	namespaces []corev1.Namespace
+}
+
+// readDocuments returns the YAML documents of a file
+func readDocuments(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(bytes.TrimSpace(doc)) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+// readObject reads a file with a single object
+func readObject(path string, obj any) error {
+	docs, err := readDocuments(path)
+	if err != nil {
+		return err
+	}
+	if len(docs) != 1 {
+		return fmt.Errorf("%s: expected a single object, got %d", path, len(docs))
+	}
+	if err := yaml.UnmarshalStrict(docs[0], obj); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func loadGoldenCase(dir string) (*goldenCase, error) {
+	c := &goldenCase{configmap: &corev1.ConfigMap{}, gateway: &gateway.Gateway{}}
+	if err := readObject(filepath.Join(dir, "class.yaml"), c.configmap); err != nil {
+		return nil, err
+	}
+	if err := readObject(filepath.Join(dir, "gateway.yaml"), c.gateway); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "routes.yaml")
+	docs, err := readDocuments(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, doc := range docs {
+		var typeMeta metav1.TypeMeta
+		if err := yaml.Unmarshal(doc, &typeMeta); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		switch typeMeta.Kind {
+		case "HTTPRoute":
+			var rt gateway.HTTPRoute
+			err = yaml.UnmarshalStrict(doc, &rt)
+			c.routes = append(c.routes, rt)
+		case "Namespace":
+			var ns corev1.Namespace
+			err = yaml.UnmarshalStrict(doc, &ns)
+			c.namespaces = append(c.namespaces, ns)
+		default:
+			err = fmt.Errorf("unexpected kind %q", typeMeta.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// render renders the templates of the class in the order they are applied,
+// regardless of template requirements, and returns the rendered objects as
+// YAML documents preceded by the template key.
+func (c *goldenCase) render() ([]byte, error) {
+	nsLabels := map[string]map[string]string{}
+	for i := range c.namespaces {
+		nsLabels[c.namespaces[i].Name] = c.namespaces[i].Labels
+	}
+	for i := range c.routes {
+		if c.routes[i].Namespace == "" {
+			c.routes[i].Namespace = "default"
+		}
+	}
+	hostnames, listenerHostnames := routeHostnames(c.gateway, c.routes, nsLabels)
+	maxNames, err := certificateMaxDNSNames(c.configmap)
+	if err != nil {
+		return nil, err
+	}
+	values := templateValues(c.gateway, hostnames, nil, maxNames)
+
+	templates, err := classTemplates(c.configmap)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, t := range templates {
+		for _, instance := range templateInstances(values, t.Mode, listenerHostnames) {
+			obj, err := renderTemplate(instance, c.configmap, t.Key)
+			if err != nil {
+				return nil, fmt.Errorf("template %s: %w", t.Key, err)
+			}
+			if obj == nil {
+				continue
+			}
+			data, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				return nil, err
+			}
+			if buf.Len() > 0 {
+				buf.WriteString("---\n")
+			}
+			fmt.Fprintf(&buf, "# %s\n", t.Key)
+			buf.Write(data)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func TestRenderTemplateGolden(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join(goldenDir, "*"))
+	if err != nil {
+		t.Fatalf("Cannot list test cases: %v", err)
+	}
+	if len(dirs) == 0 {
+		t.Fatalf("No test cases in %s", goldenDir)
+	}
+	for _, dir := range dirs {
+		dir := dir
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			c, err := loadGoldenCase(dir)
+			if err != nil {
+				t.Fatalf("Cannot load test case: %v", err)
+			}
+			rendered, err := c.render()
+			if err != nil {
+				t.Fatalf("Cannot render templates: %v", err)
+			}
+
+			path := filepath.Join(dir, "expected.yaml")
+			if *updateGolden {
+				if err := os.WriteFile(path, rendered, 0o644); err != nil {
+					t.Fatalf("Cannot update golden file: %v", err)
+				}
+				return
+			}
+			expected, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Cannot read golden file, run with -update to create it: %v", err)
+			}
+			if !bytes.Equal(rendered, expected) {
+				t.Errorf("Rendered objects differ from %s, run with -update to update it after review. Got:\n%s", path, rendered)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestClassTemplates(t *testing.T) {
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		"tier2GatewayClass":      "istio",
+		"tlsCertificateTemplate": "",
+		"albListenerTemplate":    "",
+		"dnsTemplate":            "",
+		"templateOptions": `
+albListenerTemplate:
+  mode: Listener
+dnsTemplate: {}`,
+	}}
+	templates, err := classTemplates(cm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []classTemplate{
+		{Key: "tlsCertificateTemplate", templateOptions: templateOptions{Mode: templateModeCertificate}},
+		{Key: "albListenerTemplate", templateOptions: templateOptions{Mode: templateModeListener}},
+		{Key: "dnsTemplate", templateOptions: templateOptions{Mode: templateModeGateway}},
+	}
+	if len(templates) != len(expected) {
+		t.Fatalf("Unexpected templates: %+v", templates)
+	}
+	for i := range expected {
+		if templates[i].Key != expected[i].Key || templates[i].Mode != expected[i].Mode {
+			t.Errorf("Unexpected template %d: %+v", i, templates[i])
+		}
+	}
+
+	// Templates are applied after the templates they require to be ready
+	cm.Data["albTemplate"] = ""
+	cm.Data["templateOptions"] = `
+albTemplate:
+  requires:
+    shadowGatewayProgrammed: true
+    readyTemplates: [tlsCertificateTemplate, dnsTemplate]
+dnsTemplate: {}`
+	templates, err = classTemplates(cm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var keys []string
+	for _, tmpl := range templates {
+		keys = append(keys, tmpl.Key)
+	}
+	if expected := []string{"tlsCertificateTemplate", "dnsTemplate", "albTemplate"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected templates in order %v, got %v", expected, keys)
+	}
+	delete(cm.Data, "albTemplate")
+
+	invalid := []string{
+		"missingTemplate: {}",
+		"tlsCertificateTemplate:\n  requires:\n    readyTemplates: [missingTemplate]",
+		"tlsCertificateTemplate:\n  requires:\n    readyTemplates: [dnsTemplate]\ndnsTemplate:\n  requires:\n    readyTemplates: [tlsCertificateTemplate]",
+		"tlsCertificateTemplate:\n  mode: Sometimes",
+		"tlsCertificateTemplate:\n  unknownField: true",
+	}
+	for _, opts := range invalid {
+		cm.Data["templateOptions"] = opts
+		if _, err := classTemplates(cm); err == nil {
+			t.Errorf("Expected error for templateOptions %q", opts)
+		}
+	}
+}
+
+func TestRenderListenerTemplate(t *testing.T) {
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		"albListenerTemplate": `
+{{- if eq .Listener.Protocol "HTTPS" }}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}
+data:
+  hostname: "{{ .Listener.Hostname }}"
+  port: "{{ .Listener.Port }}"
+  hostnames: "{{ range .Hostnames }}{{ . }} {{ end }}"
+{{- end }}`,
+	}}
+	hostname := gateway.Hostname("*.example.com")
+	gw := &gateway.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-gateway", Namespace: "foo-infra"},
+		Spec: gateway.GatewaySpec{
+			Listeners: []gateway.Listener{
+				{Name: "web", Port: 80, Protocol: gateway.HTTPProtocolType},
+				{Name: "websecure", Port: 443, Protocol: gateway.HTTPSProtocolType, Hostname: &hostname},
+			},
+		},
+	}
+	values := &albTemplateValues{Gateway: gw, Hostnames: []string{"foo.example.com", "foo.example.org"}}
+	listenerHostnames := map[gateway.SectionName][]string{"websecure": {"foo.example.com"}}
+	instances := templateInstances(values, templateModeListener, listenerHostnames)
+	if len(instances) != 2 {
+		t.Fatalf("Expected an instance per listener, got %d", len(instances))
+	}
+
+	obj, err := renderTemplate(instances[0], cm, "albListenerTemplate")
+	if err != nil || obj != nil {
+		t.Errorf("Expected nothing rendered for HTTP listener, got %+v, %v", obj, err)
+	}
+
+	obj, err = renderTemplate(instances[1], cm, "albListenerTemplate")
+	if err != nil || obj == nil {
+		t.Fatalf("Cannot render template: %v", err)
+	}
+	if obj.GetName() != "foo-gateway-websecure" {
+		t.Errorf("Unexpected name: %q", obj.GetName())
+	}
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+	if data["hostname"] != "*.example.com" || data["port"] != "443" || data["hostnames"] != "foo.example.com " {
+		t.Errorf("Unexpected data: %+v", data)
+	}
+}
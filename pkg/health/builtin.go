@@ -0,0 +1,88 @@
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ingressHealth is healthy when the Ingress is assigned a load balancer
+// address
+func ingressHealth(obj *unstructured.Unstructured) Result {
+	return loadBalancerHealth(obj)
+}
+
+// serviceHealth is healthy when a Service of type LoadBalancer is assigned a
+// load balancer address. Services of other types are always healthy.
+func serviceHealth(obj *unstructured.Unstructured) Result {
+	if svcType, _, _ := unstructured.NestedString(obj.Object, "spec", "type"); svcType != "LoadBalancer" {
+		return Result{Status: Healthy}
+	}
+	return loadBalancerHealth(obj)
+}
+
+func loadBalancerHealth(obj *unstructured.Unstructured) Result {
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if len(ingress) == 0 {
+		return Result{Status: Progressing, Message: "no load balancer address assigned"}
+	}
+	return Result{Status: Healthy}
+}
+
+// certificateHealth evaluates cert-manager Certificates, which are degraded
+// when issuing failed
+func certificateHealth(obj *unstructured.Unstructured) Result {
+	if ready := findCondition(obj, "Ready"); ready != nil && ready.status == "True" {
+		return Result{Status: Healthy}
+	}
+	if issuing := findCondition(obj, "Issuing"); issuing != nil {
+		if issuing.status == "True" {
+			return Result{Status: Progressing, Message: issuing.message}
+		}
+		if issuing.reason == "Failed" {
+			return Result{Status: Degraded, Message: issuing.message}
+		}
+	}
+	if ready := findCondition(obj, "Ready"); ready != nil {
+		return Result{Status: Degraded, Message: ready.message}
+	}
+	return Result{Status: Progressing, Message: "not issued"}
+}
+
+// readyCondition evaluates the common 'Ready' condition. Objects without a
+// Ready condition are considered healthy, e.g. objects without status.
+func readyCondition(obj *unstructured.Unstructured) Result {
+	ready := findCondition(obj, "Ready")
+	if ready == nil || ready.status == "True" {
+		return Result{Status: Healthy}
+	}
+	msg := ready.message
+	if msg == "" && ready.reason != "" {
+		msg = fmt.Sprintf("Ready is %s: %s", ready.status, ready.reason)
+	}
+	return Result{Status: Progressing, Message: msg}
+}
+
+type condition struct {
+	status  string
+	reason  string
+	message string
+}
+
+// findCondition returns the condition of the given type in the object status,
+// or nil if not found
+func findCondition(obj *unstructured.Unstructured, condType string) *condition {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cm, ok := c.(map[string]any)
+		if !ok || cm["type"] != condType {
+			continue
+		}
+		cond := &condition{}
+		cond.status, _, _ = unstructured.NestedString(cm, "status")
+		cond.reason, _, _ = unstructured.NestedString(cm, "reason")
+		cond.message, _, _ = unstructured.NestedString(cm, "message")
+		return cond
+	}
+	return nil
+}
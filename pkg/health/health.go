@@ -0,0 +1,115 @@
+// Package health evaluates the health of objects created from templates, with
+// evaluators selected by the kind of object.
+package health
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Status is the health status of an object
+type Status string
+
+const (
+	// The object is working as intended
+	Healthy Status = "Healthy"
+
+	// The object is not yet working as intended but is expected to be,
+	// e.g. a load balancer waiting for an address
+	Progressing Status = "Progressing"
+
+	// The object failed and is not expected to recover without changes,
+	// e.g. a certificate which could not be issued
+	Degraded Status = "Degraded"
+)
+
+// Result is the health of an object with a message explaining it
+type Result struct {
+	Status  Status
+	Message string
+}
+
+// Evaluator evaluates the health of objects of a kind
+type Evaluator interface {
+	Evaluate(obj *unstructured.Unstructured) Result
+}
+
+// EvaluatorFunc adapts a function to an Evaluator
+type EvaluatorFunc func(obj *unstructured.Unstructured) Result
+
+func (f EvaluatorFunc) Evaluate(obj *unstructured.Unstructured) Result {
+	return f(obj)
+}
+
+// Registry selects the evaluator of objects by their kind, falling back to
+// evaluating the Ready condition of objects of kinds without an evaluator
+type Registry struct {
+	evaluators map[schema.GroupKind]Evaluator
+	fallback   Evaluator
+}
+
+// NewRegistry returns a registry with the builtin evaluators
+func NewRegistry() *Registry {
+	r := &Registry{evaluators: map[schema.GroupKind]Evaluator{}, fallback: EvaluatorFunc(readyCondition)}
+	r.Register(schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}, EvaluatorFunc(ingressHealth))
+	r.Register(schema.GroupKind{Kind: "Service"}, EvaluatorFunc(serviceHealth))
+	r.Register(schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}, EvaluatorFunc(certificateHealth))
+	return r
+}
+
+// Register sets the evaluator of objects of a kind, replacing any evaluator
+// already registered for the kind
+func (r *Registry) Register(gk schema.GroupKind, e Evaluator) {
+	r.evaluators[gk] = e
+}
+
+// With returns a copy of the registry with evaluators for the given rules
+// registered, e.g. rules given in class parameters
+func (r *Registry) With(rules []Rule) (*Registry, error) {
+	c := &Registry{evaluators: make(map[schema.GroupKind]Evaluator, len(r.evaluators)+len(rules)), fallback: r.fallback}
+	for gk, e := range r.evaluators {
+		c.evaluators[gk] = e
+	}
+	for i := range rules {
+		e, err := rules[i].evaluator()
+		if err != nil {
+			return nil, fmt.Errorf("health rule for %s: %w", rules[i].GroupKind(), err)
+		}
+		c.Register(rules[i].GroupKind(), e)
+	}
+	return c, nil
+}
+
+// Evaluate evaluates the health of an object
+func (r *Registry) Evaluate(obj *unstructured.Unstructured) Result {
+	if e, found := r.evaluators[obj.GroupVersionKind().GroupKind()]; found {
+		return e.Evaluate(obj)
+	}
+	return r.fallback.Evaluate(obj)
+}
+
+// Aggregate evaluates the health of objects and returns the worst status,
+// with a message listing the objects not healthy
+func (r *Registry) Aggregate(objs []*unstructured.Unstructured) Result {
+	result := Result{Status: Healthy}
+	var messages []string
+	for _, obj := range objs {
+		res := r.Evaluate(obj)
+		if res.Status == Healthy {
+			continue
+		}
+		if result.Status != Degraded {
+			result.Status = res.Status
+		}
+		msg := fmt.Sprintf("%s %s %s", obj.GetKind(), obj.GetName(), strings.ToLower(string(res.Status)))
+		if res.Message != "" {
+			msg += ": " + res.Message
+		}
+		messages = append(messages, msg)
+	}
+	result.Message = strings.Join(messages, "; ")
+	return result
+}
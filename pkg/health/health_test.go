@@ -0,0 +1,156 @@
+package health
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func object(apiVersion, kind, name string, spec, status map[string]any) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{"apiVersion": apiVersion, "kind": kind,
+		"metadata": map[string]any{"name": name}}}
+	if spec != nil {
+		obj.Object["spec"] = spec
+	}
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	return obj
+}
+
+func conditions(conds ...map[string]any) map[string]any {
+	list := make([]any, 0, len(conds))
+	for _, c := range conds {
+		list = append(list, c)
+	}
+	return map[string]any{"conditions": list}
+}
+
+var lbAddress = map[string]any{"loadBalancer": map[string]any{"ingress": []any{map[string]any{"ip": "192.0.2.1"}}}}
+
+func TestBuiltinEvaluators(t *testing.T) {
+	r := NewRegistry()
+	tests := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		status Status
+	}{
+		{"ingress", object("networking.k8s.io/v1", "Ingress", "alb", nil, lbAddress), Healthy},
+		{"ingress-no-address", object("networking.k8s.io/v1", "Ingress", "alb", nil, nil), Progressing},
+		{"service-lb", object("v1", "Service", "lb", map[string]any{"type": "LoadBalancer"}, nil), Progressing},
+		{"service-lb-address", object("v1", "Service", "lb", map[string]any{"type": "LoadBalancer"}, lbAddress), Healthy},
+		{"service-cluster-ip", object("v1", "Service", "svc", map[string]any{"type": "ClusterIP"}, nil), Healthy},
+		{"certificate-ready", object("cert-manager.io/v1", "Certificate", "cert", nil,
+			conditions(map[string]any{"type": "Ready", "status": "True"})), Healthy},
+		{"certificate-issuing", object("cert-manager.io/v1", "Certificate", "cert", nil,
+			conditions(map[string]any{"type": "Ready", "status": "False"},
+				map[string]any{"type": "Issuing", "status": "True"})), Progressing},
+		{"certificate-failed", object("cert-manager.io/v1", "Certificate", "cert", nil,
+			conditions(map[string]any{"type": "Ready", "status": "False"},
+				map[string]any{"type": "Issuing", "status": "False", "reason": "Failed", "message": "order failed"})), Degraded},
+		{"certificate-new", object("cert-manager.io/v1", "Certificate", "cert", nil, nil), Progressing},
+		{"generic-no-status", object("v1", "ConfigMap", "cm", nil, nil), Healthy},
+		{"generic-ready", object("example.com/v1", "LoadBalancer", "lb", nil,
+			conditions(map[string]any{"type": "Ready", "status": "True"})), Healthy},
+		{"generic-not-ready", object("example.com/v1", "LoadBalancer", "lb", nil,
+			conditions(map[string]any{"type": "Ready", "status": "False", "reason": "Provisioning"})), Progressing},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := r.Evaluate(tc.obj); res.Status != tc.status {
+				t.Errorf("Expected %s, got %s (%s)", tc.status, res.Status, res.Message)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules(`
+- group: example.com
+  kind: LoadBalancer
+  healthy: object.status.state == 'active'
+  degraded: object.status.state == 'failed'
+- group: example.com
+  kind: DNSRecord
+  jsonPath: '{.status.phase}'
+  healthyValues: [Synced]
+  degradedValues: [Error]
+`)
+	if err != nil {
+		t.Fatalf("Cannot parse rules: %v", err)
+	}
+	r, err := NewRegistry().With(rules)
+	if err != nil {
+		t.Fatalf("Cannot register rules: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		status Status
+	}{
+		{"cel-healthy", object("example.com/v1", "LoadBalancer", "lb", nil, map[string]any{"state": "active"}), Healthy},
+		{"cel-degraded", object("example.com/v1", "LoadBalancer", "lb", nil, map[string]any{"state": "failed"}), Degraded},
+		{"cel-progressing", object("example.com/v1", "LoadBalancer", "lb", nil, map[string]any{"state": "provisioning"}), Progressing},
+		{"cel-no-status", object("example.com/v1", "LoadBalancer", "lb", nil, nil), Progressing},
+		{"jsonpath-healthy", object("example.com/v1", "DNSRecord", "dns", nil, map[string]any{"phase": "Synced"}), Healthy},
+		{"jsonpath-degraded", object("example.com/v1", "DNSRecord", "dns", nil, map[string]any{"phase": "Error"}), Degraded},
+		{"jsonpath-no-status", object("example.com/v1", "DNSRecord", "dns", nil, nil), Progressing},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := r.Evaluate(tc.obj); res.Status != tc.status {
+				t.Errorf("Expected %s, got %s (%s)", tc.status, res.Status, res.Message)
+			}
+		})
+	}
+
+	// Rules do not change the registry they were added to
+	if res := NewRegistry().Evaluate(tests[1].obj); res.Status != Healthy {
+		t.Errorf("Expected fallback evaluator without rules, got %s", res.Status)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	invalid := []string{
+		"- kind: LoadBalancer",
+		"- healthy: 'true'",
+		"- kind: LoadBalancer\n  healthy: 'object.status +'",
+		"- kind: LoadBalancer\n  healthy: '1'",
+		"- kind: LoadBalancer\n  healthy: 'true'\n  jsonPath: '{.status}'",
+		"- kind: LoadBalancer\n  jsonPath: '{.status.phase}'",
+		"- kind: LoadBalancer\n  unknownField: true",
+	}
+	for _, data := range invalid {
+		rules, err := ParseRules(data)
+		if err == nil {
+			_, err = NewRegistry().With(rules)
+		}
+		if err == nil {
+			t.Errorf("Expected error for rules %q", data)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	r := NewRegistry()
+	r.Register(schema.GroupKind{Group: "example.com", Kind: "Broken"}, EvaluatorFunc(func(*unstructured.Unstructured) Result {
+		return Result{Status: Degraded, Message: "broken"}
+	}))
+
+	res := r.Aggregate(nil)
+	if res.Status != Healthy || res.Message != "" {
+		t.Errorf("Expected healthy without objects, got %+v", res)
+	}
+
+	res = r.Aggregate([]*unstructured.Unstructured{
+		object("networking.k8s.io/v1", "Ingress", "alb", nil, nil),
+		object("example.com/v1", "Broken", "b", nil, nil),
+		object("v1", "ConfigMap", "cm", nil, nil),
+	})
+	expected := "Ingress alb progressing: no load balancer address assigned; Broken b degraded: broken"
+	if res.Status != Degraded || res.Message != expected {
+		t.Errorf("Expected degraded with message %q, got %+v", expected, res)
+	}
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Rule defines the health of objects of a kind, e.g. kinds without a builtin
+// evaluator. Health is given either by CEL expressions or by a JSONPath
+// expression and the values it may evaluate to.
+type Rule struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+
+	// CEL expressions evaluated with the object as 'object'. Objects are
+	// healthy if Healthy is true, degraded if Degraded is true and
+	// progressing otherwise.
+	Healthy  string `json:"healthy,omitempty"`
+	Degraded string `json:"degraded,omitempty"`
+
+	// JSONPath expression, e.g. '{.status.phase}'. Objects are healthy if it
+	// evaluates to one of HealthyValues, degraded if it evaluates to one of
+	// DegradedValues and progressing otherwise.
+	JSONPath       string   `json:"jsonPath,omitempty"`
+	HealthyValues  []string `json:"healthyValues,omitempty"`
+	DegradedValues []string `json:"degradedValues,omitempty"`
+}
+
+// GroupKind returns the kind of objects the rule applies to
+func (r *Rule) GroupKind() schema.GroupKind {
+	return schema.GroupKind{Group: r.Group, Kind: r.Kind}
+}
+
+// ParseRules parses a YAML list of rules
+func ParseRules(data string) ([]Rule, error) {
+	var rules []Rule
+	if err := yaml.UnmarshalStrict([]byte(data), &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if rules[i].Kind == "" {
+			return nil, fmt.Errorf("health rule %d: kind is required", i)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rule) evaluator() (Evaluator, error) {
+	switch {
+	case r.Healthy != "" && r.JSONPath != "":
+		return nil, errors.New("healthy and jsonPath are mutually exclusive")
+	case r.Healthy != "":
+		return r.celEvaluator()
+	case r.JSONPath != "":
+		return r.jsonPathEvaluator()
+	}
+	return nil, errors.New("one of healthy and jsonPath is required")
+}
+
+var (
+	celEnvOnce sync.Once
+	celEnv     *cel.Env
+	celEnvErr  error
+)
+
+func compile(expression string) (cel.Program, error) {
+	celEnvOnce.Do(func() {
+		celEnv, celEnvErr = cel.NewEnv(cel.Variable("object", cel.DynType))
+	})
+	if celEnvErr != nil {
+		return nil, celEnvErr
+	}
+	ast, iss := celEnv.Compile(expression)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("expression %q: %w", expression, iss.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression %q must evaluate to bool, not %s", expression, ast.OutputType())
+	}
+	return celEnv.Program(ast)
+}
+
+func (r *Rule) celEvaluator() (Evaluator, error) {
+	healthy, err := compile(r.Healthy)
+	if err != nil {
+		return nil, err
+	}
+	var degraded cel.Program
+	if r.Degraded != "" {
+		if degraded, err = compile(r.Degraded); err != nil {
+			return nil, err
+		}
+	}
+	eval := func(prg cel.Program, expression string, obj *unstructured.Unstructured) (bool, error) {
+		out, _, err := prg.Eval(map[string]any{"object": obj.Object})
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", expression, err)
+		}
+		value, ok := out.Value().(bool)
+		if !ok {
+			return false, fmt.Errorf("%s evaluated to %v, not bool", expression, out.Value())
+		}
+		return value, nil
+	}
+	return EvaluatorFunc(func(obj *unstructured.Unstructured) Result {
+		if degraded != nil {
+			if value, err := eval(degraded, r.Degraded, obj); err == nil && value {
+				return Result{Status: Degraded, Message: r.Degraded}
+			}
+		}
+		value, err := eval(healthy, r.Healthy, obj)
+		if err != nil {
+			return Result{Status: Progressing, Message: err.Error()}
+		}
+		if !value {
+			return Result{Status: Progressing, Message: "not " + r.Healthy}
+		}
+		return Result{Status: Healthy}
+	}), nil
+}
+
+func (r *Rule) jsonPathEvaluator() (Evaluator, error) {
+	jp := jsonpath.New("health").AllowMissingKeys(true)
+	if err := jp.Parse(r.JSONPath); err != nil {
+		return nil, fmt.Errorf("jsonPath %q: %w", r.JSONPath, err)
+	}
+	if len(r.HealthyValues) == 0 {
+		return nil, errors.New("healthyValues is required with jsonPath")
+	}
+	var mu sync.Mutex
+	return EvaluatorFunc(func(obj *unstructured.Unstructured) Result {
+		var buf bytes.Buffer
+		// JSONPath is not safe for concurrent use
+		mu.Lock()
+		err := jp.Execute(&buf, obj.Object)
+		mu.Unlock()
+		if err != nil {
+			return Result{Status: Progressing, Message: fmt.Sprintf("%s: %v", r.JSONPath, err)}
+		}
+		value := strings.TrimSpace(buf.String())
+		for _, v := range r.DegradedValues {
+			if v == value {
+				return Result{Status: Degraded, Message: fmt.Sprintf("%s is %s", r.JSONPath, value)}
+			}
+		}
+		for _, v := range r.HealthyValues {
+			if v == value {
+				return Result{Status: Healthy}
+			}
+		}
+		return Result{Status: Progressing, Message: fmt.Sprintf("%s is %q", r.JSONPath, value)}
+	}), nil
+}
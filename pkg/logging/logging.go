@@ -0,0 +1,145 @@
+// Package logging provides structured, leveled logging with stable keys and
+// redaction of sensitive data from logged objects.
+package logging
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// Log keys used consistently across controllers
+const (
+	GatewayKey      = "gateway"
+	GatewayClassKey = "class"
+	HTTPRouteKey    = "httproute"
+	TemplateKey     = "template"
+	GVKKey          = "gvk"
+	NameKey         = "name"
+	ClusterKey      = "cluster"
+	ObjectKey       = "object"
+)
+
+// Verbosity levels. Level 0 logs changes made by the controller.
+const (
+	// DebugLevel logs reconcile decisions
+	DebugLevel = 1
+
+	// ObjectLevel logs redacted object dumps
+	ObjectLevel = 2
+)
+
+// Redacted replaces sensitive values in logged objects
+const Redacted = "<redacted>"
+
+// sensitiveFields are the field paths redacted from logged objects in
+// addition to Secret data
+var sensitiveFields [][]string
+
+// Options configures logging
+type Options struct {
+	// Zap are the controller-runtime zap options
+	Zap zap.Options
+
+	// Production selects JSON encoded logs at info level instead of human
+	// readable development logs
+	Production bool
+
+	// SensitiveFields are dot-separated field paths redacted from logged
+	// objects, e.g. 'spec.privateKey'
+	SensitiveFields []string
+}
+
+// NewOptions returns options defaulting to development logs
+func NewOptions() *Options {
+	return &Options{Zap: zap.Options{Development: true}}
+}
+
+// BindFlags binds the options to command line flags
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	o.Zap.BindFlags(fs)
+	fs.BoolVar(&o.Production, "log-production", o.Production,
+		"Use JSON encoded logs at info level suitable for production instead of development logs.")
+	fs.Func("log-redact-fields",
+		"Comma-separated list of dot-separated field paths redacted from logged objects, e.g. 'spec.privateKey'.",
+		func(value string) error {
+			for _, f := range strings.Split(value, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					o.SensitiveFields = append(o.SensitiveFields, f)
+				}
+			}
+			return nil
+		})
+}
+
+// Logger configures redaction and returns a logger from the options
+func (o *Options) Logger() logr.Logger {
+	SetSensitiveFields(o.SensitiveFields)
+	opts := o.Zap
+	if o.Production {
+		opts.Development = false
+	}
+	return zap.New(zap.UseFlagOptions(&opts))
+}
+
+// SetSensitiveFields sets the dot-separated field paths redacted from logged
+// objects
+func SetSensitiveFields(fields []string) {
+	sensitiveFields = nil
+	for _, f := range fields {
+		sensitiveFields = append(sensitiveFields, strings.Split(f, "."))
+	}
+}
+
+// Object returns a copy of an object suitable for logging. Secret data and
+// sensitive fields are redacted and managed fields are removed. Objects that
+// cannot be converted are returned unmodified.
+func Object(obj any) any {
+	var content map[string]any
+	switch o := obj.(type) {
+	case *unstructured.Unstructured:
+		if o == nil {
+			return obj
+		}
+		content = runtime.DeepCopyJSON(o.UnstructuredContent())
+	case runtime.Object:
+		var err error
+		content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return obj
+		}
+		if _, isSecret := o.(*corev1.Secret); isSecret {
+			content["kind"] = "Secret"
+		}
+	default:
+		return obj
+	}
+
+	unstructured.RemoveNestedField(content, "metadata", "managedFields")
+	if content["kind"] == "Secret" {
+		redactValues(content, "data")
+		redactValues(content, "stringData")
+	}
+	for _, path := range sensitiveFields {
+		if _, found, _ := unstructured.NestedFieldNoCopy(content, path...); found {
+			_ = unstructured.SetNestedField(content, Redacted, path...)
+		}
+	}
+	return content
+}
+
+// redactValues redacts the values of a map field keeping the keys
+func redactValues(content map[string]any, field string) {
+	values, ok := content[field].(map[string]any)
+	if !ok {
+		return
+	}
+	for k := range values {
+		values[k] = Redacted
+	}
+}
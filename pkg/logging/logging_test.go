@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectRedactsSecrets(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-tls", ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "test"}}},
+		Data:       map[string][]byte{"tls.key": []byte("private")},
+		StringData: map[string]string{"password": "secret"},
+	}
+	obj, ok := Object(secret).(map[string]any)
+	if !ok {
+		t.Fatalf("Expected object converted to map, got %T", Object(secret))
+	}
+	if !reflect.DeepEqual(obj["data"], map[string]any{"tls.key": Redacted}) {
+		t.Errorf("Secret data not redacted: %v", obj["data"])
+	}
+	if !reflect.DeepEqual(obj["stringData"], map[string]any{"password": Redacted}) {
+		t.Errorf("Secret stringData not redacted: %v", obj["stringData"])
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj, "metadata", "managedFields"); found {
+		t.Errorf("Managed fields not removed")
+	}
+	if string(secret.Data["tls.key"]) != "private" {
+		t.Errorf("Original object modified")
+	}
+}
+
+func TestObjectRedactsSensitiveFields(t *testing.T) {
+	SetSensitiveFields([]string{"spec.privateKey", "spec.missing"})
+	defer SetSensitiveFields(nil)
+
+	us := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "example.com/v1",
+		"kind":       "Certificate",
+		"spec":       map[string]any{"privateKey": "private", "domainName": "example.com"},
+	}}
+	obj := Object(us).(map[string]any)
+	expected := map[string]any{"privateKey": Redacted, "domainName": "example.com"}
+	if !reflect.DeepEqual(obj["spec"], expected) {
+		t.Errorf("Expected %v, got %v", expected, obj["spec"])
+	}
+	if us.Object["spec"].(map[string]any)["privateKey"] != "private" {
+		t.Errorf("Original object modified")
+	}
+}
+
+func TestObjectUnknown(t *testing.T) {
+	if Object("foo") != "foo" {
+		t.Errorf("Expected non-objects returned unmodified")
+	}
+}
@@ -0,0 +1,256 @@
+// Package multicluster provides clients of the member clusters of a hub
+// controller, with member clusters registered by GatewayClusters referencing
+// Secrets holding kubeconfigs.
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+)
+
+// Cluster is a member cluster
+type Cluster struct {
+	// Name of the cluster, i.e. the name of the GatewayCluster registering it
+	Name string
+
+	// Labels of the GatewayCluster registering the cluster, e.g. region and
+	// zone labels
+	Labels map[string]string
+
+	// Client of the cluster
+	Client client.Client
+
+	// Weight of traffic to the cluster relative to other clusters
+	Weight int
+
+	// Draining is true if traffic is drained from the cluster
+	Draining bool
+
+	// Ready is false if the last probe of the cluster failed
+	Ready bool
+}
+
+// EffectiveWeight returns the weight of traffic to the cluster, i.e. zero
+// for draining clusters and clusters that are not ready
+func (c *Cluster) EffectiveWeight() int {
+	if c.Draining || !c.Ready {
+		return 0
+	}
+	return c.Weight
+}
+
+// NewClientFunc creates a client of a member cluster
+type NewClientFunc func(config *rest.Config) (client.Client, error)
+
+// ClientWithScheme returns a NewClientFunc creating clients with the given
+// scheme
+func ClientWithScheme(scheme *runtime.Scheme) NewClientFunc {
+	return func(config *rest.Config) (client.Client, error) {
+		return client.New(config, client.Options{Scheme: scheme})
+	}
+}
+
+// Registry returns the member clusters registered by GatewayClusters in a
+// namespace. Clients are created when clusters are first seen and recreated
+// when their kubeconfig Secrets change.
+type Registry struct {
+	reader    client.Reader
+	cache     cache.Cache
+	namespace string
+	newClient NewClientFunc
+
+	mu      sync.Mutex
+	clients map[string]cachedClient
+}
+
+type cachedClient struct {
+	secret          string
+	resourceVersion string
+	client          client.Client
+}
+
+// NewRegistry returns a registry of the member clusters registered in a
+// namespace. GatewayClusters and Secrets are read with the given reader.
+func NewRegistry(reader client.Reader, namespace string, newClient NewClientFunc) *Registry {
+	return &Registry{reader: reader, namespace: namespace, newClient: newClient, clients: map[string]cachedClient{}}
+}
+
+// NewCachedRegistry returns a registry of the member clusters registered in a
+// namespace, reading GatewayClusters and Secrets from a cache of only that
+// namespace, such that Secrets are not cached cluster-wide. The cache is
+// added to the manager and synced before controllers start.
+func NewCachedRegistry(mgr manager.Manager, namespace string) (*Registry, error) {
+	hub, err := cluster.New(mgr.GetConfig(), func(o *cluster.Options) {
+		o.Scheme = mgr.GetScheme()
+		o.Namespace = namespace
+		o.MapperProvider = func(*rest.Config) (meta.RESTMapper, error) { return mgr.GetRESTMapper(), nil }
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(hub); err != nil {
+		return nil, err
+	}
+	r := NewRegistry(hub.GetCache(), namespace, ClientWithScheme(mgr.GetScheme()))
+	r.cache = hub.GetCache()
+	return r, nil
+}
+
+// Source returns a source of GatewayCluster events, such that controllers can
+// react to member clusters being registered, changed or removed. It is nil
+// for registries not created with NewCachedRegistry.
+func (r *Registry) Source() source.Source {
+	if r.cache == nil {
+		return nil
+	}
+	return source.NewKindWithCache(&v1alpha1.GatewayCluster{}, r.cache)
+}
+
+// Namespace returns the namespace of GatewayClusters registering member
+// clusters
+func (r *Registry) Namespace() string {
+	return r.namespace
+}
+
+// Clusters returns the member clusters sorted by name. Clusters with missing
+// or invalid kubeconfigs are logged and skipped, such that one invalid
+// cluster does not affect other clusters.
+func (r *Registry) Clusters(ctx context.Context) ([]*Cluster, error) {
+	log := log.FromContext(ctx)
+
+	var gcList v1alpha1.GatewayClusterList
+	if err := r.reader.List(ctx, &gcList, client.InNamespace(r.namespace)); err != nil {
+		return nil, err
+	}
+	sort.Slice(gcList.Items, func(i, j int) bool { return gcList.Items[i].Name < gcList.Items[j].Name })
+
+	seen := map[string]bool{}
+	clusters := make([]*Cluster, 0, len(gcList.Items))
+	for i := range gcList.Items {
+		gc := &gcList.Items[i]
+		seen[gc.Name] = true
+		c, err := r.Client(ctx, gc)
+		if err != nil {
+			log.Error(err, "invalid member cluster kubeconfig", "cluster", gc.Name)
+			continue
+		}
+		weight, draining, err := traffic(gc)
+		if err != nil {
+			log.Error(err, "ignoring invalid member cluster annotation", "cluster", gc.Name)
+		}
+		clusters = append(clusters, &Cluster{
+			Name:     gc.Name,
+			Labels:   gc.Labels,
+			Client:   c,
+			Weight:   weight,
+			Draining: draining,
+			Ready:    !meta.IsStatusConditionFalse(gc.Status.Conditions, v1alpha1.GatewayClusterConditionReady),
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range r.clients {
+		if !seen[name] {
+			delete(r.clients, name)
+		}
+	}
+	return clusters, nil
+}
+
+// traffic returns the weight of a cluster ignoring draining and whether it is
+// draining. The weight and drain annotations take precedence over the spec,
+// such that clusters can be weighted and drained by annotating them. Invalid
+// annotations are ignored and the last one is returned as an error.
+func traffic(gc *v1alpha1.GatewayCluster) (int, bool, error) {
+	weight := v1alpha1.DefaultWeight
+	if gc.Spec.Weight != nil {
+		weight = int(*gc.Spec.Weight)
+	}
+	draining := gc.Spec.Drain
+
+	var err error
+	if value, found := gc.Annotations[v1alpha1.WeightAnnotation]; found {
+		if w, parseErr := strconv.Atoi(value); parseErr != nil || w < 0 {
+			err = fmt.Errorf("invalid annotation %s=%q, must be a non-negative integer", v1alpha1.WeightAnnotation, value)
+		} else {
+			weight = w
+		}
+	}
+	if value, found := gc.Annotations[v1alpha1.DrainAnnotation]; found {
+		if d, parseErr := strconv.ParseBool(value); parseErr != nil {
+			err = fmt.Errorf("invalid annotation %s=%q: %w", v1alpha1.DrainAnnotation, value, parseErr)
+		} else {
+			draining = d
+		}
+	}
+	return weight, draining, err
+}
+
+// Client returns the cached client of a cluster, creating it if the cluster
+// is new or its kubeconfig Secret changed. Clients are created without holding
+// the lock, since creating a client discovers the API of the cluster, such
+// that an unreachable cluster does not block clients of other clusters.
+func (r *Registry) Client(ctx context.Context, gc *v1alpha1.GatewayCluster) (client.Client, error) {
+	secret := &corev1.Secret{}
+	if err := r.reader.Get(ctx, client.ObjectKey{Namespace: gc.Namespace,
+		Name: gc.Spec.KubeconfigSecretRef.Name}, secret); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	cached := r.lookup(gc.Name, secret)
+	r.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+	kubeconfig, found := secret.Data[gc.KubeconfigKey()]
+	if !found {
+		return nil, fmt.Errorf("missing key %q in Secret %s", gc.KubeconfigKey(), secret.Name)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Another reconcile may have created a client of the same Secret
+	// meanwhile, which is kept such that a cluster has a single client
+	if cached := r.lookup(gc.Name, secret); cached != nil {
+		return cached, nil
+	}
+	r.clients[gc.Name] = cachedClient{secret: secret.Name, resourceVersion: secret.ResourceVersion, client: c}
+	return c, nil
+}
+
+// lookup returns the cached client of a cluster if created from the current
+// version of its kubeconfig Secret, otherwise nil. The lock must be held.
+func (r *Registry) lookup(name string, secret *corev1.Secret) client.Client {
+	if cached, found := r.clients[name]; found && cached.secret == secret.Name &&
+		cached.resourceVersion == secret.ResourceVersion {
+		return cached.client
+	}
+	return nil
+}
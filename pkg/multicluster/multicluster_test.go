@@ -0,0 +1,216 @@
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+)
+
+func kubeconfig(server string) []byte {
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: member
+  cluster:
+    server: %s
+contexts:
+- name: member
+  context:
+    cluster: member
+current-context: member
+`, server))
+}
+
+func hubScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	return scheme
+}
+
+// registration returns a GatewayCluster and its kubeconfig Secret
+func registration(name, server string) (*v1alpha1.GatewayCluster, *corev1.Secret) {
+	gc := &v1alpha1.GatewayCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "clusters"},
+		Spec: v1alpha1.GatewayClusterSpec{
+			KubeconfigSecretRef: v1alpha1.SecretKeyReference{Name: name + "-kubeconfig"}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-kubeconfig", Namespace: "clusters"},
+		Data:       map[string][]byte{v1alpha1.DefaultKubeconfigKey: kubeconfig(server)},
+	}
+	return gc, secret
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	blue, blueSecret := registration("blue", "https://blue.example.com")
+	green, greenSecret := registration("green", "https://green.example.com")
+	invalid, _ := registration("invalid", "https://invalid.example.com")
+	hub := fake.NewClientBuilder().WithScheme(hubScheme()).
+		WithObjects(blue, blueSecret, green, greenSecret, invalid).Build()
+
+	var created []string
+	r := NewRegistry(hub, "clusters", func(config *rest.Config) (client.Client, error) {
+		created = append(created, config.Host)
+		return fake.NewClientBuilder().Build(), nil
+	})
+
+	clusters, err := r.Clusters(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(clusters) != 2 || clusters[0].Name != "blue" || clusters[1].Name != "green" {
+		t.Fatalf("Expected clusters blue and green, got %+v", clusters)
+	}
+
+	// Clients are cached until the Secret changes
+	if _, err := r.Clusters(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(created) != 2 {
+		t.Errorf("Expected clients to be cached, created %v", created)
+	}
+	secret := &corev1.Secret{}
+	_ = hub.Get(ctx, client.ObjectKey{Namespace: "clusters", Name: "green-kubeconfig"}, secret)
+	secret.Data[v1alpha1.DefaultKubeconfigKey] = kubeconfig("https://green2.example.com")
+	if err := hub.Update(ctx, secret); err != nil {
+		t.Fatalf("Cannot update Secret: %v", err)
+	}
+	clusters, _ = r.Clusters(ctx)
+	if len(created) != 3 || created[2] != "https://green2.example.com" {
+		t.Errorf("Expected client to be recreated for changed Secret, created %v", created)
+	}
+	if clusters[0].Client == clusters[1].Client {
+		t.Errorf("Expected a client per cluster")
+	}
+}
+
+func TestClientCreationUnlocked(t *testing.T) {
+	ctx := context.Background()
+	blue, blueSecret := registration("blue", "https://blue.example.com")
+	green, greenSecret := registration("green", "https://green.example.com")
+	hub := fake.NewClientBuilder().WithScheme(hubScheme()).
+		WithObjects(blue, blueSecret, green, greenSecret).Build()
+
+	// Creating the client of blue blocks, e.g. on discovery of an
+	// unreachable cluster
+	blocked, release := make(chan struct{}), make(chan struct{})
+	r := NewRegistry(hub, "clusters", func(config *rest.Config) (client.Client, error) {
+		if config.Host == "https://blue.example.com" {
+			close(blocked)
+			<-release
+		}
+		return fake.NewClientBuilder().Build(), nil
+	})
+	done := make(chan client.Client)
+	go func() {
+		c, _ := r.Client(ctx, blue)
+		done <- c
+	}()
+	<-blocked
+
+	greenDone := make(chan error)
+	go func() {
+		_, err := r.Client(ctx, green)
+		greenDone <- err
+	}()
+	select {
+	case err := <-greenDone:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected client of green while creating client of blue")
+	}
+
+	close(release)
+	c := <-done
+	if cached, _ := r.Client(ctx, blue); cached != c {
+		t.Errorf("Expected client of blue cached")
+	}
+}
+
+func TestClusterWeights(t *testing.T) {
+	testCases := map[string]struct {
+		spec            v1alpha1.GatewayClusterSpec
+		annotations     map[string]string
+		notReady        bool
+		expectWeight    int
+		expectEffective int
+	}{
+		"default": {
+			expectWeight:    v1alpha1.DefaultWeight,
+			expectEffective: v1alpha1.DefaultWeight,
+		},
+		"weight": {
+			spec:            v1alpha1.GatewayClusterSpec{Weight: pointer.Int32(25)},
+			expectWeight:    25,
+			expectEffective: 25,
+		},
+		"draining": {
+			spec:            v1alpha1.GatewayClusterSpec{Weight: pointer.Int32(25), Drain: true},
+			expectWeight:    25,
+			expectEffective: 0,
+		},
+		"weight annotation": {
+			spec:            v1alpha1.GatewayClusterSpec{Weight: pointer.Int32(25)},
+			annotations:     map[string]string{v1alpha1.WeightAnnotation: "10"},
+			expectWeight:    10,
+			expectEffective: 10,
+		},
+		"drain annotation": {
+			annotations:     map[string]string{v1alpha1.DrainAnnotation: "true"},
+			expectWeight:    v1alpha1.DefaultWeight,
+			expectEffective: 0,
+		},
+		"invalid annotations": {
+			spec:            v1alpha1.GatewayClusterSpec{Weight: pointer.Int32(25)},
+			annotations:     map[string]string{v1alpha1.WeightAnnotation: "-1", v1alpha1.DrainAnnotation: "maybe"},
+			expectWeight:    25,
+			expectEffective: 25,
+		},
+		"not ready": {
+			notReady:        true,
+			expectWeight:    v1alpha1.DefaultWeight,
+			expectEffective: 0,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			gc, secret := registration("blue", "https://blue.example.com")
+			tc.spec.KubeconfigSecretRef = gc.Spec.KubeconfigSecretRef
+			gc.Spec = tc.spec
+			gc.Annotations = tc.annotations
+			if tc.notReady {
+				gc.Status.Conditions = []metav1.Condition{{Type: v1alpha1.GatewayClusterConditionReady,
+					Status: metav1.ConditionFalse}}
+			}
+			r := NewRegistry(fake.NewClientBuilder().WithScheme(hubScheme()).WithObjects(gc, secret).Build(), "clusters",
+				func(config *rest.Config) (client.Client, error) { return fake.NewClientBuilder().Build(), nil })
+			clusters, err := r.Clusters(context.Background())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(clusters) != 1 {
+				t.Fatalf("Expected one cluster, got %+v", clusters)
+			}
+			if clusters[0].Weight != tc.expectWeight || clusters[0].EffectiveWeight() != tc.expectEffective {
+				t.Errorf("Expected weight %d and effective weight %d, got %+v", tc.expectWeight, tc.expectEffective, clusters[0])
+			}
+		})
+	}
+}
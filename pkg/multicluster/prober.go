@@ -0,0 +1,108 @@
+package multicluster
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+)
+
+// Timeout of probing a single member cluster
+const probeTimeout = 10 * time.Second
+
+// Prober probes member clusters periodically and reports whether they are
+// reachable in the Ready condition of GatewayClusters. Member clusters are
+// probed by listing GatewayClasses, which also verifies that the Gateway API
+// is installed in the cluster.
+type Prober struct {
+	registry *Registry
+	client   client.Client
+	interval time.Duration
+}
+
+// NewProber returns a prober of the clusters of a registry, updating status
+// of GatewayClusters with the given client
+func NewProber(registry *Registry, c client.Client, interval time.Duration) *Prober {
+	return &Prober{registry: registry, client: c, interval: interval}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, such that
+// only the leader probes clusters
+func (p *Prober) NeedLeaderElection() bool {
+	return true
+}
+
+// Start probes clusters until the context is done. Probe errors are logged.
+func (p *Prober) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cluster-prober")
+	ctx = log.IntoContext(ctx, logger)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.ProbeAll(ctx); err != nil {
+			logger.Error(err, "unable to probe member clusters")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeAll probes all clusters and updates the status of GatewayClusters
+// whose Ready condition changed
+func (p *Prober) ProbeAll(ctx context.Context) error {
+	log := log.FromContext(ctx)
+	var gcList v1alpha1.GatewayClusterList
+	if err := p.registry.reader.List(ctx, &gcList, client.InNamespace(p.registry.namespace)); err != nil {
+		return err
+	}
+	for i := range gcList.Items {
+		gc := &gcList.Items[i]
+		cond := p.probe(ctx, gc)
+		conditions := append([]metav1.Condition(nil), gc.Status.Conditions...)
+		meta.SetStatusCondition(&conditions, cond)
+		if equality.Semantic.DeepEqual(conditions, gc.Status.Conditions) {
+			continue
+		}
+		log.Info("member cluster probed", "cluster", gc.Name, "status", cond.Status, "reason", cond.Reason)
+		gc.Status.Conditions = conditions
+		if err := p.client.Status().Update(ctx, gc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probe returns the Ready condition of a cluster
+func (p *Prober) probe(ctx context.Context, gc *v1alpha1.GatewayCluster) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               v1alpha1.GatewayClusterConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.GatewayClusterReasonReachable,
+		ObservedGeneration: gc.Generation,
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	c, err := p.registry.Client(ctx, gc)
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v1alpha1.GatewayClusterReasonInvalidKubeconfig
+		cond.Message = err.Error()
+		return cond
+	}
+	if err := c.List(ctx, &gateway.GatewayClassList{}, client.Limit(1)); err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v1alpha1.GatewayClusterReasonUnreachable
+		cond.Message = err.Error()
+	}
+	return cond
+}
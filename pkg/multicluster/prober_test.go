@@ -0,0 +1,57 @@
+package multicluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	gateway "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/v1alpha1"
+)
+
+func TestProber(t *testing.T) {
+	ctx := context.Background()
+	blue, blueSecret := registration("blue", "https://blue.example.com")
+	green, greenSecret := registration("green", "https://green.example.com")
+	missing, _ := registration("missing", "https://missing.example.com")
+	hub := fake.NewClientBuilder().WithScheme(hubScheme()).
+		WithObjects(blue, blueSecret, green, greenSecret, missing).Build()
+
+	gatewayScheme := runtime.NewScheme()
+	_ = gateway.AddToScheme(gatewayScheme)
+	r := NewRegistry(hub, "clusters", func(config *rest.Config) (client.Client, error) {
+		if config.Host == "https://blue.example.com" {
+			return fake.NewClientBuilder().WithScheme(gatewayScheme).Build(), nil
+		}
+		// Requests fail without the Gateway API
+		return fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(), nil
+	})
+	if err := NewProber(r, hub, time.Minute).ProbeAll(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"blue":    v1alpha1.GatewayClusterReasonReachable,
+		"green":   v1alpha1.GatewayClusterReasonUnreachable,
+		"missing": v1alpha1.GatewayClusterReasonInvalidKubeconfig,
+	}
+	for name, reason := range expected {
+		gc := &v1alpha1.GatewayCluster{}
+		_ = hub.Get(ctx, client.ObjectKey{Namespace: "clusters", Name: name}, gc)
+		cond := meta.FindStatusCondition(gc.Status.Conditions, v1alpha1.GatewayClusterConditionReady)
+		if cond == nil || cond.Reason != reason {
+			t.Errorf("Expected Ready condition of %s with reason %s, got %+v", name, reason, cond)
+		}
+	}
+
+	clusters, _ := r.Clusters(ctx)
+	if len(clusters) != 2 || !clusters[0].Ready || clusters[1].Ready {
+		t.Errorf("Expected blue ready and green not ready, got %+v", clusters)
+	}
+}
@@ -0,0 +1,79 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+)
+
+// CertificateReconciler reconciles SimulatedCertificates. Certificates are
+// assigned an ARN and an expiry time, and are ready when provisioned, i.e.
+// issued.
+type CertificateReconciler struct {
+	client.Client
+	opts Options
+}
+
+//+kubebuilder:rbac:groups=simulator.cloud-gateway-controller.pixelperfekt.dk,resources=simulatedcertificates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=simulator.cloud-gateway-controller.pixelperfekt.dk,resources=simulatedcertificates/status,verbs=get;update;patch
+
+func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cert := &v1alpha1.SimulatedCertificate{}
+	if err := r.Get(ctx, req.NamespacedName, cert); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var result ctrl.Result
+	status := cert.Status.DeepCopy()
+	ready, remaining := provisioning(cert, r.opts.ProvisioningDelay, time.Now())
+	if err := validateCertificate(cert); err != nil {
+		ready = invalid(err)
+	}
+	if ready == nil || ready.Reason == v1alpha1.ReasonProvisioning {
+		status.ARN = fmt.Sprintf("arn:aws:acm:%s:%s:certificate/%08x", region, account, resourceID(cert))
+		result.RequeueAfter = remaining
+	}
+	if ready == nil {
+		ready = provisioned()
+		notAfter := metav1.NewTime(cert.CreationTimestamp.Add(r.opts.ProvisioningDelay + certificateValidity))
+		status.NotAfter = &notAfter
+	}
+	setReady(&status.Conditions, ready, cert)
+
+	if equality.Semantic.DeepEqual(status, &cert.Status) {
+		return result, nil
+	}
+	log.Info("update simulated certificate", "ready", ready.Status, "reason", ready.Reason)
+	cert.Status = *status
+	return result, r.Status().Update(ctx, cert)
+}
+
+// validateCertificate returns an error if the spec of a certificate is
+// invalid
+func validateCertificate(cert *v1alpha1.SimulatedCertificate) error {
+	if len(cert.Spec.DNSNames) == 0 {
+		return fmt.Errorf("no DNS names")
+	}
+	for _, name := range cert.Spec.DNSNames {
+		if err := validateDNSName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.SimulatedCertificate{}).
+		Complete(r)
+}
@@ -0,0 +1,123 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+)
+
+// DNSRecordReconciler reconciles SimulatedDNSRecords. Records are assigned a
+// change ID, which changes with the spec, and are ready when provisioned.
+type DNSRecordReconciler struct {
+	client.Client
+	opts Options
+}
+
+//+kubebuilder:rbac:groups=simulator.cloud-gateway-controller.pixelperfekt.dk,resources=simulateddnsrecords,verbs=get;list;watch
+//+kubebuilder:rbac:groups=simulator.cloud-gateway-controller.pixelperfekt.dk,resources=simulateddnsrecords/status,verbs=get;update;patch
+
+func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	record := &v1alpha1.SimulatedDNSRecord{}
+	if err := r.Get(ctx, req.NamespacedName, record); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var result ctrl.Result
+	status := record.Status.DeepCopy()
+	ready, remaining := provisioning(record, r.opts.ProvisioningDelay, time.Now())
+	if err := validateDNSRecord(record); err != nil {
+		ready = invalid(err)
+	}
+	if ready == nil || ready.Reason == v1alpha1.ReasonProvisioning {
+		status.ChangeID = changeID(record)
+		result.RequeueAfter = remaining
+	}
+	if ready == nil {
+		ready = provisioned()
+	}
+	setReady(&status.Conditions, ready, record)
+
+	if equality.Semantic.DeepEqual(status, &record.Status) {
+		return result, nil
+	}
+	log.Info("update simulated dns record", "ready", ready.Status, "reason", ready.Reason)
+	record.Status = *status
+	return result, r.Status().Update(ctx, record)
+}
+
+// validateDNSRecord returns an error if the spec of a DNS record is invalid
+func validateDNSRecord(record *v1alpha1.SimulatedDNSRecord) error {
+	if err := validateDNSName(record.Spec.Name); err != nil {
+		return err
+	}
+	if len(record.Spec.Targets) == 0 {
+		return fmt.Errorf("no targets")
+	}
+	for _, t := range record.Spec.Targets {
+		ip := net.ParseIP(t)
+		switch record.Spec.Type {
+		case v1alpha1.DNSRecordTypeA:
+			if ip == nil || ip.To4() == nil {
+				return fmt.Errorf("target %q of A record is not an IPv4 address", t)
+			}
+		case v1alpha1.DNSRecordTypeAAAA:
+			if ip == nil || ip.To4() != nil {
+				return fmt.Errorf("target %q of AAAA record is not an IPv6 address", t)
+			}
+		case v1alpha1.DNSRecordTypeCNAME:
+			if len(record.Spec.Targets) != 1 {
+				return fmt.Errorf("CNAME record must have a single target")
+			}
+			if ip != nil {
+				return fmt.Errorf("target %q of CNAME record is not a hostname", t)
+			}
+			if err := validateDNSName(t); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported record type %q", record.Spec.Type)
+		}
+	}
+	return nil
+}
+
+// validateDNSName returns an error if a name is not a valid DNS name. Names
+// may be fully qualified with a trailing dot and may be wildcards.
+func validateDNSName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+	errs := validation.IsDNS1123Subdomain(name)
+	if strings.HasPrefix(name, "*.") {
+		errs = validation.IsWildcardDNS1123Subdomain(name)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid DNS name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// changeID returns the ID of the change of a DNS record to its current spec
+func changeID(record *v1alpha1.SimulatedDNSRecord) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s/%s/%s/%v/%d", record.Namespace, record.Name, record.Spec.Name, record.Spec.Type,
+		record.Spec.Targets, record.Spec.TTL)
+	return fmt.Sprintf("C%016X", h.Sum64())
+}
+
+func (r *DNSRecordReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.SimulatedDNSRecord{}).
+		Complete(r)
+}
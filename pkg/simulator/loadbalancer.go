@@ -0,0 +1,175 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+)
+
+// LoadBalancerReconciler reconciles SimulatedLoadBalancers. Load balancers
+// are assigned addresses and an ARN right away, and are ready when
+// provisioned and their certificates are ready.
+type LoadBalancerReconciler struct {
+	client.Client
+	opts Options
+}
+
+//+kubebuilder:rbac:groups=simulator.cloud-gateway-controller.pixelperfekt.dk,resources=simulatedloadbalancers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=simulator.cloud-gateway-controller.pixelperfekt.dk,resources=simulatedloadbalancers/status,verbs=get;update;patch
+
+func (r *LoadBalancerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	lb := &v1alpha1.SimulatedLoadBalancer{}
+	if err := r.Get(ctx, req.NamespacedName, lb); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var result ctrl.Result
+	status := lb.Status.DeepCopy()
+	ready, remaining := provisioning(lb, r.opts.ProvisioningDelay, time.Now())
+	if err := validateLoadBalancer(lb); err != nil {
+		ready = invalid(err)
+	}
+	if ready == nil || ready.Reason == v1alpha1.ReasonProvisioning {
+		id := resourceID(lb)
+		status.ARN = fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:loadbalancer/app/%s/%08x", region, account, lb.Name, id)
+		status.LoadBalancer = loadBalancerIngress(lb, id)
+		result.RequeueAfter = remaining
+	}
+	if ready == nil {
+		notReady, err := r.certificatesNotReady(ctx, lb)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		ready = provisioned()
+		if len(notReady) > 0 {
+			ready = &metav1.Condition{
+				Type:    v1alpha1.ConditionReady,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.ReasonCertificateNotReady,
+				Message: fmt.Sprintf("Certificates not ready: %v", notReady),
+			}
+		}
+	}
+	setReady(&status.Conditions, ready, lb)
+
+	if equality.Semantic.DeepEqual(status, &lb.Status) {
+		return result, nil
+	}
+	log.Info("update simulated load balancer", "ready", ready.Status, "reason", ready.Reason)
+	lb.Status = *status
+	return result, r.Status().Update(ctx, lb)
+}
+
+// validateLoadBalancer returns an error if the spec of a load balancer is
+// invalid
+func validateLoadBalancer(lb *v1alpha1.SimulatedLoadBalancer) error {
+	for _, a := range lb.Spec.Addresses {
+		if net.ParseIP(a) == nil {
+			return fmt.Errorf("address %q is not an IP address", a)
+		}
+	}
+	for _, l := range lb.Spec.Listeners {
+		if l.Protocol == "HTTPS" && len(l.CertificateRefs) == 0 {
+			return fmt.Errorf("HTTPS listener on port %d has no certificates", l.Port)
+		}
+	}
+	for _, b := range lb.Spec.Backends {
+		if b.Address == "" {
+			return fmt.Errorf("backend without address")
+		}
+	}
+	return nil
+}
+
+// loadBalancerIngress returns the addresses of a load balancer, i.e. the
+// requested addresses or an address from the simulated address pool, and a
+// hostname
+func loadBalancerIngress(lb *v1alpha1.SimulatedLoadBalancer, id uint32) corev1.LoadBalancerStatus {
+	hostname := fmt.Sprintf("%s-%08x.%s.%s", lb.Name, id, region, hostnameDomain)
+	addresses := lb.Spec.Addresses
+	if len(addresses) == 0 {
+		// TEST-NET-2 for internet-facing load balancers
+		address := fmt.Sprintf("198.51.100.%d", 1+id%254)
+		if lb.Spec.Scheme == v1alpha1.LoadBalancerSchemeInternal {
+			address = fmt.Sprintf("10.%d.%d.%d", id>>16&0xff, id>>8&0xff, 1+id%254)
+		}
+		addresses = []string{address}
+	}
+	var status corev1.LoadBalancerStatus
+	for _, a := range addresses {
+		status.Ingress = append(status.Ingress, corev1.LoadBalancerIngress{IP: a, Hostname: hostname})
+	}
+	return status
+}
+
+// certificatesNotReady returns the names of certificates referenced by a
+// load balancer that are not found or not ready
+func (r *LoadBalancerReconciler) certificatesNotReady(ctx context.Context, lb *v1alpha1.SimulatedLoadBalancer) ([]string, error) {
+	var notReady []string
+	for _, l := range lb.Spec.Listeners {
+		for _, name := range l.CertificateRefs {
+			cert := &v1alpha1.SimulatedCertificate{}
+			err := r.Get(ctx, client.ObjectKey{Namespace: lb.Namespace, Name: name}, cert)
+			if apierrors.IsNotFound(err) {
+				notReady = append(notReady, name)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if !meta.IsStatusConditionTrue(cert.Status.Conditions, v1alpha1.ConditionReady) {
+				notReady = append(notReady, name)
+			}
+		}
+	}
+	return notReady, nil
+}
+
+// certificateRequests maps a certificate to requests for the load balancers
+// in its namespace referencing it
+func (r *LoadBalancerReconciler) certificateRequests(obj client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var lbList v1alpha1.SimulatedLoadBalancerList
+	if err := r.List(ctx, &lbList, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list simulated load balancers")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range lbList.Items {
+		lb := &lbList.Items[i]
+	listeners:
+		for _, l := range lb.Spec.Listeners {
+			for _, name := range l.CertificateRefs {
+				if name == obj.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(lb)})
+					break listeners
+				}
+			}
+		}
+	}
+	return requests
+}
+
+func (r *LoadBalancerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.SimulatedLoadBalancer{}).
+		Watches(&source.Kind{Type: &v1alpha1.SimulatedCertificate{}},
+			handler.EnqueueRequestsFromMapFunc(r.certificateRequests)).
+		Complete(r)
+}
@@ -0,0 +1,107 @@
+// Package simulator implements a local cloud provider for tests. Simulated
+// load balancers, DNS records and certificates are reconciled by filling in
+// their status with fake addresses and ARNs, such that class templates can
+// target them and the full lifecycle of a Gateway including status
+// propagation can be exercised without external components.
+package simulator
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+)
+
+const (
+	// Region and account of simulated ARNs
+	region  = "sim-local-1"
+	account = "000000000000"
+
+	// Domain of simulated load balancer hostnames
+	hostnameDomain = "lb.simulator.local"
+
+	// Validity of simulated certificates
+	certificateValidity = 90 * 24 * time.Hour
+)
+
+// Options configure the simulator
+type Options struct {
+	// ProvisioningDelay is the time from creation of a simulated resource
+	// until it is ready
+	ProvisioningDelay time.Duration
+}
+
+// SetupWithManager adds the reconcilers of all simulated resources to a
+// manager
+func SetupWithManager(mgr ctrl.Manager, opts Options) error {
+	if err := (&LoadBalancerReconciler{Client: mgr.GetClient(), opts: opts}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&DNSRecordReconciler{Client: mgr.GetClient(), opts: opts}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	return (&CertificateReconciler{Client: mgr.GetClient(), opts: opts}).SetupWithManager(mgr)
+}
+
+// resourceID returns a short identifier of a simulated resource, stable
+// across reconciles
+func resourceID(obj client.Object) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(obj.GetNamespace() + "/" + obj.GetName()))
+	return h.Sum32()
+}
+
+// provisioning returns the Ready condition of a simulated resource that is
+// failed or still being provisioned, with the time until it is provisioned,
+// and nil if the resource is provisioned. Failures injected with the
+// FailAnnotation take precedence.
+func provisioning(obj client.Object, delay time.Duration, now time.Time) (*metav1.Condition, time.Duration) {
+	if msg, found := obj.GetAnnotations()[v1alpha1.FailAnnotation]; found {
+		return &metav1.Condition{
+			Type:    v1alpha1.ConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.ReasonFailed,
+			Message: msg,
+		}, 0
+	}
+	if remaining := obj.GetCreationTimestamp().Add(delay).Sub(now); remaining > 0 {
+		return &metav1.Condition{
+			Type:    v1alpha1.ConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.ReasonProvisioning,
+			Message: fmt.Sprintf("Provisioning for %s", remaining.Round(time.Second)),
+		}, remaining
+	}
+	return nil, 0
+}
+
+// invalid returns a false Ready condition for an invalid spec
+func invalid(err error) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    v1alpha1.ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  v1alpha1.ReasonInvalid,
+		Message: err.Error(),
+	}
+}
+
+// provisioned returns a true Ready condition
+func provisioned() *metav1.Condition {
+	return &metav1.Condition{
+		Type:   v1alpha1.ConditionReady,
+		Status: metav1.ConditionTrue,
+		Reason: v1alpha1.ReasonProvisioned,
+	}
+}
+
+// setReady sets the Ready condition of a simulated resource
+func setReady(conditions *[]metav1.Condition, ready *metav1.Condition, obj client.Object) {
+	ready.ObservedGeneration = obj.GetGeneration()
+	meta.SetStatusCondition(conditions, *ready)
+}
@@ -0,0 +1,296 @@
+package simulator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/apis/simulator/v1alpha1"
+)
+
+func testClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func request(obj client.Object) ctrl.Request {
+	return ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)}
+}
+
+func readyCondition(t *testing.T, conditions []metav1.Condition) *metav1.Condition {
+	cond := meta.FindStatusCondition(conditions, v1alpha1.ConditionReady)
+	if cond == nil {
+		t.Fatalf("Expected Ready condition, got %+v", conditions)
+	}
+	return cond
+}
+
+func TestLoadBalancer(t *testing.T) {
+	created := metav1.NewTime(time.Now())
+	cert := func(ready metav1.ConditionStatus) *v1alpha1.SimulatedCertificate {
+		return &v1alpha1.SimulatedCertificate{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-cert", Namespace: "default"},
+			Status: v1alpha1.SimulatedCertificateStatus{Conditions: []metav1.Condition{{
+				Type: v1alpha1.ConditionReady, Status: ready}}},
+		}
+	}
+
+	testCases := map[string]struct {
+		spec          v1alpha1.SimulatedLoadBalancerSpec
+		annotations   map[string]string
+		delay         time.Duration
+		objs          []client.Object
+		expectReason  string
+		expectAddress string
+		expectRequeue bool
+	}{
+		"provisioned": {
+			expectReason:  v1alpha1.ReasonProvisioned,
+			expectAddress: "198.51.100.",
+		},
+		"internal": {
+			spec:          v1alpha1.SimulatedLoadBalancerSpec{Scheme: v1alpha1.LoadBalancerSchemeInternal},
+			expectReason:  v1alpha1.ReasonProvisioned,
+			expectAddress: "10.",
+		},
+		"requested address": {
+			spec:          v1alpha1.SimulatedLoadBalancerSpec{Addresses: []string{"203.0.113.10"}},
+			expectReason:  v1alpha1.ReasonProvisioned,
+			expectAddress: "203.0.113.10",
+		},
+		"provisioning": {
+			delay:         time.Hour,
+			expectReason:  v1alpha1.ReasonProvisioning,
+			expectAddress: "198.51.100.",
+			expectRequeue: true,
+		},
+		"certificate ready": {
+			spec: v1alpha1.SimulatedLoadBalancerSpec{Listeners: []v1alpha1.SimulatedListener{{
+				Port: 443, Protocol: "HTTPS", CertificateRefs: []string{"foo-cert"}}}},
+			objs:          []client.Object{cert(metav1.ConditionTrue)},
+			expectReason:  v1alpha1.ReasonProvisioned,
+			expectAddress: "198.51.100.",
+		},
+		"certificate not ready": {
+			spec: v1alpha1.SimulatedLoadBalancerSpec{Listeners: []v1alpha1.SimulatedListener{{
+				Port: 443, Protocol: "HTTPS", CertificateRefs: []string{"foo-cert"}}}},
+			objs:          []client.Object{cert(metav1.ConditionFalse)},
+			expectReason:  v1alpha1.ReasonCertificateNotReady,
+			expectAddress: "198.51.100.",
+		},
+		"certificate not found": {
+			spec: v1alpha1.SimulatedLoadBalancerSpec{Listeners: []v1alpha1.SimulatedListener{{
+				Port: 443, Protocol: "HTTPS", CertificateRefs: []string{"foo-cert"}}}},
+			expectReason:  v1alpha1.ReasonCertificateNotReady,
+			expectAddress: "198.51.100.",
+		},
+		"https without certificate": {
+			spec: v1alpha1.SimulatedLoadBalancerSpec{Listeners: []v1alpha1.SimulatedListener{{
+				Port: 443, Protocol: "HTTPS"}}},
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+		"failure injected": {
+			annotations:  map[string]string{v1alpha1.FailAnnotation: "quota exceeded"},
+			expectReason: v1alpha1.ReasonFailed,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			lb := &v1alpha1.SimulatedLoadBalancer{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: tc.annotations,
+					CreationTimestamp: created},
+				Spec: tc.spec,
+			}
+			c := testClient(append(tc.objs, lb)...)
+			r := &LoadBalancerReconciler{Client: c, opts: Options{ProvisioningDelay: tc.delay}}
+			result, err := r.Reconcile(ctx, request(lb))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (result.RequeueAfter > 0) != tc.expectRequeue {
+				t.Errorf("Expected requeue %v, got %v", tc.expectRequeue, result.RequeueAfter)
+			}
+
+			_ = c.Get(ctx, client.ObjectKeyFromObject(lb), lb)
+			if cond := readyCondition(t, lb.Status.Conditions); cond.Reason != tc.expectReason {
+				t.Errorf("Expected reason %s, got %+v", tc.expectReason, cond)
+			}
+			if tc.expectAddress == "" {
+				if len(lb.Status.LoadBalancer.Ingress) != 0 || lb.Status.ARN != "" {
+					t.Errorf("Expected no address and ARN, got %+v", lb.Status)
+				}
+				return
+			}
+			ingress := lb.Status.LoadBalancer.Ingress
+			if len(ingress) != 1 || !strings.HasPrefix(ingress[0].IP, tc.expectAddress) ||
+				!strings.HasPrefix(ingress[0].Hostname, "foo-") {
+				t.Errorf("Expected address %s, got %+v", tc.expectAddress, ingress)
+			}
+			if !strings.HasPrefix(lb.Status.ARN, "arn:aws:elasticloadbalancing:") {
+				t.Errorf("Unexpected ARN %s", lb.Status.ARN)
+			}
+
+			// Status is stable
+			version := lb.ResourceVersion
+			if _, err := r.Reconcile(ctx, request(lb)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			_ = c.Get(ctx, client.ObjectKeyFromObject(lb), lb)
+			if lb.ResourceVersion != version {
+				t.Errorf("Expected status unchanged on second reconcile")
+			}
+		})
+	}
+}
+
+func TestCertificateRequests(t *testing.T) {
+	lbs := []client.Object{
+		&v1alpha1.SimulatedLoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Spec: v1alpha1.SimulatedLoadBalancerSpec{Listeners: []v1alpha1.SimulatedListener{
+				{Port: 443, Protocol: "HTTPS", CertificateRefs: []string{"foo-cert"}},
+				{Port: 8443, Protocol: "HTTPS", CertificateRefs: []string{"foo-cert"}}}}},
+		&v1alpha1.SimulatedLoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"}},
+		&v1alpha1.SimulatedLoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "other"},
+			Spec: v1alpha1.SimulatedLoadBalancerSpec{Listeners: []v1alpha1.SimulatedListener{
+				{Port: 443, Protocol: "HTTPS", CertificateRefs: []string{"foo-cert"}}}}},
+	}
+	r := &LoadBalancerReconciler{Client: testClient(lbs...)}
+	requests := r.certificateRequests(&v1alpha1.SimulatedCertificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-cert", Namespace: "default"}})
+	if len(requests) != 1 || requests[0].Name != "foo" || requests[0].Namespace != "default" {
+		t.Errorf("Expected request for default/foo, got %+v", requests)
+	}
+}
+
+func TestDNSRecord(t *testing.T) {
+	testCases := map[string]struct {
+		spec         v1alpha1.SimulatedDNSRecordSpec
+		expectReason string
+	}{
+		"A record": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "www.example.com.", Type: "A", Targets: []string{"198.51.100.1"}},
+			expectReason: v1alpha1.ReasonProvisioned,
+		},
+		"AAAA record": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "www.example.com", Type: "AAAA", Targets: []string{"2001:db8::1"}},
+			expectReason: v1alpha1.ReasonProvisioned,
+		},
+		"CNAME record": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "*.example.com", Type: "CNAME", Targets: []string{"foo.lb.simulator.local"}},
+			expectReason: v1alpha1.ReasonProvisioned,
+		},
+		"A record with hostname": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "www.example.com", Type: "A", Targets: []string{"foo.example.com"}},
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+		"AAAA record with IPv4 address": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "www.example.com", Type: "AAAA", Targets: []string{"198.51.100.1"}},
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+		"CNAME record with several targets": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "www.example.com", Type: "CNAME", Targets: []string{"a.example.com", "b.example.com"}},
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+		"invalid name": {
+			spec:         v1alpha1.SimulatedDNSRecordSpec{Name: "www_example.com", Type: "A", Targets: []string{"198.51.100.1"}},
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			record := &v1alpha1.SimulatedDNSRecord{
+				ObjectMeta: metav1.ObjectMeta{Name: "www", Namespace: "default"},
+				Spec:       tc.spec,
+			}
+			c := testClient(record)
+			r := &DNSRecordReconciler{Client: c}
+			if _, err := r.Reconcile(ctx, request(record)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			_ = c.Get(ctx, client.ObjectKeyFromObject(record), record)
+			if cond := readyCondition(t, record.Status.Conditions); cond.Reason != tc.expectReason {
+				t.Errorf("Expected reason %s, got %+v", tc.expectReason, cond)
+			}
+			if (record.Status.ChangeID != "") != (tc.expectReason == v1alpha1.ReasonProvisioned) {
+				t.Errorf("Unexpected change ID %q", record.Status.ChangeID)
+			}
+		})
+	}
+}
+
+func TestDNSRecordChangeID(t *testing.T) {
+	record := &v1alpha1.SimulatedDNSRecord{
+		ObjectMeta: metav1.ObjectMeta{Name: "www", Namespace: "default"},
+		Spec:       v1alpha1.SimulatedDNSRecordSpec{Name: "www.example.com", Type: "A", Targets: []string{"198.51.100.1"}},
+	}
+	id := changeID(record)
+	if changeID(record) != id {
+		t.Errorf("Expected stable change ID")
+	}
+	record.Spec.Targets = []string{"198.51.100.2"}
+	if changeID(record) == id {
+		t.Errorf("Expected change ID to change with targets")
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	testCases := map[string]struct {
+		dnsNames     []string
+		annotations  map[string]string
+		expectReason string
+	}{
+		"issued": {
+			dnsNames:     []string{"example.com", "*.example.com"},
+			expectReason: v1alpha1.ReasonProvisioned,
+		},
+		"invalid name": {
+			dnsNames:     []string{"-example.com"},
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+		"no names": {
+			expectReason: v1alpha1.ReasonInvalid,
+		},
+		"failure injected": {
+			dnsNames:     []string{"example.com"},
+			annotations:  map[string]string{v1alpha1.FailAnnotation: "CAA record forbids issuing"},
+			expectReason: v1alpha1.ReasonFailed,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cert := &v1alpha1.SimulatedCertificate{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-cert", Namespace: "default", Annotations: tc.annotations},
+				Spec:       v1alpha1.SimulatedCertificateSpec{DNSNames: tc.dnsNames},
+			}
+			c := testClient(cert)
+			r := &CertificateReconciler{Client: c}
+			if _, err := r.Reconcile(ctx, request(cert)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			_ = c.Get(ctx, client.ObjectKeyFromObject(cert), cert)
+			cond := readyCondition(t, cert.Status.Conditions)
+			if cond.Reason != tc.expectReason {
+				t.Errorf("Expected reason %s, got %+v", tc.expectReason, cond)
+			}
+			if tc.expectReason == v1alpha1.ReasonProvisioned &&
+				(!strings.HasPrefix(cert.Status.ARN, "arn:aws:acm:") || cert.Status.NotAfter == nil) {
+				t.Errorf("Expected ARN and expiry of issued certificate, got %+v", cert.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package tracing provides OpenTelemetry tracing of reconciles, with optional
+// export of traces to an OTLP collector.
+package tracing
+
+import (
+	"context"
+	"flag"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pixelperfekt-dk/cloud-gateway-controller/pkg/version"
+)
+
+const (
+	serviceName = "cloud-gateway-controller"
+	tracerName  = "github.com/pixelperfekt-dk/cloud-gateway-controller"
+)
+
+// Span attribute keys
+const (
+	GatewayKey      = attribute.Key("gateway")
+	GatewayClassKey = attribute.Key("class")
+	HTTPRouteKey    = attribute.Key("httproute")
+	TemplateKey     = attribute.Key("template")
+	GVKKey          = attribute.Key("gvk")
+	NameKey         = attribute.Key("name")
+	ClusterKey      = attribute.Key("cluster")
+)
+
+// Options configures trace export
+type Options struct {
+	// Endpoint of the OTLP gRPC collector. Tracing is disabled if empty.
+	Endpoint string
+
+	// Insecure disables TLS towards the collector
+	Insecure bool
+
+	// SampleRatio is the fraction of reconciles traced
+	SampleRatio float64
+}
+
+// BindFlags binds the options to command line flags
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "otlp-endpoint", "",
+		"The OTLP gRPC collector endpoint traces are exported to, e.g. 'localhost:4317'. Tracing is disabled if empty.")
+	fs.BoolVar(&o.Insecure, "otlp-insecure", false, "Disable TLS towards the OTLP collector.")
+	fs.Float64Var(&o.SampleRatio, "trace-sample-ratio", 1.0, "The fraction of reconciles traced.")
+}
+
+// Setup installs a global tracer provider exporting traces to the configured
+// collector. The returned function flushes and stops trace export. If no
+// endpoint is configured, tracing is a no-op.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return SetupWithExporter(exporter, opts.SampleRatio), nil
+}
+
+// SetupWithExporter installs a global tracer provider exporting traces with
+// the given exporter, e.g. an in-memory exporter in tests. The returned
+// function flushes and stops trace export.
+func SetupWithExporter(exporter sdktrace.SpanExporter, sampleRatio float64) func(context.Context) error {
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName),
+		semconv.ServiceVersionKey.String(version.Version))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown
+}
+
+// Start starts a span using the controller tracer
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span, recording the error if non-nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// collector is an in-memory stand-in for an OTLP collector that keeps the
+// exported spans on shutdown
+type collector struct {
+	*tracetest.InMemoryExporter
+}
+
+func (c collector) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected shutdown error: %v", err)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	exporter := collector{tracetest.NewInMemoryExporter()}
+	shutdown := SetupWithExporter(exporter, 1.0)
+
+	ctx, parent := Start(context.Background(), "Reconcile", GatewayKey.String("default/foo"))
+	_, child := Start(ctx, "patch", GVKKey.String("/v1, Kind=Service"))
+	End(child, errors.New("apply failed"))
+	End(parent, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected shutdown error: %v", err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	patch, reconcile := spans[0], spans[1]
+	if patch.Parent.SpanID() != reconcile.SpanContext.SpanID() {
+		t.Errorf("Expected patch span to be a child of the reconcile span")
+	}
+	if patch.Status.Code != codes.Error || len(patch.Events) != 1 {
+		t.Errorf("Expected error recorded on patch span, got %+v", patch.Status)
+	}
+	if reconcile.Status.Code != codes.Unset {
+		t.Errorf("Unexpected status on reconcile span: %+v", reconcile.Status)
+	}
+	if len(reconcile.Attributes) != 1 || reconcile.Attributes[0] != GatewayKey.String("default/foo") {
+		t.Errorf("Unexpected reconcile span attributes: %v", reconcile.Attributes)
+	}
+}